@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	merkletree "github.com/pycckuu/merkle-tree-generation/multilevelmktree"
+)
+
+// bench generates a tree several times without writing it, reporting how long
+// each run took
+func bench(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	treeFlags := addTreeFlags(flags)
+	runsPtr := flags.Int("runs", 3, "The number of times to generate the tree")
+	parseFlags(flags, args, false)
+
+	hLevel, branchLevels, _ := treeFlags.tiers()
+	numLeaves := 1 << (hLevel + sum(branchLevels))
+	treeFlags.checkLeaves(hLevel+sum(branchLevels), *treeFlags.preImage)
+	treeFlags.confirm(hLevel, branchLevels)
+	opts := treeFlags.options()
+
+	ctx, cancel := treeFlags.context()
+	defer cancel()
+
+	var total time.Duration
+	for run := 1; run <= *runsPtr; run++ {
+		start := time.Now()
+		branches, err := getMerkleRoots(ctx, treeFlags.branchBuild(hLevel, branchLevels, *treeFlags.preImage, opts))
+		if err != nil {
+			log.Fatal(err)
+		}
+		merkletree.NewMerkleTreeWithLeaves(branches, opts...)
+		elapsed := time.Since(start)
+		total += elapsed
+
+		fmt.Printf("run %d: %v, %.0f leaves/s\n", run, elapsed, float64(numLeaves)/elapsed.Seconds())
+	}
+	if *runsPtr > 0 {
+		fmt.Printf("mean: %v over %d leaves\n", total/time.Duration(*runsPtr), numLeaves)
+	}
+}
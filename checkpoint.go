@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"time"
+)
+
+// checkpointInterval is how often -checkpoint saves the branches built so far
+const checkpointInterval = 30 * time.Second
+
+// checkpoint saves the leading branches built so far, along with the
+// parameters of the output and a digest of its leaves, so an interrupted run
+// can resume from them. It is written as a JSON output holding only those
+// branches and no root.
+type checkpoint struct {
+	path     string
+	output   Output
+	digest   string
+	branches []*big.Int
+	saved    time.Time
+}
+
+// checkpointFile is the file a checkpoint is written to
+type checkpointFile struct {
+	Output
+	LeafDigest string `json:"leafDigest,omitempty"`
+}
+
+// leafDigest returns the SHA-256 hash of the leaves as 32-byte words, in hex,
+// or "" without leaves
+func leafDigest(leaves []*big.Int) string {
+	if len(leaves) == 0 {
+		return ""
+	}
+
+	hash := sha256.New()
+	for _, leaf := range leaves {
+		hash.Write(word(leaf))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// loadCheckpoint returns the checkpoint at path for output and the digest of
+// its leaves, holding the branches of an earlier run with the same parameters
+// and leaves, or none if there is no file at path
+func loadCheckpoint(path string, output *Output, digest string) (*checkpoint, error) {
+	c := &checkpoint{path: path, output: *output, digest: digest, saved: time.Now()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file checkpointFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	saved := file.Output
+	branches := saved.Branches
+	saved.Branches = nil
+
+	// Compare the parameters as they are written, so unset fields match
+	want, err := json.Marshal(output)
+	if err != nil {
+		return nil, err
+	}
+	got, err := json.Marshal(saved)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(got, want) {
+		return nil, fmt.Errorf("checkpoint %s was written with other parameters: %s", path, got)
+	}
+	if file.LeafDigest != digest {
+		return nil, fmt.Errorf("checkpoint %s was written with other leaves", path)
+	}
+	if len(branches) > 1<<output.HLevel {
+		return nil, fmt.Errorf("reading %s: too many branches", path)
+	}
+
+	c.branches = make([]*big.Int, len(branches))
+	for i, branch := range branches {
+		if c.branches[i], err = parseHex(branch); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+
+	return c, nil
+}
+
+// add records branch i, built after all branches before it, and saves the
+// checkpoint if it was last saved more than checkpointInterval ago
+func (c *checkpoint) add(i int, root *big.Int) error {
+	// Branches resumed from the checkpoint are already in it
+	if i < len(c.branches) {
+		return nil
+	}
+	c.branches = append(c.branches, root)
+	if time.Since(c.saved) < checkpointInterval {
+		return nil
+	}
+
+	return c.save()
+}
+
+// save writes the checkpoint to a temporary file and renames it over path, so
+// a crash while saving keeps the previous checkpoint
+func (c *checkpoint) save() error {
+	file := checkpointFile{Output: c.output, LeafDigest: c.digest}
+	file.Branches = make([]string, len(c.branches))
+	for i, branch := range c.branches {
+		file.Branches[i] = hexWord(branch)
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	c.saved = time.Now()
+
+	return nil
+}
+
+// remove deletes the checkpoint once the output is written
+func (c *checkpoint) remove() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.checkpoint")
+	output := &Output{HLevel: 2, LLevel: 4, Hasher: "poseidon", Leaves: "leaves.txt", LeafEncoding: "auto"}
+	digest := leafDigest([]*big.Int{big.NewInt(1), big.NewInt(2)})
+
+	c, err := loadCheckpoint(path, output, digest)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if len(c.branches) != 0 {
+		t.Fatal("Expected no branches without a checkpoint file, got", len(c.branches))
+	}
+	branches := []*big.Int{big.NewInt(7), big.NewInt(8)}
+	for i, branch := range branches {
+		if err := c.add(i, branch); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+	if err := c.save(); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	resumed, err := loadCheckpoint(path, output, digest)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if len(resumed.branches) != len(branches) {
+		t.Fatal("Expected", len(branches), "resumed branches, got", len(resumed.branches))
+	}
+	for i, branch := range resumed.branches {
+		if branch.Cmp(branches[i]) != 0 {
+			t.Error("Expected resumed branch", i, "to be", branches[i], "got", branch)
+		}
+	}
+
+	mismatches := []struct {
+		name   string
+		output Output
+		digest string
+	}{
+		{"levels", Output{HLevel: 3, LLevel: 3, Hasher: "poseidon", Leaves: "leaves.txt", LeafEncoding: "auto"}, digest},
+		{"hasher", Output{HLevel: 2, LLevel: 4, Hasher: "keccak256", Leaves: "leaves.txt", LeafEncoding: "auto"}, digest},
+		{"hashLeaves", Output{HLevel: 2, LLevel: 4, Hasher: "poseidon", Leaves: "leaves.txt", LeafEncoding: "auto", HashLeaves: true}, digest},
+		{"leaves", *output, leafDigest([]*big.Int{big.NewInt(1), big.NewInt(3)})},
+		{"no leaves", *output, ""},
+	}
+	for _, m := range mismatches {
+		if _, err := loadCheckpoint(path, &m.output, m.digest); err == nil {
+			t.Error("Expected error for a checkpoint with other", m.name, "got nil")
+		}
+	}
+
+	if err := resumed.remove(); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the checkpoint to be removed, got", err)
+	}
+	if err := resumed.remove(); err != nil {
+		t.Error("Expected removing a missing checkpoint to succeed, got", err)
+	}
+}
+
+func TestLoadCheckpointInvalid(t *testing.T) {
+	output := &Output{HLevel: 1, LLevel: 1, Hasher: "poseidon"}
+
+	cases := []string{
+		`not json`,
+		`{"hLevel":1,"lLevel":1,"preimage":0,"hasher":"poseidon","branches":["0x01","0x02","0x03"]}`,
+		`{"hLevel":1,"lLevel":1,"preimage":0,"hasher":"poseidon","branches":["branch"]}`,
+	}
+	for _, data := range cases {
+		path := filepath.Join(t.TempDir(), "run.checkpoint")
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if _, err := loadCheckpoint(path, output, ""); err == nil {
+			t.Error("Expected error for checkpoint", data, "got nil")
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfig reads a YAML config file of flag values keyed by flag name, such
+// as
+//
+//	hLevel: 8
+//	levels: [8, 8, 12]
+//	hash: poseidon
+//	format: ndjson
+//
+// returning every value as it would be given on the command line. Lists are
+// joined with commas.
+func loadConfig(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(fields))
+	for key, field := range fields {
+		switch field := field.(type) {
+		case []interface{}:
+			items := make([]string, len(field))
+			for i, item := range field {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case map[string]interface{}:
+			return nil, fmt.Errorf("reading %s: %s is not a value", path, key)
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(field)
+		}
+	}
+
+	return values, nil
+}
+
+// parseFlags parses args into flags. The flags not given in args are then set
+// from the file given with -config, if any, so the command line overrides it.
+// Settings that are not flags are an error when strict and skipped otherwise,
+// so commands other than generate can share its config files.
+func parseFlags(flags *flag.FlagSet, args []string, strict bool) {
+	flags.Parse(args)
+
+	config := flags.Lookup("config")
+	if config == nil || config.Value.String() == "" {
+		return
+	}
+	path := config.Value.String()
+
+	values, err := loadConfig(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, value := range values {
+		if name == "config" || flags.Lookup(name) == nil && strict {
+			log.Fatalf("reading %s: unknown setting %q", path, name)
+		}
+		if flags.Lookup(name) == nil || given[name] {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			log.Fatalf("reading %s: %s: %v", path, name, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig writes a config file holding data and returns its path
+func writeConfig(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, "hLevel: 8\nlevels: [8, 8, 12]\nhash: poseidon\nquiet: true\ndomainTag:\n")
+	values, err := loadConfig(path)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	expected := map[string]string{"hLevel": "8", "levels": "8,8,12", "hash": "poseidon", "quiet": "true", "domainTag": ""}
+	if len(values) != len(expected) {
+		t.Error("Expected", len(expected), "values, got", values)
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Error("Expected", key, "to be", value, "got", values[key])
+		}
+	}
+
+	for _, data := range []string{"hash: {name: poseidon}\n", "hLevel: [\n"} {
+		if _, err := loadConfig(writeConfig(t, data)); err == nil {
+			t.Error("Expected error for config", data, "got nil")
+		}
+	}
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected error for a missing config, got nil")
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	path := writeConfig(t, "hLevel: 8\nlLevel: 12\nhash: keccak256\nformat: csv\n")
+
+	cases := []struct {
+		args   []string
+		strict bool
+		hLevel int
+		lLevel int
+		hash   string
+		format string
+	}{
+		{[]string{}, true, 4, 16, "poseidon", "json"},
+		{[]string{"-config", path}, true, 8, 12, "keccak256", "csv"},
+		{[]string{"-config", path, "-hLevel=2"}, true, 2, 12, "keccak256", "csv"},
+		{[]string{"-hash=sha256", "-config", path, "-lLevel=0", "-format=bin"}, true, 8, 0, "sha256", "bin"},
+		{[]string{"-config", path, "-hLevel=8"}, false, 8, 12, "keccak256", ""},
+	}
+	for _, c := range cases {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		treeFlags := addTreeFlags(flags)
+		// Only strict commands, like generate, have -format
+		format := new(string)
+		if c.strict {
+			format = flags.String("format", "json", "")
+		}
+		parseFlags(flags, c.args, c.strict)
+
+		if *treeFlags.hLevel != c.hLevel || *treeFlags.lLevel != c.lLevel || *treeFlags.hash != c.hash {
+			t.Error("Expected", c.hLevel, c.lLevel, c.hash, "for", c.args, "got", *treeFlags.hLevel, *treeFlags.lLevel, *treeFlags.hash)
+		}
+		if *format != c.format {
+			t.Error("Expected format", c.format, "for", c.args, "got", *format)
+		}
+	}
+}
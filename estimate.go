@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	merkletree "github.com/pycckuu/merkle-tree-generation/multilevelmktree"
+)
+
+// maxDepth is the depth of the deepest tree, whose leaf indices fit in an int64
+const maxDepth = 62
+
+// nodeBytes is roughly the memory held by a tree node and its value
+const nodeBytes = 160
+
+// Trees with more leaves or needing more memory than this are only built once
+// confirmed
+const (
+	confirmLeaves = 1 << 32
+	confirmMemory = 4 << 30
+)
+
+// checkTiers returns an error unless every leaf index of the tree over the
+// branches of the given tiers, starting at the preimage, fits in an int64
+func checkTiers(hLevel int, branchLevels []int, preImage int) error {
+	if hLevel < 0 {
+		return fmt.Errorf("invalid hLevel %d", hLevel)
+	}
+	if preImage < 0 {
+		return fmt.Errorf("invalid preimage %d", preImage)
+	}
+
+	depth := hLevel
+	for _, level := range branchLevels {
+		if level < 0 {
+			return fmt.Errorf("invalid level %d", level)
+		}
+		if depth += level; depth > maxDepth {
+			return fmt.Errorf("the tree is deeper than %d levels", maxDepth)
+		}
+	}
+
+	// The last leaf is the hash of (preImage + 2^hLevel) * 2^lLevel - 1
+	lLevel := depth - hLevel
+	if preImage > math.MaxInt64>>lLevel-1<<hLevel {
+		return fmt.Errorf("preimage %d is too large for the tree", preImage)
+	}
+
+	return nil
+}
+
+// estimate is the work and memory of building a tree
+type estimate struct {
+	depth  int
+	hashes uint64
+	memory float64
+}
+
+// estimateRun estimates building the branches of the given tiers from
+// branches of branchDepth on the given number of workers, and the tree over
+// them. leafHashes is the number of leaves hashed from their values.
+func estimateRun(hLevel int, branchLevels []int, branchDepth, workers int, leafHashes uint64) estimate {
+	lLevel := sum(branchLevels)
+	depth := hLevel + lLevel
+
+	// Branches are built side by side, each with the workers left over, as
+	// getMerkleRoots does
+	if workers < 1 {
+		workers = 1
+	}
+	outer := workers
+	if outer > 1<<hLevel {
+		outer = 1 << hLevel
+	}
+
+	// The bottom tier of every branch is built from branches of branchDepth,
+	// one per worker at a time, and a tree over their roots
+	last := branchLevels[len(branchLevels)-1]
+	branch := branchDepth
+	if branch > last {
+		branch = last
+	}
+	perBranch := math.Ldexp(1, lLevel-last) + 2*math.Ldexp(1, last-branch) + 2*math.Ldexp(1, branch)*float64(workers/outer)
+
+	return estimate{
+		depth:  depth,
+		hashes: leafHashes + 1<<depth - 1,
+		memory: nodeBytes * (2*math.Ldexp(1, hLevel) + float64(outer)*perBranch),
+	}
+}
+
+// leaves returns the number of leaves of the tree
+func (e estimate) leaves() uint64 {
+	return 1 << e.depth
+}
+
+// large reports whether building the tree needs confirming
+func (e estimate) large() bool {
+	return e.leaves() > confirmLeaves || e.memory > confirmMemory
+}
+
+func (e estimate) String() string {
+	return fmt.Sprintf("%d leaves (2^%d), %d hashes, about %s of memory", e.leaves(), e.depth, e.hashes, bytesString(e.memory))
+}
+
+// bytesString formats a number of bytes with a binary unit
+func bytesString(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
+
+// estimate estimates building the tree of the given tiers with the tree flags
+func (f *treeFlags) estimate(hLevel int, branchLevels []int) estimate {
+	var leafHashes uint64
+	switch {
+	case *f.leaves == "" && !*f.random:
+		leafHashes = 1 << (hLevel + sum(branchLevels))
+	}
+
+	return estimateRun(hLevel, branchLevels, *f.branchDepth, *f.workers, leafHashes)
+}
+
+// confirm prints the estimate of building the tree of the given tiers. Large
+// trees are only built with -yes or once confirmed on a terminal.
+func (f *treeFlags) confirm(hLevel int, branchLevels []int) {
+	e := f.estimate(hLevel, branchLevels)
+
+	if !e.large() || *f.yes {
+		f.printf("Estimate: %s\n", e)
+		return
+	}
+
+	// Stdin cannot be asked when it is not a terminal or holds the leaves
+	stat, err := os.Stdin.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice == 0 || *f.leaves == "-" {
+		log.Fatalf("building %s needs -yes", e)
+	}
+	fmt.Fprintf(os.Stderr, "Building %s. Continue? [y/N] ", e)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		log.Fatalf("building %s needs -yes", e)
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		log.Fatal("aborted")
+	}
+}
+
+// calibrationTime is how long -dryRun builds sample trees for
+const calibrationTime = 500 * time.Millisecond
+
+// calibrate returns the leaves per second of building trees of the given depth
+// with opts one at a time, building them until calibrationTime has passed
+func calibrate(ctx context.Context, depth int, opts []merkletree.Option) (float64, error) {
+	start := time.Now()
+	leaves := 0
+	for time.Since(start) < calibrationTime {
+		if _, err := merkletree.NewMultilevelTree(ctx, []int{depth}, leaves, opts...); err != nil {
+			return 0, err
+		}
+		leaves += 1 << depth
+	}
+
+	return float64(leaves) / time.Since(start).Seconds(), nil
+}
+
+// outputSize returns roughly the size of the output of numBranches branches in
+// the given format
+func outputSize(format string, numBranches int) float64 {
+	// digits is the number of digits of all branch indices
+	digits := 0
+	for power := 1; power <= numBranches; power *= 10 {
+		digits += numBranches - power + 1
+	}
+	if numBranches == 1 {
+		digits = 1
+	}
+
+	// header is roughly the size of the fields other than the branches
+	const header = 256
+	n := float64(numBranches)
+	switch format {
+	case "json":
+		return header + 78*n
+	case "csv":
+		return 11 + 68*n + float64(digits)
+	case "cbor":
+		return header + 34*n
+	case "bin":
+		return 32 * n
+	case "ndjson":
+		return header + 87*n + float64(digits)
+	}
+
+	return 0
+}
+
+// durationString formats seconds as a duration, in years when too long for
+// time.Duration
+func durationString(seconds float64) string {
+	const year = 365 * 24 * 60 * 60
+	if seconds >= 100*year {
+		return fmt.Sprintf("%.0f years", seconds/year)
+	}
+
+	d := time.Duration(seconds * float64(time.Second))
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+
+	return d.Round(time.Second).String()
+}
+
+// dryRun prints the estimate of generating the tree of the given tiers in the
+// given format, with the runtime extrapolated from building a few small trees,
+// without generating it
+func (f *treeFlags) dryRun(ctx context.Context, hLevel int, branchLevels []int, format string, opts []merkletree.Option) {
+	e := f.estimate(hLevel, branchLevels)
+
+	// Sample trees are as deep as a branch of the bottom tier, so they are
+	// built on one goroutine like the branches of a run
+	depth := branchLevels[len(branchLevels)-1]
+	if depth > *f.branchDepth {
+		depth = *f.branchDepth
+	}
+	rate, err := calibrate(ctx, depth, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Branches are built at once on up to as many workers as there are
+	// processors
+	parallel := *f.workers
+	if procs := runtime.GOMAXPROCS(0); parallel > procs {
+		parallel = procs
+	}
+	if parallel > 1<<hLevel {
+		parallel = 1 << hLevel
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	fmt.Printf("Leaves:  %d (2^%d)\n", e.leaves(), e.depth)
+	fmt.Printf("Hashes:  %d with %s\n", e.hashes, *f.hash)
+	fmt.Printf("Memory:  about %s\n", bytesString(e.memory))
+	fmt.Printf("Output:  about %s as %s\n", bytesString(outputSize(format, 1<<hLevel)), format)
+	fmt.Printf("Rate:    %.0f leaves/s per worker, on %d workers\n", rate, parallel)
+	fmt.Printf("Runtime: about %s\n", durationString(float64(e.leaves())/rate/float64(parallel)))
+}
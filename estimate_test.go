@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckTiers(t *testing.T) {
+	cases := []struct {
+		hLevel   int
+		levels   []int
+		preImage int
+		valid    bool
+	}{
+		{4, []int{16}, 0, true},
+		{0, []int{0}, 0, true},
+		{8, []int{8, 8, 12}, 5, true},
+		{30, []int{32}, 0, true},
+		{31, []int{32}, 0, false},
+		{62, []int{}, 0, true},
+		{63, []int{}, 0, false},
+		{2, []int{30, 30, 1}, 0, false},
+		{-1, []int{4}, 0, false},
+		{4, []int{-1}, 0, false},
+		{4, []int{4}, -1, false},
+		// The last leaf is (preImage + 2^hLevel) * 2^lLevel - 1
+		{0, []int{1}, math.MaxInt64>>1 - 1, true},
+		{0, []int{1}, math.MaxInt64 >> 1, false},
+		{4, []int{16}, math.MaxInt64>>16 - 16, true},
+		{4, []int{16}, math.MaxInt64>>16 - 15, false},
+		{0, []int{0}, math.MaxInt64 - 1, true},
+		{0, []int{0}, math.MaxInt64, false},
+	}
+	for _, c := range cases {
+		err := checkTiers(c.hLevel, c.levels, c.preImage)
+		if c.valid && err != nil {
+			t.Error("Expected tiers", c.hLevel, c.levels, c.preImage, "to be valid, got", err)
+		}
+		if !c.valid && err == nil {
+			t.Error("Expected error for tiers", c.hLevel, c.levels, c.preImage, "got nil")
+		}
+	}
+}
+
+func TestEstimateRun(t *testing.T) {
+	e := estimateRun(4, []int{16}, 6, 1, 1<<20)
+	if e.leaves() != 1<<20 || e.hashes != 1<<21-1 {
+		t.Error("Expected 2^20 leaves and 2^21-1 hashes, got", e.leaves(), e.hashes)
+	}
+	if e.large() {
+		t.Error("Expected a tree of 2^20 leaves not to need confirming, got", e)
+	}
+	if large := estimateRun(1, []int{32}, 6, 1, 0); !large.large() {
+		t.Error("Expected a tree of 2^33 leaves to need confirming, got", large)
+	}
+
+	cases := map[float64]string{0: "0.0 B", 1023: "1023.0 B", 1536: "1.5 KiB", 4 << 30: "4.0 GiB"}
+	for bytes, expected := range cases {
+		if s := bytesString(bytes); s != expected {
+			t.Error("Expected", expected, "for", bytes, "bytes, got", s)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+// formats are the values of -format, which are also the extensions of their
+// output files
+var formats = map[string]bool{"json": true, "csv": true, "cbor": true, "bin": true, "ndjson": true}
+
+// encodeOutput encodes output in the given format:
+//
+//	json  the Output struct, indented
+//	csv   an index,root header and one row per branch
+//	cbor  a map with the fields of the JSON output, values as 32-byte strings
+//	bin   the branches as consecutive 32-byte big-endian words
+//
+// ndjson is streamed by ndjsonWriter instead.
+func encodeOutput(output *Output, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(output, "", "    ")
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"index", "root"})
+		for i, branch := range output.Branches {
+			w.Write([]string{strconv.Itoa(i), branch})
+		}
+		w.Flush()
+
+		return buf.Bytes(), w.Error()
+	case "cbor":
+		return encodeCBOR(output)
+	case "bin":
+		buf := make([]byte, 0, 32*len(output.branches))
+		for _, branch := range output.branches {
+			buf = append(buf, word(branch)...)
+		}
+
+		return buf, nil
+	}
+
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// ndjsonBranch is the line of a branch in NDJSON output
+type ndjsonBranch struct {
+	Index int    `json:"index"`
+	Root  string `json:"root"`
+}
+
+// ndjsonRoot is the last line of NDJSON output
+type ndjsonRoot struct {
+	Root string `json:"root"`
+}
+
+// ndjsonWriter streams newline-delimited JSON output: a first line with the
+// fields of the JSON output but the root and branches, a line per branch as it
+// is computed and a last line with the root. Each line is written at once, so
+// readers can follow the output while it is generated.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+// newNDJSONWriter writes the first line of the output to w
+func newNDJSONWriter(w io.Writer, output *Output) (*ndjsonWriter, error) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(output); err != nil {
+		return nil, err
+	}
+
+	return &ndjsonWriter{enc: enc}, nil
+}
+
+// branch writes the line of branch i
+func (w *ndjsonWriter) branch(i int, root *big.Int) error {
+	return w.enc.Encode(ndjsonBranch{Index: i, Root: hexWord(root)})
+}
+
+// root writes the last line
+func (w *ndjsonWriter) root(root *big.Int) error {
+	return w.enc.Encode(ndjsonRoot{Root: hexWord(root)})
+}
+
+// hexWord returns value as the 0x-prefixed 32-byte hex string of the output
+func hexWord(value *big.Int) string {
+	return fmt.Sprintf("0x%064s", value.Text(16))
+}
+
+// word returns value as a 32-byte big-endian word
+func word(value *big.Int) []byte {
+	return value.FillBytes(make([]byte, 32))
+}
+
+// CBOR major types used by encodeCBOR
+const (
+	cborUint     = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborSimple   = 7
+)
+
+// CBOR simple values of cborSimple used by encodeCBOR
+const (
+	cborFalse = 20
+	cborTrue  = 21
+)
+
+// encodeCBOR encodes output as a CBOR map (RFC 8949) with the keys of the JSON
+// output
+func encodeCBOR(output *Output) ([]byte, error) {
+	var buf []byte
+	head := func(major byte, n int) {
+		switch {
+		case n < 24:
+			buf = append(buf, major<<5|byte(n))
+		case n <= 0xff:
+			buf = append(buf, major<<5|24, byte(n))
+		case n <= 0xffff:
+			buf = binary.BigEndian.AppendUint16(append(buf, major<<5|25), uint16(n))
+		case n <= 0xffffffff:
+			buf = binary.BigEndian.AppendUint32(append(buf, major<<5|26), uint32(n))
+		default:
+			buf = binary.BigEndian.AppendUint64(append(buf, major<<5|27), uint64(n))
+		}
+	}
+	text := func(s string) {
+		head(cborText, len(s))
+		buf = append(buf, s...)
+	}
+	integer := func(n int) {
+		if n < 0 {
+			head(cborNegative, -1-n)
+			return
+		}
+		head(cborUint, n)
+	}
+	boolean := func(b bool) {
+		if b {
+			head(cborSimple, cborTrue)
+			return
+		}
+		head(cborSimple, cborFalse)
+	}
+	value := func(v *big.Int) {
+		head(cborBytes, 32)
+		buf = append(buf, word(v)...)
+	}
+
+	fields := 6
+	if len(output.Levels) > 0 {
+		fields++
+	}
+	if output.DomainTag != "" {
+		fields++
+	}
+	if output.Leaves != "" {
+		fields += 3
+	}
+	if output.Column > 0 {
+		fields++
+	}
+	if output.Header {
+		fields++
+	}
+	if output.Seed != nil {
+		fields++
+	}
+	if output.Count > 0 {
+		fields++
+	}
+	head(cborMap, fields)
+
+	text("hLevel")
+	integer(output.HLevel)
+	text("lLevel")
+	integer(output.LLevel)
+	if len(output.Levels) > 0 {
+		text("levels")
+		head(cborArray, len(output.Levels))
+		for _, level := range output.Levels {
+			integer(level)
+		}
+	}
+	text("preimage")
+	integer(output.PreImage)
+	text("hasher")
+	text(output.Hasher)
+	if output.DomainTag != "" {
+		tag, err := parseHex(output.DomainTag)
+		if err != nil {
+			return nil, err
+		}
+		text("domainTag")
+		value(tag)
+	}
+	if output.Leaves != "" {
+		text("leaves")
+		text(output.Leaves)
+		if output.Column > 0 {
+			text("column")
+			integer(output.Column)
+		}
+		text("leafEncoding")
+		text(output.LeafEncoding)
+		text("hashLeaves")
+		boolean(output.HashLeaves)
+		if output.Header {
+			text("header")
+			boolean(true)
+		}
+	}
+	if output.Seed != nil {
+		text("seed")
+		integer(int(*output.Seed))
+	}
+	if output.Count > 0 {
+		text("count")
+		integer(output.Count)
+	}
+	text("root")
+	value(output.root)
+	text("branches")
+	head(cborArray, len(output.branches))
+	for _, branch := range output.branches {
+		value(branch)
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// cborWord is the CBOR byte string of a 32-byte word ending in the given byte
+func cborWord(last string) string {
+	return "5820" + strings.Repeat("00", 31) + last
+}
+
+func TestEncodeCBOR(t *testing.T) {
+	seed := int64(-5)
+
+	cases := []struct {
+		output   Output
+		expected []string
+	}{
+		{
+			Output{HLevel: 1, LLevel: 2, PreImage: -1, Hasher: "sha256", root: big.NewInt(1), branches: []*big.Int{big.NewInt(2)}},
+			[]string{
+				"a6",
+				"66684c6576656c", "01", // hLevel: 1
+				"666c4c6576656c", "02", // lLevel: 2
+				"68707265696d616765", "20", // preimage: -1
+				"66686173686572", "66736861323536", // hasher: sha256
+				"64726f6f74", cborWord("01"), // root
+				"686272616e63686573", "81", cborWord("02"), // branches
+			},
+		},
+		{
+			Output{
+				LLevel: 1, Levels: []int{1}, Hasher: "sha256",
+				Leaves: "a.csv", Column: 2, LeafEncoding: "hex", HashLeaves: true, Header: true,
+				Count: 1000, root: big.NewInt(0),
+			},
+			[]string{
+				"ad",
+				"66684c6576656c", "00", // hLevel: 0
+				"666c4c6576656c", "01", // lLevel: 1
+				"666c6576656c73", "8101", // levels: [1]
+				"68707265696d616765", "00", // preimage: 0
+				"66686173686572", "66736861323536", // hasher: sha256
+				"666c6561766573", "65612e637376", // leaves: a.csv
+				"66636f6c756d6e", "02", // column: 2
+				"6c6c656166456e636f64696e67", "63686578", // leafEncoding: hex
+				"6a686173684c6561766573", "f5", // hashLeaves: true
+				"66686561646572", "f5", // header: true
+				"65636f756e74", "1903e8", // count: 1000
+				"64726f6f74", cborWord("00"), // root
+				"686272616e63686573", "80", // branches
+			},
+		},
+		{
+			Output{
+				HLevel: 24, LLevel: 1, Hasher: "sha256", DomainTag: "0x01", Seed: &seed,
+				root: big.NewInt(3), branches: []*big.Int{},
+			},
+			[]string{
+				"a8",
+				"66684c6576656c", "1818", // hLevel: 24
+				"666c4c6576656c", "01", // lLevel: 1
+				"68707265696d616765", "00", // preimage: 0
+				"66686173686572", "66736861323536", // hasher: sha256
+				"69646f6d61696e546167", cborWord("01"), // domainTag
+				"6473656564", "24", // seed: -5
+				"64726f6f74", cborWord("03"), // root
+				"686272616e63686573", "80", // branches
+			},
+		},
+	}
+
+	for i, c := range cases {
+		expected, err := hex.DecodeString(strings.Join(c.expected, ""))
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		encoded, err := encodeCBOR(&c.output)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if !bytes.Equal(encoded, expected) {
+			t.Errorf("Expected CBOR of case %d to be %x, got %x", i, expected, encoded)
+		}
+	}
+
+	if _, err := encodeCBOR(&Output{DomainTag: "tag", root: big.NewInt(0)}); err == nil {
+		t.Error("Expected error for an invalid domain tag, got nil")
+	}
+}
+
+func TestEncodeOutput(t *testing.T) {
+	output := &Output{
+		Branches: []string{hexWord(big.NewInt(1)), hexWord(big.NewInt(2))},
+		branches: []*big.Int{big.NewInt(1), big.NewInt(2)},
+	}
+
+	csv, err := encodeOutput(output, "csv")
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	expected := "index,root\n0," + hexWord(big.NewInt(1)) + "\n1," + hexWord(big.NewInt(2)) + "\n"
+	if string(csv) != expected {
+		t.Error("Expected CSV", expected, "got", string(csv))
+	}
+
+	bin, err := encodeOutput(output, "bin")
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if expected := append(word(big.NewInt(1)), word(big.NewInt(2))...); !bytes.Equal(bin, expected) {
+		t.Errorf("Expected binary output %x, got %x", expected, bin)
+	}
+
+	if _, err := encodeOutput(output, "xml"); err == nil {
+		t.Error("Expected error for an unknown format, got nil")
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newNDJSONWriter(&buf, &Output{HLevel: 1, LLevel: 2, Hasher: "poseidon"})
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := w.branch(i, big.NewInt(int64(i+1))); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+	if err := w.root(big.NewInt(3)); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	expected := []string{
+		`{"hLevel":1,"lLevel":2,"preimage":0,"hasher":"poseidon"}`,
+		`{"index":0,"root":"` + hexWord(big.NewInt(1)) + `"}`,
+		`{"index":1,"root":"` + hexWord(big.NewInt(2)) + `"}`,
+		`{"root":"` + hexWord(big.NewInt(3)) + `"}`,
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(expected) {
+		t.Fatal("Expected", len(expected), "lines, got", lines)
+	}
+	for i, line := range lines {
+		if line != expected[i] || !json.Valid([]byte(line)) {
+			t.Error("Expected line", i, "to be", expected[i], "got", line)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	merkletree "github.com/pycckuu/merkle-tree-generation/multilevelmktree"
+	"github.com/schollz/progressbar/v3"
+)
+
+type Output struct {
+	HLevel int `json:"hLevel"`
+	LLevel int `json:"lLevel"`
+	// Levels holds the tiers given with -levels, from the top down
+	Levels   []int `json:"levels,omitempty"`
+	PreImage int   `json:"preimage"`
+	// Hasher and DomainTag record how the nodes were hashed, so the root can
+	// be checked
+	Hasher    string `json:"hasher"`
+	DomainTag string `json:"domainTag,omitempty"`
+	// Leaves is the file the leaves were read from, if not hashed preimages,
+	// and the other fields how they were read from it
+	Leaves       string `json:"leaves,omitempty"`
+	Column       int    `json:"column,omitempty"`
+	LeafEncoding string `json:"leafEncoding,omitempty"`
+	HashLeaves   bool   `json:"hashLeaves,omitempty"`
+	Header       bool   `json:"header,omitempty"`
+	// Seed is set for -random leaves, Count to their number unless all leaves
+	// are random
+	Seed  *int64 `json:"seed,omitempty"`
+	Count int    `json:"count,omitempty"`
+	// Root and Branches are left out of the first line of NDJSON output
+	Root     string   `json:"root,omitempty"`
+	Branches []string `json:"branches,omitempty"`
+
+	// root and branches hold the values of Root and Branches once read
+	root     *big.Int
+	branches []*big.Int
+}
+
+// errOutputFormat is the error of reading an output in a format other than
+// json or ndjson, which do not record the parameters of the tree
+var errOutputFormat = errors.New("prove -input needs a JSON or NDJSON output")
+
+// readOutput reads an output written by generate in the json or ndjson format
+func readOutput(path string) (*Output, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := decodeOutput(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	branchLevels := []int{output.LLevel}
+	if len(output.Levels) > 0 {
+		branchLevels = output.Levels[1:]
+	}
+	if err := checkTiers(output.HLevel, branchLevels, output.PreImage); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(output.Levels) == 1 || len(output.Branches) != 1<<output.HLevel {
+		return nil, fmt.Errorf("reading %s: levels do not match the branches", path)
+	}
+
+	if output.root, err = parseHex(output.Root); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	output.branches = make([]*big.Int, len(output.Branches))
+	for i, branch := range output.Branches {
+		if output.branches[i], err = parseHex(branch); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+
+	return output, nil
+}
+
+// decodeOutput decodes a JSON output, or an NDJSON output with the roots of its
+// branch lines gathered into Branches
+func decodeOutput(data []byte) (*Output, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil, errOutputFormat
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var output Output
+	if err := dec.Decode(&output); err != nil {
+		return nil, fmt.Errorf("%w: %v", errOutputFormat, err)
+	}
+	if !dec.More() {
+		return &output, nil
+	}
+
+	for dec.More() {
+		if output.Root != "" {
+			return nil, errors.New("lines after the root")
+		}
+
+		var line struct {
+			Index *int   `json:"index"`
+			Root  string `json:"root"`
+		}
+		if err := dec.Decode(&line); err != nil {
+			return nil, err
+		}
+		switch {
+		case line.Index == nil:
+			output.Root = line.Root
+		case *line.Index != len(output.Branches):
+			return nil, fmt.Errorf("branch %d out of order", *line.Index)
+		default:
+			output.Branches = append(output.Branches, line.Root)
+		}
+	}
+	if output.Root == "" {
+		return nil, errors.New("the output ends before the root, it was not completed")
+	}
+
+	return &output, nil
+}
+
+// parseHex parses a 0x-prefixed hex value as written in the output
+func parseHex(s string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+	if !ok || !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("invalid hex value %q", s)
+	}
+
+	return value, nil
+}
+
+// branchBuild describes the branches to build
+type branchBuild struct {
+	hLevel int
+	// branchLevels holds the depths of the tiers every branch is built in, from
+	// the top down
+	branchLevels []int
+	preImage     int
+	workers      int
+	// progress shows a progress bar on stderr
+	progress bool
+	opts     []merkletree.Option
+	// resume holds the leading branches of an earlier run, which are not built
+	// again
+	resume []*big.Int
+	// onRoot is called with every branch root in index order when set,
+	// including the resumed ones
+	onRoot func(i int, root *big.Int) error
+}
+
+// getMerkleRoots computes the Merkle tree roots for each branch, stopping early
+// once ctx is done. Up to b.workers goroutines run at once: branches are built
+// side by side, and each branch gets the workers left over when there are
+// fewer branches than workers. Branches are handed out in order and no more are
+// started once one fails, returning the error of the leftmost failing branch.
+func getMerkleRoots(ctx context.Context, b branchBuild) ([]*big.Int, error) {
+	lLevel := sum(b.branchLevels)
+	n := 1 << b.hLevel
+	increment := 1 << lLevel
+	branches := make([]*big.Int, n)
+	resumed := copy(branches, b.resume)
+
+	bar := progressbar.DefaultSilent(int64(n))
+	if b.progress {
+		bar = progressbar.Default(int64(n))
+	}
+	bar.Add(resumed)
+
+	workers := b.workers
+	if workers < 1 {
+		workers = 1
+	}
+	outer := workers
+	if remaining := n - resumed; outer > remaining && remaining > 0 {
+		outer = remaining
+	}
+	opts := append(b.opts[:len(b.opts):len(b.opts)], merkletree.WithWorkers(workers/outer))
+
+	// emitted is the number of leading branches passed to onRoot
+	var mu sync.Mutex
+	emitted := 0
+	// advance passes the completed branches after the emitted ones to onRoot
+	advance := func() error {
+		for ; emitted < n && branches[emitted] != nil; emitted++ {
+			if b.onRoot == nil {
+				continue
+			}
+			if err := b.onRoot(emitted, branches[emitted]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+	if err := advance(); err != nil {
+		return nil, err
+	}
+
+	build := func(j int) error {
+		i := resumed + j
+		merkleTree, err := merkletree.NewMultilevelTree(ctx, b.branchLevels, (i+b.preImage)*increment, opts...)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		branches[i] = merkleTree.Root()
+		if err := advance(); err != nil {
+			return err
+		}
+		bar.Add(1)
+
+		return nil
+	}
+	if err := runParallel(n-resumed, outer, build); err != nil {
+		return nil, err
+	}
+
+	return branches, nil
+}
+
+// runParallel calls run for every i below n on the given number of goroutines.
+// Indices are handed out in order and none are started once a call fails,
+// returning the error of the leftmost failing index.
+func runParallel(n, workers int, run func(i int) error) error {
+	var mu sync.Mutex
+	next, failed := 0, n
+	var err error
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				i := next
+				if i >= n || failed < n {
+					mu.Unlock()
+					return
+				}
+				next++
+				mu.Unlock()
+
+				if runErr := run(i); runErr != nil {
+					mu.Lock()
+					if i < failed {
+						failed, err = i, runErr
+					}
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return err
+}
+
+// writeOutput fills in the branches and root of output, then encodes it in the
+// given format and writes it to path, creating its directory. Without a path it
+// is written to a file named after the parameters, and "-" writes to stdout.
+// It returns the file the output was written to, empty for stdout.
+func writeOutput(output *Output, branches []*big.Int, root *big.Int, format, path string) string {
+	output.branches, output.root = branches, root
+	output.Branches = make([]string, len(branches))
+	for i, branch := range branches {
+		output.Branches[i] = hexWord(branch)
+	}
+	output.Root = hexWord(root)
+
+	data, err := encodeOutput(output, format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// End text output with a newline for the terminal
+	if path == "-" && format == "json" {
+		data = append(data, '\n')
+	}
+
+	path = outputPath(output, format, path)
+	file := createOutput(path)
+	if _, err := file.Write(data); err != nil {
+		log.Fatalf("error writing output: %v", err)
+	}
+	closeOutput(file)
+
+	return path
+}
+
+// outputPath returns the file written for -output=path, empty for stdout
+func outputPath(output *Output, format, path string) string {
+	switch path {
+	case "-":
+		return ""
+	case "":
+		return fmt.Sprintf("output_hLevel_%d_lLevel_%d_preImage_%d.%s", output.HLevel, output.LLevel, output.PreImage, format)
+	}
+
+	return path
+}
+
+// createOutput creates the file at path and its directory, returning stdout for
+// an empty path
+func createOutput(path string) *os.File {
+	if path == "" {
+		return os.Stdout
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Fatalf("error creating output directory: %v", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			log.Fatalf("no permission to write %s, choose another path with -output", path)
+		}
+		log.Fatalf("error writing to file: %v", err)
+	}
+
+	return file
+}
+
+// closeOutput closes a file returned by createOutput, leaving stdout open
+func closeOutput(file *os.File) {
+	if file == os.Stdout {
+		return
+	}
+	if err := file.Close(); err != nil {
+		log.Fatalf("error writing to file: %v", err)
+	}
+}
+
+// generate builds the branches and the tree over them, writing them to the file
+// given with -output or to stdout
+func generate(args []string) {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	treeFlags := addTreeFlags(flags)
+	saveTreePtr := flags.String("saveTree", "", "A file to save the tree over the branches to, as JSON for .json, gob for .gob and binary otherwise")
+	formatPtr := flags.String("format", "json", "The output format, one of json, csv, cbor, bin and ndjson")
+	outputPtr := flags.String("output", "", "The file to write the output to, - for stdout, output_hLevel_<h>_lLevel_<l>_preImage_<p>.<format> by default")
+	checkpointPtr := flags.String("checkpoint", "", "A file to save the branches built so far to, resuming from it if it exists")
+	dryRunPtr := flags.Bool("dryRun", false, "Print the hashes, memory, output size and runtime of the run, estimated from a short calibration, without generating anything")
+	parseFlags(flags, args, true)
+
+	if !formats[*formatPtr] {
+		log.Fatal("unknown format: ", *formatPtr)
+	}
+
+	hLevel, branchLevels, levels := treeFlags.tiers()
+	lLevel := sum(branchLevels)
+	preImage := *treeFlags.preImage
+	treeFlags.checkLeaves(hLevel+lLevel, preImage)
+	opts := treeFlags.options()
+
+	ctx, cancel := treeFlags.context()
+	defer cancel()
+
+	if *dryRunPtr {
+		treeFlags.dryRun(ctx, hLevel, branchLevels, *formatPtr, opts)
+		return
+	}
+	treeFlags.confirm(hLevel, branchLevels)
+
+	output := &Output{
+		HLevel:   hLevel,
+		LLevel:   lLevel,
+		Levels:   levels,
+		PreImage: preImage,
+		Hasher:   *treeFlags.hash,
+	}
+	if *treeFlags.leaves != "" {
+		output.Leaves, output.Column = *treeFlags.leaves, *treeFlags.column
+		output.LeafEncoding, output.HashLeaves = *treeFlags.leafEncoding, *treeFlags.hashLeaves
+		output.Header = *treeFlags.header
+	}
+	if *treeFlags.random {
+		output.Seed, output.Count = treeFlags.seed, *treeFlags.count
+	}
+	if tag := treeFlags.tag(); tag != nil {
+		output.DomainTag = hexWord(tag)
+	}
+
+	build := treeFlags.branchBuild(hLevel, branchLevels, preImage, opts)
+
+	var saved *checkpoint
+	if *checkpointPtr != "" {
+		var err error
+		if saved, err = loadCheckpoint(*checkpointPtr, output, leafDigest(treeFlags.leafValues())); err != nil {
+			log.Fatal(err)
+		}
+		if len(saved.branches) > 0 {
+			treeFlags.printf("Resuming from %d of %d branches in %s\n", len(saved.branches), 1<<hLevel, *checkpointPtr)
+		}
+		build.resume = saved.branches
+	}
+
+	// NDJSON is written while the branches are built
+	var stream *ndjsonWriter
+	var file *os.File
+	written := ""
+	if *formatPtr == "ndjson" {
+		written = outputPath(output, *formatPtr, *outputPtr)
+		file = createOutput(written)
+		var err error
+		if stream, err = newNDJSONWriter(file, output); err != nil {
+			log.Fatalf("error writing output: %v", err)
+		}
+	}
+
+	build.onRoot = func(i int, root *big.Int) error {
+		if stream != nil {
+			if err := stream.branch(i, root); err != nil {
+				return err
+			}
+		}
+		if saved != nil {
+			return saved.add(i, root)
+		}
+
+		return nil
+	}
+
+	branches, err := getMerkleRoots(ctx, build)
+	if err != nil {
+		// Keep what was built for the next run
+		if saved != nil {
+			if err := saved.save(); err != nil {
+				log.Print(err)
+			} else {
+				treeFlags.printf("Checkpoint written to %s, run again with the same flags to resume\n", *checkpointPtr)
+			}
+		}
+		log.Fatal(err)
+	}
+	tree := merkletree.NewMerkleTreeWithLeaves(branches, opts...)
+
+	if stream != nil {
+		if err := stream.root(tree.Root.Data); err != nil {
+			log.Fatalf("error writing output: %v", err)
+		}
+		closeOutput(file)
+	} else {
+		written = writeOutput(output, branches, tree.Root.Data, *formatPtr, *outputPtr)
+	}
+	if written != "" {
+		treeFlags.printf("Output written to %s\n", written)
+	}
+	if saved != nil {
+		if err := saved.remove(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *saveTreePtr != "" {
+		if err := tree.SaveToFile(*saveTreePtr); err != nil {
+			log.Fatal(err)
+		}
+		treeFlags.printf("Tree written to %s\n", *saveTreePtr)
+	}
+}
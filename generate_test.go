@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestOutputPath(t *testing.T) {
+	output := &Output{HLevel: 2, LLevel: 3, PreImage: 5}
+
+	cases := []struct {
+		format   string
+		path     string
+		expected string
+	}{
+		{"json", "", "output_hLevel_2_lLevel_3_preImage_5.json"},
+		{"cbor", "", "output_hLevel_2_lLevel_3_preImage_5.cbor"},
+		{"json", "-", ""},
+		{"csv", "out/roots.csv", "out/roots.csv"},
+	}
+	for _, c := range cases {
+		if path := outputPath(output, c.format, c.path); path != c.expected {
+			t.Error("Expected path", c.expected, "for", c.format, c.path, "got", path)
+		}
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "dir", "output.bin")
+	output := &Output{HLevel: 1, LLevel: 1, Hasher: "poseidon"}
+	branches := []*big.Int{big.NewInt(1), big.NewInt(2)}
+
+	if written := writeOutput(output, branches, big.NewInt(3), "bin", path); written != path {
+		t.Error("Expected output written to", path, "got", written)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if len(data) != 64 || data[31] != 1 || data[63] != 2 {
+		t.Errorf("Expected the two branches as words, got %x", data)
+	}
+	if output.Root != hexWord(big.NewInt(3)) || len(output.Branches) != 2 {
+		t.Error("Expected the root and branches filled in, got", output.Root, output.Branches)
+	}
+}
+
+func TestRunParallel(t *testing.T) {
+	for _, workers := range []int{1, 3, 100} {
+		var mu sync.Mutex
+		seen := make([]bool, 50)
+		running, most := 0, 0
+		err := runParallel(len(seen), workers, func(i int) error {
+			mu.Lock()
+			seen[i] = true
+			if running++; running > most {
+				most = running
+			}
+			mu.Unlock()
+
+			runtime.Gosched()
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			return nil
+		})
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		for i, ok := range seen {
+			if !ok {
+				t.Fatal("Expected index", i, "to run with", workers, "workers")
+			}
+		}
+		if most > workers {
+			t.Error("Expected at most", workers, "calls at once, got", most)
+		}
+	}
+
+	for _, workers := range []int{1, 4} {
+		err := runParallel(20, workers, func(i int) error {
+			if i == 7 || i == 12 {
+				return fmt.Errorf("index %d", i)
+			}
+
+			return nil
+		})
+		if err == nil || err.Error() != "index 7" {
+			t.Error("Expected the error of index 7 with", workers, "workers, got", err)
+		}
+	}
+}
+
+func TestDecodeOutput(t *testing.T) {
+	root, branch := hexWord(big.NewInt(3)), hexWord(big.NewInt(1))
+	header := `{"hLevel":0,"lLevel":2,"preimage":0,"hasher":"poseidon"}`
+
+	cases := []string{
+		`{"hLevel":0,"lLevel":2,"preimage":0,"hasher":"poseidon","root":"` + root + `","branches":["` + branch + `"]}`,
+		header + "\n" + `{"index":0,"root":"` + branch + `"}` + "\n" + `{"root":"` + root + `"}` + "\n",
+	}
+	for _, data := range cases {
+		output, err := decodeOutput([]byte(data))
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if output.LLevel != 2 || output.Root != root || len(output.Branches) != 1 || output.Branches[0] != branch {
+			t.Error("Expected the output of", data, "got", output)
+		}
+	}
+
+	invalid := []string{
+		"index,root\n0," + branch + "\n",
+		"\xa6\x66hLevel",
+		"",
+		header + "\n" + `{"index":1,"root":"` + branch + `"}` + "\n" + `{"root":"` + root + `"}` + "\n",
+		header + "\n" + `{"index":0,"root":"` + branch + `"}` + "\n",
+		header + "\n" + `{"root":"` + root + `"}` + "\n" + `{"index":0,"root":"` + branch + `"}` + "\n",
+	}
+	for _, data := range invalid {
+		if _, err := decodeOutput([]byte(data)); err == nil {
+			t.Errorf("Expected error for output %q, got nil", data)
+		}
+	}
+	if _, err := decodeOutput([]byte(invalid[0])); !errors.Is(err, errOutputFormat) {
+		t.Error("Expected errOutputFormat for CSV output, got", err)
+	}
+}
@@ -5,9 +5,13 @@ go 1.20
 require (
 	github.com/iden3/go-iden3-crypto v0.0.15
 	github.com/schollz/progressbar/v3 v3.13.1
+	golang.org/x/crypto v0.7.0
+	gopkg.in/yaml.v3 v3.0.1
+	lukechampine.com/blake3 v1.1.7
 )
 
 require (
+	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
 	github.com/mattn/go-runewidth v0.0.14 // indirect
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// leafEncodings are the values of -leafEncoding
+var leafEncodings = map[string]bool{"auto": true, "hex": true, "decimal": true}
+
+// parseLeaf parses a leaf in the given encoding: auto takes 0x-prefixed values
+// as hex and others as decimal, hex takes values with or without 0x and
+// decimal takes only decimal values
+func parseLeaf(text, encoding string) (*big.Int, error) {
+	hex := encoding == "hex" || encoding == "auto" && strings.HasPrefix(text, "0x")
+
+	leaf, ok := new(big.Int).SetString(text, 10)
+	if hex {
+		leaf, ok = new(big.Int).SetString(strings.TrimPrefix(text, "0x"), 16)
+	}
+	if !ok || leaf.Sign() < 0 {
+		return nil, fmt.Errorf("invalid %s leaf %q", encoding, text)
+	}
+
+	return leaf, nil
+}
+
+// leafFormat describes how leaves are read
+type leafFormat struct {
+	// column is the CSV column holding the leaves, counted from 1, or 0 for one
+	// leaf per line
+	column int
+	// encoding is one of leafEncodings
+	encoding string
+	// header skips the first row or line
+	header bool
+}
+
+// readLeaves reads one leaf per line, skipping blank lines. With a column, the
+// input is read as CSV and leaves are taken from that column.
+func readLeaves(r io.Reader, format leafFormat) ([]*big.Int, error) {
+	if format.column > 0 {
+		return readCSVLeaves(r, format)
+	}
+
+	var leaves []*big.Int
+	scanner := bufio.NewScanner(r)
+	header := format.header
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if header {
+			header = false
+			continue
+		}
+
+		leaf, err := parseLeaf(text, format.encoding)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		leaves = append(leaves, leaf)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return leaves, nil
+}
+
+// readCSVLeaves reads the leaves in a column of CSV input, as readLeaves
+func readCSVLeaves(r io.Reader, format leafFormat) ([]*big.Int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var leaves []*big.Int
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if row == 1 && format.header {
+			continue
+		}
+		if format.column > len(record) {
+			return nil, fmt.Errorf("row %d: no column %d", row, format.column)
+		}
+
+		leaf, err := parseLeaf(strings.TrimSpace(record[format.column-1]), format.encoding)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	return leaves, nil
+}
+
+// leafValues returns the leaves of the -leaves file, or of stdin for "-",
+// read on the first call, or nil without it
+func (f *treeFlags) leafValues() []*big.Int {
+	if *f.leaves == "" || f.leafCache != nil {
+		return f.leafCache
+	}
+
+	name, file := "stdin", os.Stdin
+	if *f.leaves != "-" {
+		var err error
+		if file, err = os.Open(*f.leaves); err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		name = *f.leaves
+	}
+
+	if !leafEncodings[*f.leafEncoding] {
+		log.Fatal("unknown leaf encoding: ", *f.leafEncoding)
+	}
+	leaves, err := readLeaves(file, leafFormat{column: *f.column, encoding: *f.leafEncoding, header: *f.header})
+	if err != nil {
+		log.Fatalf("reading %s: %v", name, err)
+	}
+	if len(leaves) == 0 {
+		log.Fatalf("reading %s: no leaves", name)
+	}
+	f.leafCache = leaves
+
+	return leaves
+}
+
+// hashLeaf returns the leaf of a raw value with -hashLeaves, the keccak256 hash
+// of the value as a 32-byte big-endian word shifted right by 8 bits. Values of
+// any size up to 256 bits, including those above the modulus of the field
+// hashers, give leaves inside every field.
+func hashLeaf(value *big.Int) (*big.Int, error) {
+	if value.BitLen() > 256 {
+		return nil, fmt.Errorf("value %v does not fit in 32 bytes", value)
+	}
+
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(word(value))
+
+	return new(big.Int).Rsh(new(big.Int).SetBytes(hash.Sum(nil)), 8), nil
+}
+
+// randomLeaf returns the pseudorandom leaf at index for seed, the first 31
+// bytes of the SHA-256 hash of both. It is below the modulus of every field
+// hasher and does not depend on the order leaves are generated in.
+func randomLeaf(seed int64, index int) *big.Int {
+	var input [16]byte
+	binary.BigEndian.PutUint64(input[:8], uint64(seed))
+	binary.BigEndian.PutUint64(input[8:], uint64(index))
+	sum := sha256.Sum256(input[:])
+
+	return new(big.Int).SetBytes(sum[:31])
+}
+
+// checkLeaves exits if the -leaves file or -count random leaves do not fit a
+// tree of the given depth, or are combined with a preimage
+func (f *treeFlags) checkLeaves(depth, preImage int) {
+	if *f.random && *f.leaves != "" {
+		log.Fatal("-random cannot be used with -leaves")
+	}
+	if *f.count != 0 && !*f.random {
+		log.Fatal("-count needs -random")
+	}
+
+	var count int
+	switch {
+	case *f.random:
+		if *f.count < 0 {
+			log.Fatal("invalid count: ", *f.count)
+		}
+		count = *f.count
+	case *f.leaves != "":
+		count = len(f.leafValues())
+	default:
+		return
+	}
+
+	if preImage != 0 {
+		log.Fatal("-preImage cannot be used with -leaves or -random")
+	}
+	if numLeaves := 1 << depth; count > numLeaves {
+		log.Fatalf("%d leaves do not fit a tree of depth %d with %d leaves", count, depth, numLeaves)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadLeaves(t *testing.T) {
+	cases := []struct {
+		input    string
+		format   leafFormat
+		expected []int64
+	}{
+		{"1\n0x10\n\n  3  \n", leafFormat{encoding: "auto"}, []int64{1, 16, 3}},
+		{"10\nff\n", leafFormat{encoding: "hex"}, []int64{16, 255}},
+		{"0x10\n", leafFormat{encoding: "hex"}, []int64{16}},
+		{"12\n", leafFormat{encoding: "decimal"}, []int64{12}},
+		{"\n\n", leafFormat{encoding: "auto"}, nil},
+		{"value\n\n5\n", leafFormat{encoding: "auto", header: true}, []int64{5}},
+		{"name,value\nalice,1\nbob,0x2\n", leafFormat{column: 2, encoding: "auto", header: true}, []int64{1, 2}},
+		{"alice,1\nbob,2,extra\n", leafFormat{column: 2, encoding: "decimal"}, []int64{1, 2}},
+		{"\"a,b\", 7 \n", leafFormat{column: 2, encoding: "auto"}, []int64{7}},
+		{"name\n", leafFormat{column: 3, encoding: "auto", header: true}, nil},
+	}
+	for _, c := range cases {
+		leaves, err := readLeaves(strings.NewReader(c.input), c.format)
+		if err != nil {
+			t.Fatal("Unexpected error for", c.input, err)
+		}
+		if len(leaves) != len(c.expected) {
+			t.Fatal("Expected", len(c.expected), "leaves for", c.input, "got", len(leaves))
+		}
+		for i, leaf := range leaves {
+			if leaf.Cmp(big.NewInt(c.expected[i])) != 0 {
+				t.Error("Expected leaf", i, "of", c.input, "to be", c.expected[i], "got", leaf)
+			}
+		}
+	}
+}
+
+func TestReadLeavesErrors(t *testing.T) {
+	cases := []struct {
+		input  string
+		format leafFormat
+		err    string
+	}{
+		{"1\nx\n", leafFormat{encoding: "auto"}, "line 2"},
+		{"-1\n", leafFormat{encoding: "auto"}, "line 1"},
+		{"0x10\n", leafFormat{encoding: "decimal"}, "line 1"},
+		{"ff\n", leafFormat{encoding: "auto"}, "line 1"},
+		{"value\n1\n", leafFormat{encoding: "auto"}, "line 1"},
+		{"name,value\nalice,1\n", leafFormat{column: 2, encoding: "auto"}, "row 1"},
+		{"name,value\nalice\n", leafFormat{column: 2, encoding: "auto", header: true}, "row 2: no column 2"},
+		{"name,value\nalice,bob\n", leafFormat{column: 2, encoding: "auto", header: true}, "row 2"},
+		{"\"a,1\n", leafFormat{column: 1, encoding: "auto"}, "quote"},
+	}
+	for _, c := range cases {
+		_, err := readLeaves(strings.NewReader(c.input), c.format)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Error("Expected error with", c.err, "for", c.input, "got", err)
+		}
+	}
+}
+
+// testTreeFlags returns the tree flags parsed from args
+func testTreeFlags(t *testing.T, args ...string) *treeFlags {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	treeFlags := addTreeFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	return treeFlags
+}
+
+func TestLeafValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leaves.txt")
+	if err := os.WriteFile(path, []byte("1\n2\n0x03\n"), 0o644); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	f := testTreeFlags(t, "-leaves", path)
+	leaves := f.leafValues()
+	if len(leaves) != 3 || leaves[2].Int64() != 3 {
+		t.Fatal("Expected leaves 1, 2 and 3, got", leaves)
+	}
+
+	// Leaves are read once
+	if err := os.Remove(path); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if again := f.leafValues(); len(again) != 3 {
+		t.Error("Expected the 3 leaves read before, got", again)
+	}
+
+	if leaves := testTreeFlags(t).leafValues(); leaves != nil {
+		t.Error("Expected no leaves without -leaves, got", leaves)
+	}
+}
+
+func TestLeafValuesStdin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte("7\n\n0x08\n"), 0o644); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	stdin, err := os.Open(path)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	defer stdin.Close()
+	defer func(saved *os.File) { os.Stdin = saved }(os.Stdin)
+	os.Stdin = stdin
+
+	leaves := testTreeFlags(t, "-leaves", "-").leafValues()
+	if len(leaves) != 2 || leaves[0].Int64() != 7 || leaves[1].Int64() != 8 {
+		t.Error("Expected leaves 7 and 8 from stdin, got", leaves)
+	}
+}
+
+func TestHashLeaf(t *testing.T) {
+	// keccak256 of the zero word is
+	// 0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563
+	expected, _ := new(big.Int).SetString("290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e5", 16)
+	leaf, err := hashLeaf(big.NewInt(0))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if leaf.Cmp(expected) != 0 {
+		t.Error("Expected leaf", expected, "got", leaf)
+	}
+
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	if leaf, err := hashLeaf(max); err != nil || leaf.BitLen() > 248 {
+		t.Error("Expected a 248-bit leaf for the largest value, got", leaf, err)
+	}
+	if _, err := hashLeaf(new(big.Int).Lsh(big.NewInt(1), 256)); err == nil {
+		t.Error("Expected error for a value over 32 bytes, got nil")
+	}
+}
+
+func TestRandomLeaf(t *testing.T) {
+	// The first 31 bytes of SHA-256 of 16 zero bytes
+	expected, _ := new(big.Int).SetString("374708fff7719dd5979ec875d56cd2286f6d3cf7ec317a3b25632aab28ec37", 16)
+	if leaf := randomLeaf(0, 0); leaf.Cmp(expected) != 0 {
+		t.Error("Expected leaf", expected, "got", leaf)
+	}
+
+	seen := map[string]bool{}
+	for seed := int64(-1); seed <= 1; seed++ {
+		for index := 0; index < 4; index++ {
+			leaf := randomLeaf(seed, index)
+			if leaf.Cmp(randomLeaf(seed, index)) != 0 {
+				t.Error("Expected the same leaf for seed", seed, "and index", index)
+			}
+			if leaf.BitLen() > 248 || seen[leaf.String()] {
+				t.Error("Expected a new 248-bit leaf for seed", seed, "and index", index, "got", leaf)
+			}
+			seen[leaf.String()] = true
+		}
+	}
+}
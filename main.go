@@ -1,106 +1,273 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
 	"log"
-	"math"
 	"math/big"
 	"os"
-	"sync"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	merkletree "github.com/pycckuu/merkle-tree-generation/multilevelmktree"
-	"github.com/schollz/progressbar/v3"
 )
 
-type Output struct {
-	HLevel   int      `json:"hLevel"`
-	LLevel   int      `json:"lLevel"`
-	PreImage int      `json:"preimage"`
-	Root     string   `json:"root"`
-	Branches []string `json:"branches"`
-}
+// treeFlags holds the flags describing the generated tree, shared by the
+// subcommands that build it
+type treeFlags struct {
+	hLevel       *int
+	lLevel       *int
+	preImage     *int
+	levels       *string
+	hash         *string
+	domainTag    *string
+	branchDepth  *int
+	timeout      *time.Duration
+	workers      *int
+	quiet        *bool
+	noProgress   *bool
+	leaves       *string
+	column       *int
+	leafEncoding *string
+	hashLeaves   *bool
+	header       *bool
+	random       *bool
+	seed         *int64
+	count        *int
+	yes          *bool
+	config       *string
 
-// getMerkleRoots computes the Merkle tree roots for each branch concurrently
-func getMerkleRoots(hLevel, lLevel int, preImage int) []*big.Int {
-	n := int(math.Pow(2, float64(hLevel)))
-	increment := int(math.Pow(2, float64(lLevel)))
-	branches := make([]*big.Int, n)
+	// leafCache holds the leaves of the -leaves file once read
+	leafCache []*big.Int
+}
 
-	bar := progressbar.Default(int64(n))
+// addTreeFlags defines the tree flags on flags
+func addTreeFlags(flags *flag.FlagSet) *treeFlags {
+	return &treeFlags{
+		hLevel:       flags.Int("hLevel", 4, "An integer value for the hLevel"),
+		lLevel:       flags.Int("lLevel", 16, "An integer value for the lLevel"),
+		preImage:     flags.Int("preImage", 0, "An integer value for the preimage"),
+		levels:       flags.String("levels", "", "Comma-separated depths of the trees on each tier from the top down, e.g. 8,8,12, overriding hLevel and lLevel"),
+		hash:         flags.String("hash", merkletree.Poseidon.Name(), fmt.Sprintf("The hash function to use, one of %v", merkletree.HasherNames())),
+		domainTag:    flags.String("domainTag", "", "A tag hashed into every internal node, decimal or 0x-prefixed hex, none by default"),
+		branchDepth:  flags.Int("branchDepth", merkletree.DefaultBranchDepth, "The depth of the branches each lLevel tree is built from, trading memory for fewer, larger trees"),
+		timeout:      flags.Duration("timeout", 0, "Stop generating after this long, e.g. 10m, no limit by default"),
+		workers:      flags.Int("workers", runtime.NumCPU(), "The number of branches built at once"),
+		quiet:        flags.Bool("quiet", false, "Print only the JSON output, without the progress bar or messages"),
+		noProgress:   flags.Bool("noProgress", false, "Hide the progress bar"),
+		leaves:       flags.String("leaves", "", "A file of leaves, one decimal or 0x-prefixed hex value per line, used instead of hashing the preimages, - for stdin"),
+		column:       flags.Int("column", 0, "Read -leaves as CSV and take the leaves from this column, counted from 1"),
+		leafEncoding: flags.String("leafEncoding", "auto", "The encoding of the values of -leaves, hex, decimal or auto for hex with a 0x prefix and decimal otherwise"),
+		hashLeaves:   flags.Bool("hashLeaves", false, "Hash every value of -leaves into its leaf, as keccak256 of the value shifted right by 8 bits, instead of using it as it is"),
+		header:       flags.Bool("header", false, "Skip the first row or line of -leaves as a header"),
+		random:       flags.Bool("random", false, "Use pseudorandom field elements as the leaves instead of hashing the preimages"),
+		seed:         flags.Int64("seed", 0, "The seed of the -random leaves, recorded in the output"),
+		count:        flags.Int("count", 0, "The number of -random leaves, the rest being empty, all leaves by default"),
+		yes:          flags.Bool("yes", false, "Build large trees without asking for confirmation"),
+		config:       flags.String("config", "", "A YAML file of flag values keyed by flag name, overridden by the flags given"),
+	}
+}
 
-	var wg sync.WaitGroup
-	wg.Add(n)
+// tiers returns the depth of the tree over the branches and the depths of the
+// tiers each branch is built from, along with the tiers given with -levels
+func (f *treeFlags) tiers() (int, []int, []int) {
+	if *f.levels == "" {
+		if err := checkTiers(*f.hLevel, []int{*f.lLevel}, *f.preImage); err != nil {
+			log.Fatal(err)
+		}
 
-	for i := 0; i < n; i++ {
-		go func(i int) {
-			defer wg.Done()
-			merkleTree := merkletree.NewDeterministicMerkleTree(lLevel, (i+preImage)*increment)
-			branches[i] = merkleTree.Root.Data
-			bar.Add(1)
-		}(i)
+		return *f.hLevel, []int{*f.lLevel}, nil
 	}
 
-	wg.Wait()
+	var levels []int
+	for _, field := range strings.Split(*f.levels, ",") {
+		level, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || level < 0 {
+			log.Fatal("invalid levels: ", *f.levels)
+		}
+		levels = append(levels, level)
+	}
+	if len(levels) < 2 {
+		log.Fatal("levels needs at least two tiers: ", *f.levels)
+	}
+	if err := checkTiers(levels[0], levels[1:], *f.preImage); err != nil {
+		log.Fatal(err)
+	}
 
-	return branches
+	// The top tier is the tree over the branches, the rest build each branch
+	return levels[0], levels[1:], levels
 }
 
-// outputJSON formats the output as JSON and prints to stdout
-func outputJSON(branches []*big.Int, root *big.Int, hLevel, lLevel int, preImage int) {
-	branchesHex := make([]string, len(branches))
-	for i, branch := range branches {
-		branchesHex[i] = fmt.Sprintf("0x%064s", branch.Text(16))
+// options returns the options selected by the hash, domain tag, branch depth,
+// leaves and random flags. With -hashLeaves the leaves are hashed here, once.
+func (f *treeFlags) options() []merkletree.Option {
+	hasher, err := merkletree.HasherByName(*f.hash)
+	if err != nil {
+		log.Fatal(err)
 	}
-	rootHex := fmt.Sprintf("0x%064s", root.Text(16))
 
-	output := Output{
-		Branches: branchesHex,
-		HLevel:   hLevel,
-		PreImage: preImage,
-		Root:     rootHex,
-		LLevel:   lLevel,
+	opts := []merkletree.Option{merkletree.WithHasher(hasher), merkletree.WithBranchDepth(*f.branchDepth)}
+	if tag := f.tag(); tag != nil {
+		opts = append(opts, merkletree.WithDomainTag(tag))
 	}
 
-	outputJSON, err := json.MarshalIndent(output, "", "    ")
-	if err != nil {
-		fmt.Println("error:", err)
+	leaves := f.leafValues()
+	if leaves == nil && !*f.random {
+		return opts
 	}
-	fmt.Printf("%s\n", outputJSON)
 
-	// Open output file
-	fileName := fmt.Sprintf("output_hLevel_%d_lLevel_%d_preImage_%d.json", hLevel, lLevel, preImage)
-	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, 0o755)
+	// Leaves past the end of the file or -count are empty, the hash of zero
+	// like PadZeroHash
+	zero, err := hasher.Hash([]*big.Int{big.NewInt(0)})
 	if err != nil {
-		log.Fatalf("error opening file: %v", err)
+		log.Fatal(err)
 	}
-	defer file.Close()
+	if *f.random {
+		seed, count := *f.seed, *f.count
 
-	// Write JSON data to the file
-	_, err = file.Write(outputJSON)
-	if err != nil {
-		log.Fatalf("error writing to file: %v", err)
+		return append(opts, merkletree.WithLeafGenerator(func(index int) *big.Int {
+			if count > 0 && index >= count {
+				return zero
+			}
+
+			return randomLeaf(seed, index)
+		}))
+	}
+
+	if *f.hashLeaves {
+		hashed := make([]*big.Int, len(leaves))
+		for i, value := range leaves {
+			if hashed[i], err = hashLeaf(value); err != nil {
+				log.Fatalf("hashing leaf %d: %v", i, err)
+			}
+		}
+		leaves = hashed
 	}
 
-	fmt.Println("Output written to", fileName)
+	return append(opts, merkletree.WithLeafGenerator(func(index int) *big.Int {
+		if index < len(leaves) {
+			return leaves[index]
+		}
+
+		return zero
+	}))
 }
 
-func main() {
-	// Define the flags
-	hLevelPtr := flag.Int("hLevel", 4, "An integer value for the hLevel")
-	lLevelPtr := flag.Int("lLevel", 16, "An integer value for the lLevel")
-	preimagePtr := flag.Int("preImage", 0, "An integer value for the preimage")
+// tag returns the value of the domain tag flag, nil if it is not set
+func (f *treeFlags) tag() *big.Int {
+	if *f.domainTag == "" {
+		return nil
+	}
+
+	tag, ok := new(big.Int).SetString(*f.domainTag, 0)
+	if !ok {
+		log.Fatal("invalid domain tag: ", *f.domainTag)
+	}
+
+	return tag
+}
+
+// context returns a context that is done on interrupt or once the timeout
+// passes
+func (f *treeFlags) context() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	if *f.timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *f.timeout)
+
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// branchBuild returns the build of the given branches with the workers and
+// progress flags
+func (f *treeFlags) branchBuild(hLevel int, branchLevels []int, preImage int, opts []merkletree.Option) branchBuild {
+	return branchBuild{
+		hLevel:       hLevel,
+		branchLevels: branchLevels,
+		preImage:     preImage,
+		workers:      *f.workers,
+		progress:     f.progress(),
+		opts:         opts,
+	}
+}
+
+// progress reports whether to show the progress bar
+func (f *treeFlags) progress() bool {
+	return !*f.quiet && !*f.noProgress
+}
+
+// printf writes a message to stderr unless -quiet is given, keeping stdout for
+// the JSON output
+func (f *treeFlags) printf(format string, args ...interface{}) {
+	if !*f.quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+// sum returns the sum of levels
+func sum(levels []int) int {
+	total := 0
+	for _, level := range levels {
+		total += level
+	}
 
-	// Parse the flags
-	flag.Parse()
+	return total
+}
+
+// command is a subcommand of the CLI
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []command{
+	{"generate", "Build the branches and the tree over them, the default", generate},
+	{"prove", "Prove a leaf against the root of a generated tree", prove},
+	{"verify", "Check a proof written by prove", verify},
+	{"serve", "Serve the root and proofs of a saved tree over HTTP", serve},
+	{"bench", "Time the generation of a tree", bench},
+	{"visualize", "Draw a saved tree as a Graphviz graph", visualize},
+}
+
+// usage prints the subcommands to stderr
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", name)
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h for the flags of a command.\n", name)
+}
 
-	hLevel := *hLevelPtr
-	lLevel := *lLevelPtr
-	preImage := *preimagePtr
+func main() {
+	// Flags without a command generate, as before there were commands
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		generate(os.Args[1:])
+		return
+	}
 
-	branches := getMerkleRoots(hLevel, lLevel, preImage)
-	root := merkletree.NewMerkleTreeWithLeaves(branches).Root.Data
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			c.run(os.Args[2:])
+			return
+		}
+	}
 
-	outputJSON(branches, root, hLevel, lLevel, preImage)
+	if os.Args[1] != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	usage()
 }
@@ -0,0 +1,169 @@
+package multilevelmktree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// DefaultBranchDepth is the depth of the branches of deterministic trees, 64
+// leaves each for binary trees
+const DefaultBranchDepth = 6
+
+// BranchedTree is a deterministic tree built as branches of a fixed depth and a
+// top tree over the branch roots. The branches are complete subtrees, so the
+// top tree has the root of the whole tree. Trees no deeper than a branch are
+// built as a single branch.
+type BranchedTree struct {
+	// Top is the tree whose leaves are the branch roots
+	Top *MerkleTree
+	// BranchRoots holds the root of every branch in leaf order
+	BranchRoots []*big.Int
+	// Branches holds the branch trees with WithBranchTrees, nil otherwise
+	Branches []*MerkleTree
+}
+
+// WithBranchTrees keeps the branch trees of a BranchedTree, so proofs within a
+// branch can be generated without rebuilding it. Only their roots are kept by
+// default.
+func WithBranchTrees() Option {
+	return func(cfg *config) {
+		cfg.branchTrees = true
+	}
+}
+
+// WithBranchDepth sets the depth of the branches deterministic trees are built
+// from, DefaultBranchDepth by default. Only one branch is held in memory at a
+// time unless WithBranchTrees is given, so deeper branches trade memory for
+// fewer, larger trees. The root does not depend on it.
+func WithBranchDepth(depth int) Option {
+	return func(cfg *config) {
+		cfg.branchDepth = depth
+	}
+}
+
+// WithWorkers sets the number of goroutines building a tree, GOMAXPROCS by
+// default. They build the branches of deterministic trees side by side and hash
+// the large levels of every tree. Callers building several trees at once can
+// give 1 so only their own goroutines run.
+func WithWorkers(workers int) Option {
+	return func(cfg *config) {
+		cfg.workers = workers
+	}
+}
+
+// WithLeafGenerator makes deterministic trees take the leaf at index i from
+// generate(startIndex+i) instead of hashing startIndex+i, for trees over address
+// lists, commitments or test vectors. generate is called from several
+// goroutines and must not return nil.
+func WithLeafGenerator(generate func(index int) *big.Int) Option {
+	return func(cfg *config) {
+		cfg.leafGenerator = generate
+	}
+}
+
+// NewBranchedDeterministicTree builds the tree of NewDeterministicMerkleTreeCtx,
+// returning the branch roots along with the top tree
+func NewBranchedDeterministicTree(ctx context.Context, depth int, startIndex int, opts ...Option) (*BranchedTree, error) {
+	return buildBranchedTree(ctx, depth, startIndex, newConfig(opts))
+}
+
+// Root returns the root of the whole tree
+func (t *BranchedTree) Root() *big.Int {
+	return t.Top.Root.Data
+}
+
+func buildBranchedTree(ctx context.Context, depth int, startIndex int, cfg *config) (*BranchedTree, error) {
+	if cfg.arity < 2 {
+		return nil, fmt.Errorf("invalid arity %d", cfg.arity)
+	}
+	if cfg.branchDepth < 0 {
+		return nil, fmt.Errorf("invalid branch depth %d", cfg.branchDepth)
+	}
+	numLeaves := pow(cfg.arity, depth)
+	numBranches := 1
+	if depth > cfg.branchDepth {
+		numBranches = pow(cfg.arity, depth-cfg.branchDepth)
+	}
+
+	tree := &BranchedTree{BranchRoots: make([]*big.Int, numBranches)}
+	if cfg.branchTrees {
+		tree.Branches = make([]*MerkleTree, numBranches)
+	}
+
+	// build generates the leaves of branch i and builds its tree. The first
+	// index is computed from the branch width, as i*numLeaves overflows for
+	// deep trees.
+	width := numLeaves / numBranches
+
+	// Branches are built side by side, each hashing its levels with the
+	// workers left over, so no more than the workers run at once
+	workers := cfg.numWorkers()
+	outer := workers
+	if outer > numBranches {
+		outer = numBranches
+	}
+	branchCfg := *cfg
+	branchCfg.workers = workers / outer
+
+	build := func(i int) error {
+		branchLeaves, err := deterministicLeaves(ctx, &branchCfg, startIndex, i*width, width)
+		if err != nil {
+			return err
+		}
+
+		branch, err := buildMerkleTree(ctx, branchLeaves, &branchCfg)
+		if err != nil {
+			return err
+		}
+		tree.BranchRoots[i] = branch.Root.Data
+		if cfg.branchTrees {
+			tree.Branches[i] = branch
+		}
+
+		return nil
+	}
+	if err := runParallel(numBranches, outer, build); err != nil {
+		return nil, err
+	}
+
+	top, err := buildMerkleTree(ctx, tree.BranchRoots, cfg)
+	if err != nil {
+		return nil, err
+	}
+	tree.Top = top
+
+	return tree, nil
+}
+
+// deterministicLeaves returns count leaves of a deterministic tree starting at
+// index first, the leaf at index i being the hash of startIndex+i unless a leaf
+// generator is set
+func deterministicLeaves(ctx context.Context, cfg *config, startIndex, first, count int) ([]*big.Int, error) {
+	leaves := make([]*big.Int, 0, count)
+	for index := first; index < first+count; index++ {
+		if index%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		if cfg.leafGenerator != nil {
+			leaf := cfg.leafGenerator(index + startIndex)
+			if leaf == nil {
+				return nil, &HashError{Level: 0, Index: index, Err: errors.New("no leaf generated")}
+			}
+			leaves = append(leaves, leaf)
+			continue
+		}
+
+		leaf, err := cfg.hasher.Hash([]*big.Int{big.NewInt(int64(index + startIndex))})
+		if err != nil {
+			return nil, &HashError{Level: 0, Index: index, Err: err}
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	return leaves, nil
+}
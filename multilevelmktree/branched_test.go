@@ -0,0 +1,223 @@
+package multilevelmktree
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+// deterministicLeaves returns the leaves of a deterministic tree
+func poseidonLeaves(t *testing.T, count, startIndex int) []*big.Int {
+	leaves := make([]*big.Int, count)
+	for i := range leaves {
+		leaf, err := Poseidon.Hash([]*big.Int{big.NewInt(int64(i + startIndex))})
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		leaves[i] = leaf
+	}
+
+	return leaves
+}
+
+func TestBranchedDeterministicTree(t *testing.T) {
+	leaves := poseidonLeaves(t, 256, 5)
+
+	tree, err := NewBranchedDeterministicTree(context.Background(), 8, 5)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if expected := NewMerkleTreeWithLeaves(leaves).Root.Data; tree.Root().Cmp(expected) != 0 {
+		t.Error("Expected root", expected, "got", tree.Root())
+	}
+	if expected := NewDeterministicMerkleTree(8, 5).Root.Data; tree.Root().Cmp(expected) != 0 {
+		t.Error("Expected root of NewDeterministicMerkleTree", expected, "got", tree.Root())
+	}
+	if len(tree.BranchRoots) != 4 || tree.Branches != nil {
+		t.Fatal("Expected 4 branch roots and no branch trees, got", len(tree.BranchRoots), len(tree.Branches))
+	}
+	for i, root := range tree.BranchRoots {
+		if expected := NewMerkleTreeWithLeaves(leaves[i*64 : (i+1)*64]).Root.Data; root.Cmp(expected) != 0 {
+			t.Error("Expected root of branch", i, "to be", expected, "got", root)
+		}
+	}
+
+	kept, err := NewBranchedDeterministicTree(context.Background(), 8, 5, WithBranchTrees())
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if len(kept.Branches) != 4 {
+		t.Fatal("Expected 4 branch trees, got", len(kept.Branches))
+	}
+	for i, branch := range kept.Branches {
+		if branch.Root.Data.Cmp(tree.BranchRoots[i]) != 0 {
+			t.Error("Expected branch tree", i, "to have root", tree.BranchRoots[i], "got", branch.Root.Data)
+		}
+	}
+
+	small, err := NewBranchedDeterministicTree(context.Background(), 3, 0)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if len(small.BranchRoots) != 1 || small.Root().Cmp(small.BranchRoots[0]) != 0 {
+		t.Error("Expected a single branch holding the whole tree, got", len(small.BranchRoots))
+	}
+}
+
+func TestBranchDepth(t *testing.T) {
+	expected := NewDeterministicMerkleTree(8, 0).Root.Data
+
+	cases := []struct {
+		branchDepth int
+		numBranches int
+	}{
+		{0, 256},
+		{3, 32},
+		{8, 1},
+		{10, 1},
+	}
+	for _, c := range cases {
+		tree, err := NewBranchedDeterministicTree(context.Background(), 8, 0, WithBranchDepth(c.branchDepth))
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if len(tree.BranchRoots) != c.numBranches {
+			t.Error("Expected", c.numBranches, "branches for branch depth", c.branchDepth, "got", len(tree.BranchRoots))
+		}
+		if tree.Root().Cmp(expected) != 0 {
+			t.Error("Expected root", expected, "for branch depth", c.branchDepth, "got", tree.Root())
+		}
+	}
+
+	if _, err := NewBranchedDeterministicTree(context.Background(), 8, 0, WithBranchDepth(-1)); err == nil {
+		t.Error("Expected error for negative branch depth, got nil")
+	}
+}
+
+func TestParallelBranches(t *testing.T) {
+	// Use several workers even on a single CPU
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	leaves := poseidonLeaves(t, 1024, 7)
+	tree, err := NewBranchedDeterministicTree(context.Background(), 10, 7, WithBranchDepth(2), WithBranchTrees())
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if expected := NewMerkleTreeWithLeaves(leaves).Root.Data; tree.Root().Cmp(expected) != 0 {
+		t.Error("Expected root", expected, "got", tree.Root())
+	}
+	for i, root := range tree.BranchRoots {
+		if expected := NewMerkleTreeWithLeaves(leaves[i*4 : (i+1)*4]).Root.Data; root.Cmp(expected) != 0 {
+			t.Fatal("Expected root of branch", i, "to be", expected, "got", root)
+		}
+		if tree.Branches[i].Root.Data != root {
+			t.Fatal("Expected branch tree", i, "to have root", root, "got", tree.Branches[i].Root.Data)
+		}
+	}
+
+	// Leaves below index 100 hash negative values
+	var hashErr *HashError
+	_, err = NewBranchedDeterministicTree(context.Background(), 10, -100, WithBranchDepth(2))
+	if !errors.As(err, &hashErr) || hashErr.Index != 0 {
+		t.Error("Expected HashError for leaf 0, got", err)
+	}
+}
+
+func TestLeafGenerator(t *testing.T) {
+	generate := func(index int) *big.Int {
+		return big.NewInt(int64(index * index))
+	}
+	leaves := make([]*big.Int, 64)
+	for i := range leaves {
+		leaves[i] = generate(i + 10)
+	}
+	expected := NewMerkleTreeWithLeaves(leaves).Root.Data
+
+	if root := NewDeterministicMerkleTree(6, 10, WithLeafGenerator(generate)).Root.Data; root.Cmp(expected) != 0 {
+		t.Error("Expected root", expected, "got", root)
+	}
+	tree, err := NewMultilevelTree(context.Background(), []int{2, 4}, 10, WithLeafGenerator(generate), WithBranchDepth(1))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if tree.Root().Cmp(expected) != 0 {
+		t.Error("Expected multilevel root", expected, "got", tree.Root())
+	}
+	proof, err := tree.Proof(37)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if proof.Leaf.Cmp(leaves[37]) != 0 || !proof.Verify() {
+		t.Error("Expected proof of generated leaf", leaves[37], "to verify, got", proof.Leaf)
+	}
+
+	missing := func(index int) *big.Int {
+		if index == 5 {
+			return nil
+		}
+		return big.NewInt(int64(index))
+	}
+	var hashErr *HashError
+	_, err = NewDeterministicMerkleTreeCtx(context.Background(), 3, 0, WithLeafGenerator(missing))
+	if !errors.As(err, &hashErr) || hashErr.Index != 5 {
+		t.Error("Expected HashError for leaf 5, got", err)
+	}
+}
+
+// goroutineCounter hashes with Poseidon, recording the most goroutines alive
+// beyond base while hashing
+type goroutineCounter struct {
+	base int
+	most atomic.Int64
+}
+
+func (h *goroutineCounter) Name() string {
+	return "counter"
+}
+
+func (h *goroutineCounter) Hash(inputs []*big.Int) (*big.Int, error) {
+	// Let goroutines done with a level exit first
+	runtime.Gosched()
+	n := int64(runtime.NumGoroutine() - h.base)
+	for most := h.most.Load(); n > most && !h.most.CompareAndSwap(most, n); most = h.most.Load() {
+	}
+
+	return Poseidon.Hash(inputs)
+}
+
+func TestWorkersBound(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	expected := NewDeterministicMerkleTree(11, 0).Root.Data
+
+	// 2 branches of 1024 leaves, whose levels of 512 parents are hashed by
+	// the workers left over
+	for _, workers := range []int{1, 2, 3, 4, 0} {
+		h := &goroutineCounter{base: runtime.NumGoroutine()}
+		tree, err := NewBranchedDeterministicTree(context.Background(), 11, 0, WithBranchDepth(10), WithWorkers(workers), WithHasher(h))
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if tree.Root().Cmp(expected) != 0 {
+			t.Error("Expected root", expected, "with", workers, "workers, got", tree.Root())
+		}
+
+		limit := workers
+		if limit == 0 {
+			limit = 4
+		}
+		if most := h.most.Load(); most > int64(limit) {
+			t.Error("Expected at most", limit, "goroutines beyond the caller with", workers, "workers, got", most)
+		}
+	}
+
+	h := &goroutineCounter{base: runtime.NumGoroutine()}
+	if _, err := NewMerkleTreeWithLeavesCtx(context.Background(), poseidonLeaves(t, 1024, 0), WithWorkers(2), WithHasher(h)); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if most := h.most.Load(); most > 2 {
+		t.Error("Expected at most 2 goroutines beyond the caller hashing levels, got", most)
+	}
+}
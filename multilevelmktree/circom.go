@@ -0,0 +1,84 @@
+package multilevelmktree
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+// CircuitInput holds the paths of some leaves to the root in the input layout
+// of circom Merkle circuits, with keys as leaf indices
+type CircuitInput struct {
+	Root *big.Int
+	// Siblings holds the siblings of each key from the leaf up, in the format
+	// of GenerateProof
+	Siblings [][]*big.Int
+	Keys     []int
+	Values   []*big.Int
+}
+
+// CircuitInput returns the paths of the leaves at indices to the current root.
+// Like GenerateProof, it accepts indices of leaves not inserted yet.
+func (t *IncrementalMerkleTree) CircuitInput(indices []int) (*CircuitInput, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if len(indices) == 0 {
+		return nil, errors.New("no leaf indices")
+	}
+
+	input := &CircuitInput{
+		Root:     t.roots[t.currentRoot],
+		Siblings: make([][]*big.Int, len(indices)),
+		Keys:     append([]int(nil), indices...),
+		Values:   make([]*big.Int, len(indices)),
+	}
+	for i, index := range indices {
+		siblings, _, err := t.proof(index)
+		if err != nil {
+			return nil, err
+		}
+		value, err := t.node(0, index)
+		if err != nil {
+			return nil, err
+		}
+		input.Siblings[i], input.Values[i] = siblings, value
+	}
+
+	return input, nil
+}
+
+// circuitInputJSON is the JSON form of a CircuitInput, with values as decimal
+// strings as snarkjs expects
+type circuitInputJSON struct {
+	Root     string     `json:"root"`
+	Siblings [][]string `json:"siblings"`
+	Keys     []string   `json:"keys"`
+	Values   []string   `json:"values"`
+}
+
+// MarshalJSON implements json.Marshaler, writing the input file of snarkjs
+// witness generation
+func (c *CircuitInput) MarshalJSON() ([]byte, error) {
+	if c.Root == nil || len(c.Siblings) != len(c.Keys) || len(c.Values) != len(c.Keys) {
+		return nil, errors.New("incomplete circuit input")
+	}
+
+	out := circuitInputJSON{
+		Root:     c.Root.String(),
+		Siblings: make([][]string, len(c.Keys)),
+		Keys:     make([]string, len(c.Keys)),
+		Values:   make([]string, len(c.Keys)),
+	}
+	for i, key := range c.Keys {
+		out.Siblings[i] = make([]string, len(c.Siblings[i]))
+		for j, sibling := range c.Siblings[i] {
+			out.Siblings[i][j] = sibling.String()
+		}
+		out.Keys[i] = strconv.Itoa(key)
+		out.Values[i] = c.Values[i].String()
+	}
+
+	return json.Marshal(out)
+}
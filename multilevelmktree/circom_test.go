@@ -0,0 +1,57 @@
+package multilevelmktree
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestCircuitInput(t *testing.T) {
+	imt, err := NewIncrementalMerkleTree(3)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if _, err := imt.InsertBatch(testLeaves(5)); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	input, err := imt.CircuitInput([]int{1, 6})
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	for i, index := range []int{1, 6} {
+		_, directions, err := imt.GenerateProof(index)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if !VerifyProof(input.Values[i], input.Siblings[i], directions, input.Root) {
+			t.Error("Expected path of key", index, "to verify")
+		}
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if out["root"] != imt.Root().String() {
+		t.Error("Expected decimal root", imt.Root(), "got", out["root"])
+	}
+	if keys := out["keys"]; !reflect.DeepEqual(keys, []interface{}{"1", "6"}) {
+		t.Error("Expected keys [1 6], got", keys)
+	}
+	if values := out["values"].([]interface{}); values[0] != "2" {
+		t.Error("Expected value 2, got", values[0])
+	}
+
+	if _, err := imt.CircuitInput([]int{8}); err == nil {
+		t.Error("Expected error for out of range key, got nil")
+	}
+	if _, err := json.Marshal(&CircuitInput{Root: big.NewInt(1), Keys: []int{0}}); err == nil {
+		t.Error("Expected error for incomplete input, got nil")
+	}
+}
@@ -0,0 +1,157 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrNotAppendOnly is returned for operations that need the append-only shape
+// of trees built with PadPromoteOdd
+var ErrNotAppendOnly = errors.New("tree is not append-only, build it with PadPromoteOdd")
+
+// largestPowerOfTwoBelow returns the largest power of two strictly smaller than
+// n, for n > 1
+func largestPowerOfTwoBelow(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+// subtreeHash returns the root of the append-only tree over the size leaves
+// starting at start
+func (t *MerkleTree) subtreeHash(start, size int) (*big.Int, error) {
+	if size&(size-1) == 0 && start%size == 0 {
+		// Complete subtree stored in the tree
+		level := 0
+		for 1<<level < size {
+			level++
+		}
+
+		return t.levels[level][start>>level].Data, nil
+	}
+
+	k := largestPowerOfTwoBelow(size)
+	left, err := t.subtreeHash(start, k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.subtreeHash(start+k, size-k)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.cfg.hashChildren([]*big.Int{left, right})
+}
+
+// GenerateConsistencyProof returns a proof that the tree over the first newSize
+// leaves extends the tree over the first oldSize leaves, following RFC 9162
+// section 2.1.4. The tree must be built with PadPromoteOdd, e.g. through
+// WithRFC6962, and newSize may not exceed its number of leaves.
+func (t *MerkleTree) GenerateConsistencyProof(oldSize, newSize int) ([]*big.Int, error) {
+	if t.cfg.padding != PadPromoteOdd {
+		return nil, ErrNotAppendOnly
+	}
+	if oldSize <= 0 || oldSize > newSize || newSize > t.numLeaves {
+		return nil, fmt.Errorf("invalid sizes %d and %d for a tree of %d leaves", oldSize, newSize, t.numLeaves)
+	}
+
+	return t.subproof(oldSize, 0, newSize, true)
+}
+
+// subproof implements SUBPROOF(m, D[start:start+size], complete) of RFC 9162
+func (t *MerkleTree) subproof(m, start, size int, complete bool) ([]*big.Int, error) {
+	if m == size {
+		if complete {
+			return nil, nil
+		}
+		hash, err := t.subtreeHash(start, size)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*big.Int{hash}, nil
+	}
+
+	k := largestPowerOfTwoBelow(size)
+	var proof []*big.Int
+	var hash *big.Int
+	var err error
+	if m <= k {
+		proof, err = t.subproof(m, start, k, complete)
+		if err == nil {
+			hash, err = t.subtreeHash(start+k, size-k)
+		}
+	} else {
+		proof, err = t.subproof(m-k, start+k, size-k, false)
+		if err == nil {
+			hash, err = t.subtreeHash(start, k)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(proof, hash), nil
+}
+
+// VerifyConsistencyProof checks a proof produced by GenerateConsistencyProof
+// that newRoot, over newSize leaves, extends oldRoot, over oldSize leaves. The
+// options must select the hasher the tree was built with.
+func VerifyConsistencyProof(oldSize, newSize int, oldRoot, newRoot *big.Int, proof []*big.Int, opts ...Option) bool {
+	cfg := newConfig(opts)
+	if oldSize <= 0 || oldSize > newSize {
+		return false
+	}
+	if oldSize == newSize {
+		return len(proof) == 0 && oldRoot.Cmp(newRoot) == 0
+	}
+	if len(proof) == 0 {
+		return false
+	}
+
+	if oldSize&(oldSize-1) == 0 {
+		proof = append([]*big.Int{oldRoot}, proof...)
+	}
+
+	fn := oldSize - 1
+	sn := newSize - 1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr := proof[0]
+	sr := proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return false
+		}
+
+		var err error
+		if fn&1 == 1 || fn == sn {
+			fr, err = cfg.hashChildren([]*big.Int{c, fr})
+			if err != nil {
+				return false
+			}
+			sr, err = cfg.hashChildren([]*big.Int{c, sr})
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr, err = cfg.hashChildren([]*big.Int{sr, c})
+		}
+		if err != nil {
+			return false
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && fr.Cmp(oldRoot) == 0 && sr.Cmp(newRoot) == 0
+}
@@ -0,0 +1,48 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestConsistencyProof(t *testing.T) {
+	leaves := rfc6962LeafHashes(t)
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithRFC6962())
+
+	roots := make([]*big.Int, len(leaves)+1)
+	for size := 1; size <= len(leaves); size++ {
+		roots[size] = NewMerkleTreeWithLeaves(leaves[:size], WithRFC6962()).Root.Data
+	}
+
+	for newSize := 1; newSize <= len(leaves); newSize++ {
+		for oldSize := 1; oldSize <= newSize; oldSize++ {
+			proof, err := merkleTree.GenerateConsistencyProof(oldSize, newSize)
+			if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			if !VerifyConsistencyProof(oldSize, newSize, roots[oldSize], roots[newSize], proof, WithRFC6962()) {
+				t.Error("Expected consistency proof from", oldSize, "to", newSize, "to verify")
+			}
+
+			if oldSize < newSize && VerifyConsistencyProof(oldSize, newSize, roots[oldSize], roots[newSize-1], proof, WithRFC6962()) {
+				t.Error("Expected consistency proof from", oldSize, "to", newSize, "to fail for a wrong root")
+			}
+		}
+	}
+}
+
+func TestConsistencyProofErrors(t *testing.T) {
+	if _, err := NewMerkleTreeWithLeaves(testLeaves(4)).GenerateConsistencyProof(1, 2); !errors.Is(err, ErrNotAppendOnly) {
+		t.Error("Expected ErrNotAppendOnly, got", err)
+	}
+
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(5), WithPadding(PadPromoteOdd))
+	if _, err := merkleTree.GenerateConsistencyProof(3, 6); err == nil {
+		t.Error("Expected error for a size beyond the tree, got nil")
+	}
+	if _, err := merkleTree.GenerateConsistencyProof(0, 3); err == nil {
+		t.Error("Expected error for an empty old tree, got nil")
+	}
+}
@@ -0,0 +1,70 @@
+package multilevelmktree
+
+import "math/big"
+
+// LeafChange is a leaf that differs between two trees. Old is nil when the leaf
+// only exists in the second tree and New is nil when it only exists in the
+// first.
+type LeafChange struct {
+	Index int
+	Old   *big.Int
+	New   *big.Int
+}
+
+// Diff returns the leaves that differ between a and b in index order. When both
+// trees were built with the same options over the same number of leaves,
+// subtrees with equal hashes are skipped; otherwise every leaf is compared.
+func Diff(a, b *MerkleTree) []LeafChange {
+	var changes []LeafChange
+	if !sameShape(a, b) {
+		numLeaves := a.numLeaves
+		if b.numLeaves > numLeaves {
+			numLeaves = b.numLeaves
+		}
+
+		for i := 0; i < numLeaves; i++ {
+			var before, after *big.Int
+			if i < a.numLeaves {
+				before = a.levels[0][i].Data
+			}
+			if i < b.numLeaves {
+				after = b.levels[0][i].Data
+			}
+			if before == nil || after == nil || before.Cmp(after) != 0 {
+				changes = append(changes, LeafChange{Index: i, Old: before, New: after})
+			}
+		}
+
+		return changes
+	}
+
+	var walk func(level, index int)
+	walk = func(level, index int) {
+		before, after := a.levels[level][index].Data, b.levels[level][index].Data
+		if before.Cmp(after) == 0 {
+			return
+		}
+
+		if level == 0 {
+			if index < a.numLeaves {
+				changes = append(changes, LeafChange{Index: index, Old: before, New: after})
+			}
+			return
+		}
+
+		for child := index * a.cfg.arity; child < (index+1)*a.cfg.arity && child < len(a.levels[level-1]); child++ {
+			walk(level-1, child)
+		}
+	}
+	walk(a.Depth(), 0)
+
+	return changes
+}
+
+// sameShape reports whether equal node hashes in a and b imply equal subtrees
+func sameShape(a, b *MerkleTree) bool {
+	return a.numLeaves == b.numLeaves &&
+		len(a.levels) == len(b.levels) &&
+		a.cfg.padding == b.cfg.padding &&
+		sameHashing(a.cfg, b.cfg)
+}
@@ -0,0 +1,61 @@
+package multilevelmktree
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		numLeaves int
+		opts      []Option
+	}{
+		{8, nil},
+		{11, []Option{WithPadding(PadPromoteOdd)}},
+		{11, []Option{WithPadding(PadDuplicateOdd)}},
+		{9, []Option{WithPadding(PadZeroHash), WithArity(4)}},
+	}
+
+	for _, c := range cases {
+		leaves := testLeaves(c.numLeaves)
+		a := NewMerkleTreeWithLeaves(leaves, c.opts...)
+
+		changed := append([]*big.Int(nil), leaves...)
+		changed[1] = big.NewInt(100)
+		changed[c.numLeaves-1] = big.NewInt(200)
+		b := NewMerkleTreeWithLeaves(changed, c.opts...)
+
+		if changes := Diff(a, a); len(changes) != 0 {
+			t.Error("Expected no changes between a tree and itself, got", changes)
+		}
+
+		changes := Diff(a, b)
+		expected := []LeafChange{
+			{Index: 1, Old: leaves[1], New: changed[1]},
+			{Index: c.numLeaves - 1, Old: leaves[c.numLeaves-1], New: changed[c.numLeaves-1]},
+		}
+		if len(changes) != len(expected) {
+			t.Fatal("Expected changes", expected, "got", changes)
+		}
+		for i := range expected {
+			if changes[i].Index != expected[i].Index || changes[i].Old.Cmp(expected[i].Old) != 0 || changes[i].New.Cmp(expected[i].New) != 0 {
+				t.Error("Expected change", expected[i], "got", changes[i])
+			}
+		}
+	}
+}
+
+func TestDiffDifferentSizes(t *testing.T) {
+	a := NewMerkleTreeWithLeaves(testLeaves(3), WithPadding(PadZeroHash))
+	b := NewMerkleTreeWithLeaves(testLeaves(5), WithPadding(PadZeroHash))
+
+	changes := Diff(a, b)
+	if len(changes) != 2 || changes[0].Index != 3 || changes[0].Old != nil || changes[1].New.Cmp(big.NewInt(5)) != 0 {
+		t.Error("Expected leaves 3 and 4 to be added, got", changes)
+	}
+
+	changes = Diff(b, a)
+	if len(changes) != 2 || changes[0].New != nil || changes[1].Old.Cmp(big.NewInt(5)) != 0 {
+		t.Error("Expected leaves 3 and 4 to be removed, got", changes)
+	}
+}
@@ -0,0 +1,54 @@
+package multilevelmktree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// dotLabel truncates a node value to its first and last four hex digits
+func dotLabel(v *big.Int) string {
+	hex := fmt.Sprintf("%064s", v.Text(16))
+
+	return "0x" + hex[:4] + "…" + hex[len(hex)-4:]
+}
+
+// ToDOT writes the tree as a Graphviz graph with the root at the top and
+// truncated hashes as labels. Padding leaves are drawn dashed.
+func (t *MerkleTree) ToDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph merkletree {")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=monospace];")
+
+	for level := len(t.levels) - 1; level >= 0; level-- {
+		for i := range t.levels[level] {
+			style := ""
+			if level == 0 && i >= t.numLeaves {
+				style = ", style=dashed"
+			}
+			fmt.Fprintf(bw, "\tn%d_%d [label=\"%s\"%s];\n", level, i, dotLabel(t.levels[level][i].Data), style)
+		}
+	}
+
+	for level := len(t.levels) - 1; level > 0; level-- {
+		below := len(t.levels[level-1])
+		for i := range t.levels[level] {
+			for child := i * t.cfg.arity; child < (i+1)*t.cfg.arity; child++ {
+				if child >= below {
+					if t.cfg.padding != PadDuplicateOdd {
+						break
+					}
+					// The last node is repeated to complete the group
+					fmt.Fprintf(bw, "\tn%d_%d -> n%d_%d [style=dashed];\n", level, i, level-1, below-1)
+					continue
+				}
+				fmt.Fprintf(bw, "\tn%d_%d -> n%d_%d;\n", level, i, level-1, child)
+			}
+		}
+	}
+
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
@@ -0,0 +1,41 @@
+package multilevelmktree
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestToDOT(t *testing.T) {
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(3), WithPadding(PadZeroHash))
+
+	var buf bytes.Buffer
+	if err := merkleTree.ToDOT(&buf); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	dot := buf.String()
+
+	if !strings.HasPrefix(dot, "digraph merkletree {") || !strings.HasSuffix(dot, "}\n") {
+		t.Error("Expected a digraph, got", dot)
+	}
+	if n := strings.Count(dot, " -> "); n != 6 {
+		t.Error("Expected 6 edges, got", n)
+	}
+	if !strings.Contains(dot, "n0_3 [label=") || !strings.Contains(dot, "style=dashed") {
+		t.Error("Expected the padding leaf to be drawn dashed, got", dot)
+	}
+	if label := dotLabel(merkleTree.Root.Data); !strings.Contains(dot, "n2_0 [label=\""+label+"\"]") {
+		t.Error("Expected root labelled", label, "got", dot)
+	}
+}
+
+func TestToDOTDuplicateOdd(t *testing.T) {
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(3), WithPadding(PadDuplicateOdd))
+
+	var buf bytes.Buffer
+	merkleTree.ToDOT(&buf)
+
+	if !strings.Contains(buf.String(), "n1_1 -> n0_2 [style=dashed];") {
+		t.Error("Expected the repeated leaf to be linked dashed, got", buf.String())
+	}
+}
@@ -0,0 +1,56 @@
+package multilevelmktree
+
+// Flush copies the nodes of the tree to store, for example to persist a tree
+// kept in a MemoryNodeStore once per block. The first call copies every node,
+// later calls only the nodes written since the previous one, deleting those
+// that became empty. It cannot be called while a transaction is open.
+func (t *IncrementalMerkleTree) Flush(store NodeStore) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tx != nil {
+		return ErrTxInProgress
+	}
+
+	var keys []NodeKey
+	if t.dirty == nil {
+		// Every node that can be non-empty lies above the inserted leaves
+		width := t.nextIndex
+		for level := 0; level <= t.depth; level++ {
+			for index := 0; index < width; index++ {
+				keys = append(keys, NodeKey{Level: level, Index: index})
+			}
+			width = (width + t.cfg.arity - 1) / t.cfg.arity
+		}
+	} else {
+		keys = make([]NodeKey, 0, len(t.dirty))
+		for key := range t.dirty {
+			keys = append(keys, key)
+		}
+		sortNodeKeys(keys)
+	}
+
+	dirty := make(map[NodeKey]struct{})
+	for i, key := range keys {
+		value, ok, err := t.store.Get(key)
+		if err == nil {
+			if ok {
+				err = store.Put(key, value)
+			} else {
+				err = store.Delete(key)
+			}
+		}
+		if err != nil {
+			// Keep what is left for the next Flush
+			for _, key := range keys[i:] {
+				dirty[key] = struct{}{}
+			}
+			t.dirty = dirty
+
+			return err
+		}
+	}
+	t.dirty = dirty
+
+	return nil
+}
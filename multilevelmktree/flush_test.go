@@ -0,0 +1,104 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// countingStore counts the writes to the store it wraps
+type countingStore struct {
+	*MemoryNodeStore
+	writes int
+}
+
+func (s *countingStore) Put(key NodeKey, value *big.Int) error {
+	s.writes++
+
+	return s.MemoryNodeStore.Put(key, value)
+}
+
+func (s *countingStore) Delete(key NodeKey) error {
+	s.writes++
+
+	return s.MemoryNodeStore.Delete(key)
+}
+
+// sameNodes reports whether both stores hold the same nodes of a tree of depth
+// up to 3
+func sameNodes(a, b NodeStore) bool {
+	for level := 0; level <= 3; level++ {
+		for index := 0; index < 8; index++ {
+			key := NodeKey{Level: level, Index: index}
+			x, okX, _ := a.Get(key)
+			y, okY, _ := b.Get(key)
+			if okX != okY || okX && x.Cmp(y) != 0 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func TestFlush(t *testing.T) {
+	imt, _ := NewIncrementalMerkleTree(3)
+	imt.InsertBatch(testLeaves(5))
+
+	store := &countingStore{MemoryNodeStore: NewMemoryNodeStore()}
+	if err := imt.Flush(store); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if !sameNodes(imt.store, store) {
+		t.Error("Expected the first flush to copy every node")
+	}
+
+	// Only the path of the changed leaf is written again
+	store.writes = 0
+	imt.Update(4, big.NewInt(40))
+	imt.Flush(store)
+	if store.writes != 4 || !sameNodes(imt.store, store) {
+		t.Error("Expected 4 writes for one changed path, got", store.writes)
+	}
+
+	store.writes = 0
+	imt.Flush(store)
+	if store.writes != 0 {
+		t.Error("Expected no writes without changes, got", store.writes)
+	}
+
+	imt.Remove(0)
+	imt.Flush(store)
+	if _, ok, _ := store.Get(NodeKey{Level: 0, Index: 0}); ok {
+		t.Error("Expected the removed leaf to be deleted from the flushed store")
+	}
+
+	restored, _ := NewIncrementalMerkleTree(3, WithNodeStore(store))
+	if root, _ := restored.node(3, 0); root.Cmp(imt.Root()) != 0 {
+		t.Error("Expected the flushed root", imt.Root(), "got", root)
+	}
+
+	imt.Begin()
+	if err := imt.Flush(store); !errors.Is(err, ErrTxInProgress) {
+		t.Error("Expected ErrTxInProgress, got", err)
+	}
+	imt.Rollback()
+}
+
+func TestFlushFailure(t *testing.T) {
+	imt, _ := NewIncrementalMerkleTree(2)
+	imt.InsertBatch(testLeaves(3))
+
+	store := &failingStore{MemoryNodeStore: NewMemoryNodeStore(), puts: 2}
+	if err := imt.Flush(store); err == nil {
+		t.Fatal("Expected error from the store, got nil")
+	}
+
+	store.puts = 100
+	if err := imt.Flush(store); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if !sameNodes(imt.store, store) {
+		t.Error("Expected the retried flush to copy the remaining nodes")
+	}
+}
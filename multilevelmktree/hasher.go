@@ -0,0 +1,204 @@
+package multilevelmktree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/iden3/go-iden3-crypto/constants"
+	"github.com/iden3/go-iden3-crypto/poseidon"
+	"golang.org/x/crypto/sha3"
+	"lukechampine.com/blake3"
+)
+
+// Hasher hashes a list of values into a single node value
+type Hasher interface {
+	// Name identifies the hasher, e.g. on the command line
+	Name() string
+	Hash(inputs []*big.Int) (*big.Int, error)
+}
+
+// ErrNotInField is returned by field hashers for inputs that are negative or not
+// below the field modulus
+var ErrNotInField = errors.New("input not inside the finite field")
+
+// checkInField returns ErrNotInField unless every input is in [0, modulus)
+func checkInField(inputs []*big.Int, modulus *big.Int) error {
+	for _, input := range inputs {
+		if input == nil || input.Sign() < 0 || input.Cmp(modulus) >= 0 {
+			return fmt.Errorf("%w: %v", ErrNotInField, input)
+		}
+	}
+
+	return nil
+}
+
+// hashers holds every built-in hasher by name
+var hashers = map[string]Hasher{}
+
+func registerHasher(h Hasher) Hasher {
+	hashers[h.Name()] = h
+	return h
+}
+
+// HasherByName returns the built-in hasher registered under name
+func HasherByName(name string) (Hasher, error) {
+	h, ok := hashers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown hasher %q, available: %v", name, HasherNames())
+	}
+
+	return h, nil
+}
+
+// HasherNames returns the names of all built-in hashers in sorted order
+func HasherNames() []string {
+	names := make([]string, 0, len(hashers))
+	for name := range hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+type poseidonHasher struct{}
+
+func (poseidonHasher) Name() string { return "poseidon" }
+
+func (poseidonHasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	if err := checkInField(inputs, constants.Q); err != nil {
+		return nil, err
+	}
+
+	return poseidon.Hash(inputs)
+}
+
+// Poseidon hashes with Poseidon over the BN254 scalar field. It is the default
+// hasher of every tree.
+var Poseidon = registerHasher(poseidonHasher{})
+
+type keccak256Hasher struct{}
+
+func (keccak256Hasher) Name() string { return "keccak256" }
+
+// Hash returns keccak256 of the inputs encoded as 32-byte big-endian words,
+// which matches Solidity's keccak256(abi.encodePacked(a, b)) for uint256 or
+// bytes32 values.
+func (keccak256Hasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	hash := sha3.NewLegacyKeccak256()
+	for _, input := range inputs {
+		word, err := toWord(input)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(word[:])
+	}
+
+	return new(big.Int).SetBytes(hash.Sum(nil)), nil
+}
+
+// Keccak256 hashes with Ethereum's keccak256 so that roots and proofs can be
+// checked by Solidity code.
+var Keccak256 = registerHasher(keccak256Hasher{})
+
+type blake3Hasher struct{}
+
+func (blake3Hasher) Name() string { return "blake3" }
+
+// Hash returns the 256-bit Blake3 digest of the inputs encoded as 32-byte
+// big-endian words. The result is not reduced into any field.
+func (blake3Hasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	hash := blake3.New(32, nil)
+	for _, input := range inputs {
+		word, err := toWord(input)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(word[:])
+	}
+
+	return new(big.Int).SetBytes(hash.Sum(nil)), nil
+}
+
+// Blake3 is a fast hasher for trees that only guard data integrity and are
+// never proven inside a SNARK.
+var Blake3 = registerHasher(blake3Hasher{})
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Name() string { return "sha256" }
+
+// Hash returns SHA-256 of the inputs encoded as 32-byte big-endian words,
+// which matches Solidity's sha256(abi.encodePacked(a, b)) for uint256 or
+// bytes32 values. The result is not reduced into any field.
+func (sha256Hasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	hash := sha256.New()
+	for _, input := range inputs {
+		word, err := toWord(input)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(word[:])
+	}
+
+	return new(big.Int).SetBytes(hash.Sum(nil)), nil
+}
+
+// SHA256 hashes with a single SHA-256, for trees checked by the sha256
+// precompile or outside Ethereum
+var SHA256 = registerHasher(sha256Hasher{})
+
+type bitcoinHasher struct{}
+
+func (bitcoinHasher) Name() string { return "sha256d" }
+
+// Hash returns Bitcoin's double SHA-256 of the concatenated inputs. Values are
+// hashes in the byte order Bitcoin displays them (block explorers and RPC), so
+// they are reversed into internal byte order before hashing and the digest is
+// reversed back.
+func (bitcoinHasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	buf := make([]byte, 0, 32*len(inputs))
+	for _, input := range inputs {
+		word, err := toWord(input)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, reverseBytes(word[:])...)
+	}
+
+	first := sha256.Sum256(buf)
+	second := sha256.Sum256(first[:])
+
+	return new(big.Int).SetBytes(reverseBytes(second[:])), nil
+}
+
+// SHA256d hashes like Bitcoin block Merkle trees. Combined with
+// PadDuplicateOdd, roots over transaction ids match block header Merkle roots.
+var SHA256d = registerHasher(bitcoinHasher{})
+
+func reverseBytes(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+
+	return reversed
+}
+
+// toWord encodes a non-negative value of at most 256 bits as a 32-byte
+// big-endian word
+func toWord(value *big.Int) ([32]byte, error) {
+	var word [32]byte
+	if value == nil {
+		return word, errors.New("missing value")
+	}
+	if value.Sign() < 0 || value.BitLen() > 256 {
+		return word, fmt.Errorf("value %s does not fit in 32 bytes", value)
+	}
+	value.FillBytes(word[:])
+
+	return word, nil
+}
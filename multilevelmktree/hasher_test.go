@@ -0,0 +1,173 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/iden3/go-iden3-crypto/constants"
+)
+
+func TestKeccak256Hasher(t *testing.T) {
+	// keccak256(abi.encodePacked(uint256(1), uint256(2)))
+	expected, _ := new(big.Int).SetString("e90b7bceb6e7df5418fb78d8ee546e97c83a08bbccc01a0644d599ccd2a7c2e0", 16)
+
+	hashed, err := Keccak256.Hash([]*big.Int{big.NewInt(1), big.NewInt(2)})
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if hashed.Cmp(expected) != 0 {
+		t.Errorf("Expected %x, got %x", expected, hashed)
+	}
+
+	if _, err := Keccak256.Hash([]*big.Int{big.NewInt(-1)}); err == nil {
+		t.Error("Expected error for negative input, got nil")
+	}
+}
+
+func TestSHA256Hasher(t *testing.T) {
+	// sha256(abi.encodePacked(uint256(1), uint256(2)))
+	expected, _ := new(big.Int).SetString("d6ba9329f8932c12192b37849f772104d20048f76434a3290512d9d814e4116f", 16)
+
+	hashed, err := SHA256.Hash([]*big.Int{big.NewInt(1), big.NewInt(2)})
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if hashed.Cmp(expected) != 0 {
+		t.Errorf("Expected %x, got %x", expected, hashed)
+	}
+	if sha256d, _ := SHA256d.Hash([]*big.Int{big.NewInt(1), big.NewInt(2)}); sha256d.Cmp(hashed) == 0 {
+		t.Error("Expected sha256 and sha256d to differ")
+	}
+}
+
+func TestKeccak256Tree(t *testing.T) {
+	leaves := testLeaves(4)
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithHasher(Keccak256))
+
+	left, _ := Keccak256.Hash([]*big.Int{leaves[0], leaves[1]})
+	right, _ := Keccak256.Hash([]*big.Int{leaves[2], leaves[3]})
+	root, _ := Keccak256.Hash([]*big.Int{left, right})
+	if merkleTree.Root.Data.Cmp(root) != 0 {
+		t.Error("Expected keccak256 root", root, "got", merkleTree.Root.Data)
+	}
+
+	proof, directions, _ := merkleTree.GenerateProof(2)
+	if !VerifyProof(leaves[2], proof, directions, root, WithHasher(Keccak256)) {
+		t.Error("Expected keccak256 proof to verify")
+	}
+	if VerifyProof(leaves[2], proof, directions, root) {
+		t.Error("Expected keccak256 proof to fail with the default hasher")
+	}
+}
+
+func TestHasherByName(t *testing.T) {
+	for _, name := range []string{"poseidon", "keccak256", "mimc", "blake3", "poseidon-bls12-381", "sha256", "sha256d", "rfc6962"} {
+		h, err := HasherByName(name)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if h.Name() != name {
+			t.Error("Expected hasher", name, "got", h.Name())
+		}
+	}
+
+	if _, err := HasherByName("md5"); err == nil {
+		t.Error("Expected error for unknown hasher, got nil")
+	}
+}
+
+func TestMiMCHasher(t *testing.T) {
+	// Zero values of Tornado Cash's MerkleTreeWithHistory, which hashes node
+	// pairs with MiMCSponge: zeros(0) = keccak256("tornado") % p and
+	// zeros(1) = MiMCSponge(zeros(0), zeros(0))
+	zero, _ := new(big.Int).SetString("2fe54c60d3acabf3343a35b6eba15db4821b340f76e741e2249685ed4899af6c", 16)
+	expected, _ := new(big.Int).SetString("256a6135777eee2fd26f54b8b7037a25439d5235caee224154186d2b8a52e31d", 16)
+
+	hashed, err := MiMC.Hash([]*big.Int{zero, zero})
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if hashed.Cmp(expected) != 0 {
+		t.Errorf("Expected %x, got %x", expected, hashed)
+	}
+}
+
+func TestBlake3Hasher(t *testing.T) {
+	// Blake3 of the empty input
+	expected, _ := new(big.Int).SetString("af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262", 16)
+
+	hashed, err := Blake3.Hash(nil)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if hashed.Cmp(expected) != 0 {
+		t.Errorf("Expected %x, got %x", expected, hashed)
+	}
+}
+
+func BenchmarkHashers(b *testing.B) {
+	input := []*big.Int{big.NewInt(1), big.NewInt(2)}
+
+	for _, name := range HasherNames() {
+		h, _ := HasherByName(name)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				h.Hash(input)
+			}
+		})
+	}
+}
+
+func TestPoseidonHasherMatchesIden3(t *testing.T) {
+	bn254, err := NewPoseidonHasher("poseidon-bn254", constants.Q)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	for n := 1; n <= 4; n++ {
+		input := testLeaves(n)
+		expected, _ := Poseidon.Hash(input)
+		hashed, err := bn254.Hash(input)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if hashed.Cmp(expected) != 0 {
+			t.Error("Expected BN254 Poseidon of", n, "inputs to be", expected, "got", hashed)
+		}
+	}
+}
+
+func TestPoseidonBLS12381Hasher(t *testing.T) {
+	// Reference test vector for poseidonperm_x5_255_3 on the state [0, 1, 2]
+	expected, _ := new(big.Int).SetString("28ce19420fc246a05553ad1e8c98f5c9d67166be2c18e9e4cb4b4e317dd2a78a", 16)
+
+	hashed, err := PoseidonBLS12381.Hash([]*big.Int{big.NewInt(1), big.NewInt(2)})
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if hashed.Cmp(expected) != 0 {
+		t.Errorf("Expected %x, got %x", expected, hashed)
+	}
+
+	if _, err := PoseidonBLS12381.Hash([]*big.Int{blsModulus}); err == nil {
+		t.Error("Expected error for input outside the field, got nil")
+	}
+	if _, err := NewPoseidonHasher("invalid", big.NewInt(11)); err == nil {
+		t.Error("Expected error for a field where x^5 is not a permutation, got nil")
+	}
+}
+
+func TestFieldHashersRejectOutOfField(t *testing.T) {
+	for _, h := range []Hasher{Poseidon, MiMC, PoseidonBLS12381} {
+		for _, input := range []*big.Int{big.NewInt(-1), constants.Q, blsModulus} {
+			if h == PoseidonBLS12381 && input == constants.Q {
+				// The BN254 modulus is inside the larger BLS12-381 field
+				continue
+			}
+			if _, err := h.Hash([]*big.Int{big.NewInt(1), input}); !errors.Is(err, ErrNotInField) {
+				t.Error("Expected", h.Name(), "to reject", input, "with ErrNotInField, got", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package multilevelmktree
+
+import "math/big"
+
+// LeafEvent describes a change of one leaf, for example to keep an audit log of
+// state transitions. Old is nil for leaves inserted into an incremental tree.
+// OldRoot and NewRoot are the roots before and after the whole operation, so
+// the events of a batch share them.
+type LeafEvent struct {
+	Index   int
+	Old     *big.Int
+	New     *big.Int
+	OldRoot *big.Int
+	NewRoot *big.Int
+}
+
+// WithLeafHook calls hook with an event for every leaf changed by UpdateLeaf and
+// UpdateLeaves, and by Insert, InsertBatch, Update and Remove of incremental
+// trees, once the change succeeded. Events of an incremental tree transaction
+// are delivered on Commit and dropped on Rollback. The hook runs before the
+// call returns and must not use the tree.
+func WithLeafHook(hook func(LeafEvent)) Option {
+	return func(cfg *config) {
+		cfg.leafHook = hook
+	}
+}
+
+// emit passes events to the leaf hook, holding them back until the open
+// transaction commits
+func (t *IncrementalMerkleTree) emit(events ...LeafEvent) {
+	if t.cfg.leafHook == nil {
+		return
+	}
+	if t.tx != nil {
+		t.tx.events = append(t.tx.events, events...)
+		return
+	}
+
+	for _, event := range events {
+		t.cfg.leafHook(event)
+	}
+}
@@ -0,0 +1,84 @@
+package multilevelmktree
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestLeafHookIncremental(t *testing.T) {
+	var events []LeafEvent
+	imt, _ := NewIncrementalMerkleTree(3, WithLeafHook(func(e LeafEvent) {
+		events = append(events, e)
+	}))
+	empty := imt.Root()
+
+	imt.Insert(big.NewInt(1))
+	imt.InsertBatch(testLeaves(2))
+	imt.Update(0, big.NewInt(5))
+	imt.Remove(2)
+	if len(events) != 5 {
+		t.Fatal("Expected 5 events, got", len(events))
+	}
+
+	if e := events[0]; e.Index != 0 || e.Old != nil || e.New.Cmp(big.NewInt(1)) != 0 || e.OldRoot.Cmp(empty) != 0 {
+		t.Error("Expected the insert of 1 into the empty tree, got", e)
+	}
+	if events[1].Index != 1 || events[2].Index != 2 || events[1].NewRoot.Cmp(events[2].NewRoot) != 0 {
+		t.Error("Expected batch events for leaves 1 and 2 sharing a root, got", events[1], events[2])
+	}
+	if e := events[3]; e.Index != 0 || e.Old.Cmp(big.NewInt(1)) != 0 || e.New.Cmp(big.NewInt(5)) != 0 {
+		t.Error("Expected the update of leaf 0 from 1 to 5, got", e)
+	}
+	if e := events[4]; e.Index != 2 || e.Old.Cmp(big.NewInt(2)) != 0 || e.NewRoot.Cmp(imt.Root()) != 0 {
+		t.Error("Expected the removal of leaf 2 ending at the current root, got", e)
+	}
+
+	// The events chain from one root to the next
+	for i := 1; i < len(events); i++ {
+		if i != 2 && events[i].OldRoot.Cmp(events[i-1].NewRoot) != 0 {
+			t.Error("Expected event", i, "to start from the root of the previous one")
+		}
+	}
+
+	events = nil
+	imt.Begin()
+	imt.Insert(big.NewInt(7))
+	imt.Rollback()
+	imt.Begin()
+	imt.Insert(big.NewInt(8))
+	if len(events) != 0 {
+		t.Error("Expected no events before commit, got", len(events))
+	}
+	imt.Commit()
+	if len(events) != 1 || events[0].New.Cmp(big.NewInt(8)) != 0 {
+		t.Error("Expected only the committed insert, got", events)
+	}
+}
+
+func TestLeafHookMerkleTree(t *testing.T) {
+	var events []LeafEvent
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(5), WithPadding(PadDuplicateLast), WithLeafHook(func(e LeafEvent) {
+		events = append(events, e)
+	}))
+	root := merkleTree.Root.Data
+
+	merkleTree.UpdateLeaf(4, big.NewInt(50))
+	merkleTree.UpdateLeaves(map[int]*big.Int{0: big.NewInt(10), 2: big.NewInt(30)})
+	if len(events) != 3 {
+		t.Fatal("Expected 3 events without padding copies, got", len(events))
+	}
+
+	if e := events[0]; e.Index != 4 || e.Old.Cmp(big.NewInt(5)) != 0 || e.OldRoot.Cmp(root) != 0 || e.NewRoot.Cmp(events[1].OldRoot) != 0 {
+		t.Error("Expected the update of leaf 4 from the original root, got", e)
+	}
+	if events[1].Index != 0 || events[2].Index != 2 || events[2].NewRoot.Cmp(merkleTree.Root.Data) != 0 {
+		t.Error("Expected batch events for leaves 0 and 2 ending at the current root, got", events[1], events[2])
+	}
+
+	if err := merkleTree.UpdateLeaf(1, big.NewInt(-1)); err == nil {
+		t.Fatal("Expected error for a leaf outside the field, got nil")
+	}
+	if len(events) != 3 {
+		t.Error("Expected no event for a failed update, got", len(events))
+	}
+}
@@ -0,0 +1,461 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// DefaultRootHistorySize is the number of recent roots an incremental tree
+// remembers, the same as Tornado Cash's ROOT_HISTORY_SIZE
+const DefaultRootHistorySize = 30
+
+// ErrTreeFull is returned when inserting into an incremental tree that has no
+// empty leaves left
+var ErrTreeFull = errors.New("tree is full")
+
+// IncrementalMerkleTree is a tree of fixed depth, like the trees used by Tornado
+// Cash and Semaphore. Leaves are inserted at the next free index and empty
+// subtrees hash to precomputed zero values. It is safe for concurrent use, so
+// proofs can be generated while another goroutine inserts. A proof then
+// verifies against a root kept by IsKnownRoot rather than necessarily the
+// current one.
+type IncrementalMerkleTree struct {
+	// mu guards the fields below and the node store
+	mu sync.RWMutex
+
+	cfg   *config
+	depth int
+	// zeros holds the root of an empty subtree on each level
+	zeros []*big.Int
+	// store holds the non-empty nodes
+	store     NodeStore
+	nextIndex int
+	// roots is a ring buffer of the most recent roots, roots[currentRoot] being
+	// the current one
+	roots       []*big.Int
+	currentRoot int
+	// tx holds the state to roll back to while a transaction is open
+	tx *imtTx
+	// dirty holds the nodes written since the last Flush, once there was one
+	dirty map[NodeKey]struct{}
+}
+
+// NewIncrementalMerkleTree returns an empty tree with room for arity^depth
+// leaves. Empty leaves hold the hash of zero. Only nodes above inserted leaves
+// are stored, so depths up to 256 for address or nullifier sized key spaces
+// cost no more than the path length per leaf.
+func NewIncrementalMerkleTree(depth int, opts ...Option) (*IncrementalMerkleTree, error) {
+	cfg := newConfig(opts)
+	if cfg.arity < 2 {
+		return nil, fmt.Errorf("invalid arity %d", cfg.arity)
+	}
+	if depth < 0 {
+		return nil, fmt.Errorf("invalid depth %d", depth)
+	}
+	if cfg.rootHistory < 1 {
+		return nil, fmt.Errorf("invalid root history size %d", cfg.rootHistory)
+	}
+
+	zero, err := cfg.zeroLeaf()
+	if err != nil {
+		return nil, err
+	}
+
+	zeros := make([]*big.Int, depth+1)
+	zeros[0] = zero
+	for level := 1; level <= depth; level++ {
+		children := make([]*big.Int, cfg.arity)
+		for i := range children {
+			children[i] = zeros[level-1]
+		}
+		zeros[level], err = cfg.hashChildren(children)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	roots := make([]*big.Int, cfg.rootHistory)
+	roots[0] = zeros[depth]
+
+	store := cfg.store
+	if store == nil {
+		store = NewMemoryNodeStore()
+	}
+
+	return &IncrementalMerkleTree{
+		cfg:   cfg,
+		depth: depth,
+		zeros: zeros,
+		store: store,
+		roots: roots,
+	}, nil
+}
+
+// Depth returns the number of levels between the root and the leaves
+func (t *IncrementalMerkleTree) Depth() int {
+	return t.depth
+}
+
+// capacity returns the number of leaves the tree has room for, capped at the
+// largest int where arity^depth does not fit
+func (t *IncrementalMerkleTree) capacity() int {
+	return powCapped(t.cfg.arity, t.depth)
+}
+
+// NextIndex returns the index the next inserted leaf will get, which is also
+// the number of leaves inserted so far
+func (t *IncrementalMerkleTree) NextIndex() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.nextIndex
+}
+
+// LeafAt returns the leaf at index, which must be below NextIndex. Removed
+// leaves hold the empty leaf value.
+func (t *IncrementalMerkleTree) LeafAt(index int) (*big.Int, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if index < 0 || index >= t.nextIndex {
+		return nil, fmt.Errorf("leaf index %d out of range [0, %d)", index, t.nextIndex)
+	}
+
+	return t.node(0, index)
+}
+
+// Root returns the current root
+func (t *IncrementalMerkleTree) Root() *big.Int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.roots[t.currentRoot]
+}
+
+// IsKnownRoot reports whether root is one of the most recent roots kept in the
+// root history, including the current one
+func (t *IncrementalMerkleTree) IsKnownRoot(root *big.Int) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if root == nil {
+		return false
+	}
+
+	// Walk back from the current root like Tornado Cash's isKnownRoot
+	for i := 0; i < len(t.roots); i++ {
+		known := t.roots[(t.currentRoot-i+len(t.roots))%len(t.roots)]
+		if known == nil {
+			break
+		}
+		if known.Cmp(root) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// node returns the node at index within level, or the empty subtree root if
+// nothing has been inserted below it
+func (t *IncrementalMerkleTree) node(level, index int) (*big.Int, error) {
+	value, ok, err := t.store.Get(NodeKey{Level: level, Index: index})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t.zeros[level], nil
+	}
+
+	return value, nil
+}
+
+// Insert appends leaf at the next free index, rehashing its path to the root,
+// and returns that index
+func (t *IncrementalMerkleTree) Insert(leaf *big.Int) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.insert(leaf)
+}
+
+func (t *IncrementalMerkleTree) insert(leaf *big.Int) (int, error) {
+	if t.nextIndex >= t.capacity() {
+		return 0, ErrTreeFull
+	}
+
+	oldRoot := t.roots[t.currentRoot]
+	if err := t.setLeaf(t.nextIndex, leaf); err != nil {
+		return 0, err
+	}
+	t.emit(LeafEvent{Index: t.nextIndex, New: leaf, OldRoot: oldRoot, NewRoot: t.roots[t.currentRoot]})
+	t.nextIndex++
+
+	return t.nextIndex - 1, nil
+}
+
+// InsertBatch appends leaves at the next free indices and returns the index of
+// the first one. Every node above the new leaves is hashed once, so shared
+// parts of their paths are not recomputed for each leaf, and the root history
+// gains a single root. If the leaves do not fit or hashing fails, the tree is
+// left unchanged.
+func (t *IncrementalMerkleTree) InsertBatch(leaves []*big.Int) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	first := t.nextIndex
+	if len(leaves) == 0 {
+		return first, nil
+	}
+	if len(leaves) > t.capacity()-first {
+		return 0, ErrTreeFull
+	}
+
+	arity := t.cfg.arity
+	keys := make([]NodeKey, 0, 2*len(leaves)+t.depth)
+	values := make([]*big.Int, 0, cap(keys))
+	for i, leaf := range leaves {
+		keys = append(keys, NodeKey{Level: 0, Index: first + i})
+		values = append(values, leaf)
+	}
+
+	// Hash the changed range of each level from the one below
+	nodes := leaves
+	start := first
+	for level := 0; level < t.depth; level++ {
+		end := start + len(nodes)
+		parentStart := start / arity
+		parents := make([]*big.Int, (end-1)/arity-parentStart+1)
+		for p := range parents {
+			children := make([]*big.Int, arity)
+			for i := range children {
+				j := (parentStart+p)*arity + i
+				if j >= start && j < end {
+					children[i] = nodes[j-start]
+					continue
+				}
+
+				sibling, err := t.node(level, j)
+				if err != nil {
+					return 0, err
+				}
+				children[i] = sibling
+			}
+
+			parent, err := t.cfg.hashChildren(children)
+			if err != nil {
+				return 0, err
+			}
+			parents[p] = parent
+			keys = append(keys, NodeKey{Level: level + 1, Index: parentStart + p})
+			values = append(values, parent)
+		}
+
+		nodes = parents
+		start = parentStart
+	}
+
+	if err := t.putNodes(keys, values); err != nil {
+		return 0, err
+	}
+	oldRoot := t.roots[t.currentRoot]
+	t.pushRoot(nodes[0])
+	t.nextIndex += len(leaves)
+
+	if t.cfg.leafHook != nil {
+		events := make([]LeafEvent, len(leaves))
+		for i, leaf := range leaves {
+			events[i] = LeafEvent{Index: first + i, New: leaf, OldRoot: oldRoot, NewRoot: nodes[0]}
+		}
+		t.emit(events...)
+	}
+
+	return first, nil
+}
+
+// Update sets the leaf at leafIndex to newValue and returns the value it
+// replaces. Unlike Insert it only changes leaves inserted before, so an
+// unintended overwrite cannot go unnoticed.
+func (t *IncrementalMerkleTree) Update(leafIndex int, newValue *big.Int) (*big.Int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if leafIndex < 0 || leafIndex >= t.nextIndex {
+		return nil, fmt.Errorf("leaf index %d out of range [0, %d)", leafIndex, t.nextIndex)
+	}
+
+	return t.changeLeaf(leafIndex, newValue)
+}
+
+// Remove empties the leaf at leafIndex, like removing a member from a Semaphore
+// group. The leaf keeps its index, so later inserts are not moved, and once
+// every leaf is removed the root is the one of the empty tree again.
+func (t *IncrementalMerkleTree) Remove(leafIndex int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if leafIndex < 0 || leafIndex >= t.nextIndex {
+		return fmt.Errorf("leaf index %d out of range [0, %d)", leafIndex, t.nextIndex)
+	}
+
+	_, err := t.changeLeaf(leafIndex, t.zeros[0])
+
+	return err
+}
+
+// changeLeaf sets the inserted leaf at leafIndex, emitting the event of the
+// change, and returns the previous value
+func (t *IncrementalMerkleTree) changeLeaf(leafIndex int, leaf *big.Int) (*big.Int, error) {
+	old, err := t.node(0, leafIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	oldRoot := t.roots[t.currentRoot]
+	if err := t.setLeaf(leafIndex, leaf); err != nil {
+		return nil, err
+	}
+	t.emit(LeafEvent{Index: leafIndex, Old: old, New: leaf, OldRoot: oldRoot, NewRoot: t.roots[t.currentRoot]})
+
+	return old, nil
+}
+
+// setLeaf sets the leaf at leafIndex and rehashes its path, making the new root
+// the current one
+func (t *IncrementalMerkleTree) setLeaf(leafIndex int, leaf *big.Int) error {
+	// Hash the new path before touching the tree, so a failure leaves it as is
+	path := make([]*big.Int, t.depth+1)
+	path[0] = leaf
+	index := leafIndex
+	for level := 0; level < t.depth; level++ {
+		first := index - index%t.cfg.arity
+		children := make([]*big.Int, t.cfg.arity)
+		for i := range children {
+			if first+i == index {
+				children[i] = path[level]
+				continue
+			}
+
+			sibling, err := t.node(level, first+i)
+			if err != nil {
+				return err
+			}
+			children[i] = sibling
+		}
+
+		parent, err := t.cfg.hashChildren(children)
+		if err != nil {
+			return err
+		}
+		path[level+1] = parent
+		index /= t.cfg.arity
+	}
+
+	keys := make([]NodeKey, len(path))
+	index = leafIndex
+	for level := range keys {
+		keys[level] = NodeKey{Level: level, Index: index}
+		index /= t.cfg.arity
+	}
+	if err := t.putNodes(keys, path); err != nil {
+		return err
+	}
+	t.pushRoot(path[t.depth])
+
+	return nil
+}
+
+// pushRoot makes root the current root, keeping the previous ones in the root
+// history
+func (t *IncrementalMerkleTree) pushRoot(root *big.Int) {
+	t.currentRoot = (t.currentRoot + 1) % len(t.roots)
+	t.roots[t.currentRoot] = root
+}
+
+// putNodes stores the given nodes, restoring the previous ones if the store
+// fails part way. Nodes equal to the empty subtree root are deleted instead, as
+// node falls back to it.
+func (t *IncrementalMerkleTree) putNodes(keys []NodeKey, values []*big.Int) error {
+	previous := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		value, ok, err := t.store.Get(key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			previous[i] = value
+		}
+	}
+
+	for i, key := range keys {
+		var err error
+		if values[i].Cmp(t.zeros[key.Level]) == 0 {
+			err = t.store.Delete(key)
+		} else {
+			err = t.store.Put(key, values[i])
+		}
+		if err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if previous[j] != nil {
+					t.store.Put(keys[j], previous[j])
+				} else {
+					t.store.Delete(keys[j])
+				}
+			}
+
+			return err
+		}
+	}
+	if t.dirty != nil {
+		for _, key := range keys {
+			t.dirty[key] = struct{}{}
+		}
+	}
+
+	return nil
+}
+
+// GenerateProof returns the sibling hashes and direction bits proving the leaf
+// at leafIndex against the current root, in the format of
+// MerkleTree.GenerateProof. Leaves not inserted yet can be proven too, showing
+// that the position still holds the empty leaf.
+func (t *IncrementalMerkleTree) GenerateProof(leafIndex int) ([]*big.Int, []int, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.proof(leafIndex)
+}
+
+func (t *IncrementalMerkleTree) proof(leafIndex int) ([]*big.Int, []int, error) {
+	if leafIndex < 0 || leafIndex >= t.capacity() {
+		return nil, nil, fmt.Errorf("leaf index %d out of range [0, %d)", leafIndex, t.capacity())
+	}
+
+	arity := t.cfg.arity
+	proof := make([]*big.Int, 0, t.depth*(arity-1))
+	directions := make([]int, t.depth)
+
+	index := leafIndex
+	for level := 0; level < t.depth; level++ {
+		position := index % arity
+		first := index - position
+		for j := first; j < first+arity; j++ {
+			if j == index {
+				continue
+			}
+
+			sibling, err := t.node(level, j)
+			if err != nil {
+				return nil, nil, err
+			}
+			proof = append(proof, sibling)
+		}
+
+		directions[level] = position
+		index /= arity
+	}
+
+	return proof, directions, nil
+}
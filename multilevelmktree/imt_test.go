@@ -0,0 +1,320 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+func TestIncrementalMerkleTree(t *testing.T) {
+	zero, _ := poseidon.Hash([]*big.Int{big.NewInt(0)})
+	imt, err := NewIncrementalMerkleTree(3)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	padded := make([]*big.Int, 8)
+	for i := range padded {
+		padded[i] = zero
+	}
+	if expected := NewMerkleTreeWithLeaves(padded).Root.Data; imt.Root().Cmp(expected) != 0 {
+		t.Error("Expected empty root to be", expected, "got", imt.Root())
+	}
+
+	leaves := testLeaves(8)
+	for i, leaf := range leaves {
+		index, err := imt.Insert(leaf)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if index != i {
+			t.Error("Expected leaf to be inserted at", i, "got", index)
+		}
+
+		padded[i] = leaf
+		expected := NewMerkleTreeWithLeaves(padded).Root.Data
+		if imt.Root().Cmp(expected) != 0 {
+			t.Error("Expected root after", i+1, "inserts to be", expected, "got", imt.Root())
+		}
+
+		for j := 0; j <= i; j++ {
+			proof, directions, err := imt.GenerateProof(j)
+			if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+			if !VerifyProof(leaves[j], proof, directions, imt.Root()) {
+				t.Error("Expected proof for leaf", j, "after", i+1, "inserts to verify")
+			}
+		}
+	}
+
+	if _, err := imt.Insert(big.NewInt(9)); !errors.Is(err, ErrTreeFull) {
+		t.Error("Expected ErrTreeFull, got", err)
+	}
+	if _, _, err := imt.GenerateProof(8); err == nil {
+		t.Error("Expected error for out of range leaf index, got nil")
+	}
+}
+
+func TestIncrementalMerkleTreeEmptyLeafProof(t *testing.T) {
+	imt, _ := NewIncrementalMerkleTree(3)
+	imt.InsertBatch(testLeaves(3))
+	zero, _ := poseidon.Hash([]*big.Int{big.NewInt(0)})
+
+	for _, index := range []int{3, 7} {
+		proof, directions, err := imt.GenerateProof(index)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if !VerifyProof(zero, proof, directions, imt.Root()) {
+			t.Error("Expected empty leaf proof for", index, "to verify")
+		}
+		if VerifyProof(big.NewInt(4), proof, directions, imt.Root()) {
+			t.Error("Expected empty leaf proof for", index, "to fail for another value")
+		}
+	}
+}
+
+func TestIncrementalMerkleTreeWithArity(t *testing.T) {
+	imt, _ := NewIncrementalMerkleTree(2, WithArity(4))
+
+	leaves := testLeaves(5)
+	for _, leaf := range leaves {
+		imt.Insert(leaf)
+	}
+
+	proof, directions, _ := imt.GenerateProof(4)
+	if !VerifyProof(leaves[4], proof, directions, imt.Root(), WithArity(4)) {
+		t.Error("Expected arity 4 proof to verify")
+	}
+}
+
+func TestIncrementalMerkleTreeWithDomainTag(t *testing.T) {
+	tag := WithDomainTag(big.NewInt(5))
+	zero, _ := poseidon.Hash([]*big.Int{big.NewInt(0)})
+	imt, _ := NewIncrementalMerkleTree(2, tag)
+
+	leaves := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), zero}
+	for _, leaf := range leaves[:3] {
+		imt.Insert(leaf)
+	}
+
+	if expected := NewMerkleTreeWithLeaves(leaves, tag).Root.Data; imt.Root().Cmp(expected) != 0 {
+		t.Error("Expected tagged root to be", expected, "got", imt.Root())
+	}
+}
+
+func TestIncrementalMerkleTreeRemove(t *testing.T) {
+	store := NewMemoryNodeStore()
+	imt, _ := NewIncrementalMerkleTree(2, WithNodeStore(store))
+	empty := imt.Root()
+
+	leaves := testLeaves(3)
+	for _, leaf := range leaves {
+		imt.Insert(leaf)
+	}
+
+	zero, _ := poseidon.Hash([]*big.Int{big.NewInt(0)})
+	if err := imt.Remove(1); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	expected := NewMerkleTreeWithLeaves([]*big.Int{leaves[0], zero, leaves[2], zero}).Root.Data
+	if imt.Root().Cmp(expected) != 0 {
+		t.Error("Expected root after removal to be", expected, "got", imt.Root())
+	}
+	if imt.NextIndex() != 3 {
+		t.Error("Expected removal to keep the next index at 3, got", imt.NextIndex())
+	}
+
+	proof, directions, _ := imt.GenerateProof(2)
+	if !VerifyProof(leaves[2], proof, directions, imt.Root()) {
+		t.Error("Expected proof of a remaining leaf to verify")
+	}
+
+	if leaf, _ := imt.LeafAt(1); leaf.Cmp(zero) != 0 {
+		t.Error("Expected removed leaf to hold the empty leaf", zero, "got", leaf)
+	}
+	if _, err := imt.LeafAt(3); err == nil {
+		t.Error("Expected error reading a leaf not inserted yet, got nil")
+	}
+
+	imt.Remove(0)
+	imt.Remove(2)
+	if imt.Root().Cmp(empty) != 0 {
+		t.Error("Expected removing every leaf to restore the empty root", empty, "got", imt.Root())
+	}
+	for level := 0; level <= 2; level++ {
+		for index := 0; index < 4; index++ {
+			if _, ok, _ := store.Get(NodeKey{Level: level, Index: index}); ok {
+				t.Error("Expected empty node", index, "on level", level, "to be deleted from the store")
+			}
+		}
+	}
+
+	if err := imt.Remove(3); err == nil {
+		t.Error("Expected error for a leaf not inserted yet, got nil")
+	}
+}
+
+func TestIncrementalMerkleTreeInsertBatch(t *testing.T) {
+	for _, arity := range []int{2, 3} {
+		batched, _ := NewIncrementalMerkleTree(3, WithArity(arity))
+		sequential, _ := NewIncrementalMerkleTree(3, WithArity(arity))
+
+		leaves := testLeaves(pow(arity, 3))
+		for _, batch := range [][]*big.Int{leaves[:1], leaves[1:6], nil, leaves[6:]} {
+			index, err := batched.InsertBatch(batch)
+			if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+			if index != sequential.NextIndex() {
+				t.Error("Expected batch to start at", sequential.NextIndex(), "got", index)
+			}
+			for _, leaf := range batch {
+				sequential.Insert(leaf)
+			}
+
+			if batched.Root().Cmp(sequential.Root()) != 0 {
+				t.Error("Expected batched root to be", sequential.Root(), "got", batched.Root())
+			}
+		}
+
+		if _, err := batched.InsertBatch(testLeaves(1)); !errors.Is(err, ErrTreeFull) {
+			t.Error("Expected ErrTreeFull, got", err)
+		}
+	}
+
+	imt, _ := NewIncrementalMerkleTree(2)
+	imt.Insert(big.NewInt(1))
+	root := imt.Root()
+	if _, err := imt.InsertBatch(testLeaves(4)); !errors.Is(err, ErrTreeFull) {
+		t.Error("Expected ErrTreeFull for a batch that does not fit, got", err)
+	}
+	if _, err := imt.InsertBatch([]*big.Int{big.NewInt(2), big.NewInt(-1)}); err == nil {
+		t.Error("Expected error for a leaf outside the field, got nil")
+	}
+	if imt.NextIndex() != 1 || imt.Root().Cmp(root) != 0 {
+		t.Error("Expected failed batches to leave the tree unchanged")
+	}
+}
+
+func TestIncrementalMerkleTreeUpdate(t *testing.T) {
+	imt, _ := NewIncrementalMerkleTree(2)
+	leaves := testLeaves(4)
+	for _, leaf := range leaves[:3] {
+		imt.Insert(leaf)
+	}
+
+	old, err := imt.Update(1, big.NewInt(20))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if old.Cmp(leaves[1]) != 0 {
+		t.Error("Expected the replaced value", leaves[1], "got", old)
+	}
+
+	if leaf, err := imt.LeafAt(1); err != nil || leaf.Cmp(big.NewInt(20)) != 0 {
+		t.Error("Expected updated leaf 20, got", leaf, err)
+	}
+
+	imt.Insert(leaves[3])
+	leaves[1] = big.NewInt(20)
+	if expected := NewMerkleTreeWithLeaves(leaves).Root.Data; imt.Root().Cmp(expected) != 0 {
+		t.Error("Expected root after update to be", expected, "got", imt.Root())
+	}
+
+	empty, _ := NewIncrementalMerkleTree(2)
+	if _, err := empty.Update(0, big.NewInt(1)); err == nil {
+		t.Error("Expected error updating a leaf not inserted yet, got nil")
+	}
+}
+
+func TestIncrementalMerkleTreeDepth256(t *testing.T) {
+	imt, err := NewIncrementalMerkleTree(256, WithHasher(Keccak256))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	batched, _ := NewIncrementalMerkleTree(256, WithHasher(Keccak256))
+
+	leaves := testLeaves(3)
+	for _, leaf := range leaves {
+		if _, err := imt.Insert(leaf); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+	batched.InsertBatch(leaves)
+	if imt.Root().Cmp(batched.Root()) != 0 {
+		t.Error("Expected batched root to be", imt.Root(), "got", batched.Root())
+	}
+
+	proof, directions, err := imt.GenerateProof(2)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if len(proof) != 256 || !VerifyProof(leaves[2], proof, directions, imt.Root(), WithHasher(Keccak256)) {
+		t.Error("Expected a valid proof of 256 siblings, got", len(proof))
+	}
+}
+
+func TestIncrementalMerkleTreeConcurrentUse(t *testing.T) {
+	imt, _ := NewIncrementalMerkleTree(6, WithHasher(Keccak256), WithRootHistory(64))
+	imt.Insert(big.NewInt(1))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, leaf := range testLeaves(63) {
+			imt.Insert(new(big.Int).Add(leaf, big.NewInt(1)))
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		if _, _, err := imt.GenerateProof(0); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if !imt.IsKnownRoot(imt.Root()) {
+			t.Error("Expected the current root to be known")
+		}
+		if leaf, err := imt.LeafAt(0); err != nil || leaf.Cmp(big.NewInt(1)) != 0 {
+			t.Error("Expected leaf 1, got", leaf, err)
+		}
+	}
+	<-done
+
+	if imt.NextIndex() != 64 {
+		t.Error("Expected 64 leaves after concurrent inserts, got", imt.NextIndex())
+	}
+	proof, directions, _ := imt.GenerateProof(0)
+	if !VerifyProof(big.NewInt(1), proof, directions, imt.Root(), WithHasher(Keccak256)) {
+		t.Error("Expected proof to verify once inserts finished")
+	}
+}
+
+func TestIncrementalMerkleTreeRootHistory(t *testing.T) {
+	imt, err := NewIncrementalMerkleTree(4, WithRootHistory(3))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	roots := []*big.Int{imt.Root()}
+	for _, leaf := range testLeaves(5) {
+		imt.Insert(leaf)
+		roots = append(roots, imt.Root())
+	}
+
+	// Only the last 3 roots are remembered
+	for i, root := range roots {
+		if known := imt.IsKnownRoot(root); known != (i >= len(roots)-3) {
+			t.Error("Expected root", i, "known to be", !known)
+		}
+	}
+	if imt.IsKnownRoot(nil) || imt.IsKnownRoot(big.NewInt(0)) {
+		t.Error("Expected unknown roots to be rejected")
+	}
+
+	if _, err := NewIncrementalMerkleTree(4, WithRootHistory(0)); err == nil {
+		t.Error("Expected error for an empty root history, got nil")
+	}
+}
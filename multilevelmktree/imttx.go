@@ -0,0 +1,141 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+)
+
+var (
+	// ErrTxInProgress is returned by Begin and Flush while a transaction is open
+	ErrTxInProgress = errors.New("transaction already in progress")
+	// ErrNoTx is returned by Commit and Rollback without an open transaction
+	ErrNoTx = errors.New("no transaction in progress")
+)
+
+// stagedStore holds the changes of a transaction in front of the node store of
+// the tree, a nil value marking a deleted node
+type stagedStore struct {
+	base  NodeStore
+	nodes map[NodeKey]*big.Int
+}
+
+func (s *stagedStore) Get(key NodeKey) (*big.Int, bool, error) {
+	if value, ok := s.nodes[key]; ok {
+		return value, value != nil, nil
+	}
+
+	return s.base.Get(key)
+}
+
+func (s *stagedStore) Put(key NodeKey, value *big.Int) error {
+	s.nodes[key] = value
+
+	return nil
+}
+
+func (s *stagedStore) Delete(key NodeKey) error {
+	s.nodes[key] = nil
+
+	return nil
+}
+
+// imtTx is the state of an incremental tree when its transaction began
+type imtTx struct {
+	staged      *stagedStore
+	nextIndex   int
+	roots       []*big.Int
+	currentRoot int
+	// events holds the leaf events to emit on Commit
+	events []LeafEvent
+}
+
+// Begin starts a transaction. Inserts, updates and removals until Commit or
+// Rollback are staged in memory. The tree reads them back as usual, but the
+// node store is not written until Commit.
+func (t *IncrementalMerkleTree) Begin() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.begin()
+}
+
+func (t *IncrementalMerkleTree) begin() error {
+	if t.tx != nil {
+		return ErrTxInProgress
+	}
+
+	t.tx = &imtTx{
+		staged:      &stagedStore{base: t.store, nodes: make(map[NodeKey]*big.Int)},
+		nextIndex:   t.nextIndex,
+		roots:       append([]*big.Int(nil), t.roots...),
+		currentRoot: t.currentRoot,
+	}
+	t.store = t.tx.staged
+
+	return nil
+}
+
+// Commit writes the staged changes to the node store and ends the transaction.
+// If the store fails, it is restored and the transaction stays open, so it can
+// be committed again or rolled back.
+func (t *IncrementalMerkleTree) Commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.commit()
+}
+
+func (t *IncrementalMerkleTree) commit() error {
+	if t.tx == nil {
+		return ErrNoTx
+	}
+
+	keys := make([]NodeKey, 0, len(t.tx.staged.nodes))
+	for key := range t.tx.staged.nodes {
+		keys = append(keys, key)
+	}
+	sortNodeKeys(keys)
+
+	// Deleted nodes are written as empty subtree roots, which putNodes deletes
+	values := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		values[i] = t.tx.staged.nodes[key]
+		if values[i] == nil {
+			values[i] = t.zeros[key.Level]
+		}
+	}
+
+	t.store = t.tx.staged.base
+	if err := t.putNodes(keys, values); err != nil {
+		t.store = t.tx.staged
+		return err
+	}
+	events := t.tx.events
+	t.tx = nil
+	t.emit(events...)
+
+	return nil
+}
+
+// Rollback discards the staged changes, restoring the leaves and root history
+// from when the transaction began
+func (t *IncrementalMerkleTree) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.rollback()
+}
+
+func (t *IncrementalMerkleTree) rollback() error {
+	if t.tx == nil {
+		return ErrNoTx
+	}
+
+	t.store = t.tx.staged.base
+	t.nextIndex = t.tx.nextIndex
+	t.roots = t.tx.roots
+	t.currentRoot = t.tx.currentRoot
+	t.tx = nil
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestIncrementalMerkleTreeTransactions(t *testing.T) {
+	store := NewMemoryNodeStore()
+	imt, _ := NewIncrementalMerkleTree(3, WithNodeStore(store))
+	expected, _ := NewIncrementalMerkleTree(3)
+	for _, leaf := range testLeaves(3) {
+		imt.Insert(leaf)
+		expected.Insert(leaf)
+	}
+	root := imt.Root()
+
+	if err := imt.Begin(); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if err := imt.Begin(); !errors.Is(err, ErrTxInProgress) {
+		t.Error("Expected ErrTxInProgress, got", err)
+	}
+	imt.InsertBatch(testLeaves(2))
+	imt.Remove(1)
+	if _, ok, _ := store.Get(NodeKey{Level: 0, Index: 3}); ok {
+		t.Error("Expected staged leaves to stay out of the store")
+	}
+	if leaf, _ := imt.LeafAt(3); leaf.Cmp(big.NewInt(1)) != 0 {
+		t.Error("Expected staged leaf to be readable, got", leaf)
+	}
+
+	if err := imt.Rollback(); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if imt.NextIndex() != 3 || imt.Root().Cmp(root) != 0 {
+		t.Error("Expected rollback to restore", root, "got", imt.Root())
+	}
+	if leaf, _ := imt.LeafAt(1); leaf.Cmp(big.NewInt(2)) != 0 {
+		t.Error("Expected rollback to restore the removed leaf, got", leaf)
+	}
+
+	imt.Begin()
+	imt.InsertBatch(testLeaves(2))
+	imt.Remove(1)
+	if err := imt.Commit(); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	expected.InsertBatch(testLeaves(2))
+	expected.Remove(1)
+	if imt.Root().Cmp(expected.Root()) != 0 {
+		t.Error("Expected committed root to be", expected.Root(), "got", imt.Root())
+	}
+	if _, ok, _ := store.Get(NodeKey{Level: 0, Index: 1}); ok {
+		t.Error("Expected the removed leaf to be deleted from the store")
+	}
+	if value, ok, _ := store.Get(NodeKey{Level: 0, Index: 4}); !ok || value.Cmp(big.NewInt(2)) != 0 {
+		t.Error("Expected committed leaf in the store, got", value)
+	}
+
+	if err := imt.Commit(); !errors.Is(err, ErrNoTx) {
+		t.Error("Expected ErrNoTx, got", err)
+	}
+	if err := imt.Rollback(); !errors.Is(err, ErrNoTx) {
+		t.Error("Expected ErrNoTx, got", err)
+	}
+}
+
+func TestIncrementalMerkleTreeCommitFailure(t *testing.T) {
+	store := &failingStore{MemoryNodeStore: NewMemoryNodeStore(), puts: 1}
+	imt, _ := NewIncrementalMerkleTree(2, WithNodeStore(store))
+
+	imt.Begin()
+	imt.Insert(big.NewInt(1))
+	root := imt.Root()
+	if err := imt.Commit(); err == nil {
+		t.Fatal("Expected error from the store, got nil")
+	}
+	if _, ok, _ := store.Get(NodeKey{Level: 0, Index: 0}); ok {
+		t.Error("Expected a failed commit to leave the store unchanged")
+	}
+
+	store.puts = 3
+	if err := imt.Commit(); err != nil {
+		t.Fatal("Unexpected error on retry:", err)
+	}
+	if imt.Root().Cmp(root) != 0 {
+		t.Error("Expected committed root", root, "got", imt.Root())
+	}
+	if value, ok, _ := store.Get(NodeKey{Level: 0, Index: 0}); !ok || value.Cmp(big.NewInt(1)) != 0 {
+		t.Error("Expected committed leaf in the store, got", value)
+	}
+}
@@ -0,0 +1,243 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrValueExists is returned when inserting a value an indexed tree or a
+	// nullifier set already holds, or proving its absence
+	ErrValueExists = errors.New("value already in the tree")
+	// ErrValueNotFound is returned when proving a value an indexed tree or a
+	// nullifier set does not hold
+	ErrValueNotFound = errors.New("value not in the tree")
+)
+
+// IndexedLeaf is a leaf of an IndexedMerkleTree, linking to the leaf holding
+// the next larger value. The leaf with the largest value links to index and
+// value zero.
+type IndexedLeaf struct {
+	Value     *big.Int
+	NextIndex int
+	NextValue *big.Int
+}
+
+// hash returns the value of the leaf in the tree, the hash of its three fields
+func (l IndexedLeaf) hash(cfg *config) (*big.Int, error) {
+	return cfg.hasher.Hash([]*big.Int{l.Value, big.NewInt(int64(l.NextIndex)), l.NextValue})
+}
+
+// IndexedMerkleTree is an indexed Merkle tree like the nullifier tree of Aztec.
+// Its leaves form a linked list sorted by value, so the absence of a value is
+// proven by the inclusion of the leaf that skips over it. Leaf 0 holds the value
+// zero, and inserted values must be larger. It is safe for concurrent use.
+type IndexedMerkleTree struct {
+	// mu guards the leaves, keeping them in step with the tree
+	mu sync.RWMutex
+
+	cfg  *config
+	tree *IncrementalMerkleTree
+	// leaves holds the leaves by index and sorted their indices ordered by value
+	leaves []IndexedLeaf
+	sorted []int
+}
+
+// IndexedProof proves the inclusion of Leaf at Index, in the format of
+// GenerateProof
+type IndexedProof struct {
+	Leaf       IndexedLeaf
+	Index      int
+	Siblings   []*big.Int
+	Directions []int
+}
+
+// NewIndexedMerkleTree returns a tree of the given depth holding only the zero
+// leaf
+func NewIndexedMerkleTree(depth int, opts ...Option) (*IndexedMerkleTree, error) {
+	tree, err := NewIncrementalMerkleTree(depth, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &IndexedMerkleTree{cfg: tree.cfg, tree: tree}
+	zero := IndexedLeaf{Value: big.NewInt(0), NextValue: big.NewInt(0)}
+	hashed, err := zero.hash(t.cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tree.Insert(hashed); err != nil {
+		return nil, err
+	}
+	t.leaves = []IndexedLeaf{zero}
+	t.sorted = []int{0}
+
+	return t, nil
+}
+
+// Root returns the current root
+func (t *IndexedMerkleTree) Root() *big.Int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.tree.Root()
+}
+
+// Len returns the number of leaves, including the zero leaf
+func (t *IndexedMerkleTree) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return len(t.leaves)
+}
+
+// lowLeaf returns the position in sorted of the leaf with the largest value
+// below or equal to value
+func (t *IndexedMerkleTree) lowLeaf(value *big.Int) int {
+	return sort.Search(len(t.sorted), func(i int) bool {
+		return t.leaves[t.sorted[i]].Value.Cmp(value) > 0
+	}) - 1
+}
+
+// Insert adds value at the next free index, linking the leaf below it to the
+// new leaf, and returns that index. The tree is left unchanged on failure.
+func (t *IndexedMerkleTree) Insert(value *big.Int) (int, error) {
+	if value == nil || value.Sign() <= 0 {
+		return 0, fmt.Errorf("invalid value %v, values must be positive", value)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	position := t.lowLeaf(value)
+	lowIndex := t.sorted[position]
+	low := t.leaves[lowIndex]
+	if low.Value.Cmp(value) == 0 {
+		return 0, ErrValueExists
+	}
+
+	index := len(t.leaves)
+	leaf := IndexedLeaf{Value: value, NextIndex: low.NextIndex, NextValue: low.NextValue}
+	low.NextIndex, low.NextValue = index, value
+
+	hashedLow, err := low.hash(t.cfg)
+	if err != nil {
+		return 0, err
+	}
+	hashed, err := leaf.hash(t.cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	// Both leaves change together or not at all
+	if err := t.tree.Begin(); err != nil {
+		return 0, err
+	}
+	if _, err := t.tree.Update(lowIndex, hashedLow); err != nil {
+		t.tree.Rollback()
+		return 0, err
+	}
+	if _, err := t.tree.Insert(hashed); err != nil {
+		t.tree.Rollback()
+		return 0, err
+	}
+	if err := t.tree.Commit(); err != nil {
+		t.tree.Rollback()
+		return 0, err
+	}
+
+	t.leaves[lowIndex] = low
+	t.leaves = append(t.leaves, leaf)
+	t.sorted = append(t.sorted, 0)
+	copy(t.sorted[position+2:], t.sorted[position+1:])
+	t.sorted[position+1] = index
+
+	return index, nil
+}
+
+// proof returns the inclusion proof of the leaf at index
+func (t *IndexedMerkleTree) proof(index int) (*IndexedProof, error) {
+	siblings, directions, err := t.tree.GenerateProof(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IndexedProof{Leaf: t.leaves[index], Index: index, Siblings: siblings, Directions: directions}, nil
+}
+
+// ProveMembership returns the proof of the leaf holding value
+func (t *IndexedMerkleTree) ProveMembership(value *big.Int) (*IndexedProof, error) {
+	if value == nil {
+		return nil, errors.New("invalid value <nil>")
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	position := t.lowLeaf(value)
+	if position < 0 || t.leaves[t.sorted[position]].Value.Cmp(value) != 0 {
+		return nil, ErrValueNotFound
+	}
+
+	return t.proof(t.sorted[position])
+}
+
+// ProveNonMembership returns the proof of the leaf whose value is the largest
+// below value, which links past it
+func (t *IndexedMerkleTree) ProveNonMembership(value *big.Int) (*IndexedProof, error) {
+	if value == nil || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid value %v, values must be positive", value)
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	position := t.lowLeaf(value)
+	if t.leaves[t.sorted[position]].Value.Cmp(value) == 0 {
+		return nil, ErrValueExists
+	}
+
+	return t.proof(t.sorted[position])
+}
+
+// verifyIndexedProof checks that the leaf of proof hashes up to root
+func verifyIndexedProof(proof *IndexedProof, root *big.Int, opts []Option) bool {
+	hashed, err := proof.Leaf.hash(newConfig(opts))
+	if err != nil {
+		return false
+	}
+
+	return VerifyProof(hashed, proof.Siblings, proof.Directions, root, opts...)
+}
+
+// VerifyMembership checks that proof shows value in the tree with the given
+// root. The options must match those the tree was built with.
+func VerifyMembership(value *big.Int, proof *IndexedProof, root *big.Int, opts ...Option) bool {
+	if proof == nil || value == nil || proof.Leaf.Value == nil || proof.Leaf.NextValue == nil || proof.Leaf.Value.Cmp(value) != 0 {
+		return false
+	}
+
+	return verifyIndexedProof(proof, root, opts)
+}
+
+// VerifyNonMembership checks that proof shows value absent from the tree with
+// the given root: the proven leaf holds a smaller value and links either to a
+// larger one or to none. The options must match those the tree was built with.
+func VerifyNonMembership(value *big.Int, proof *IndexedProof, root *big.Int, opts ...Option) bool {
+	if proof == nil || value == nil || proof.Leaf.Value == nil || proof.Leaf.NextValue == nil {
+		return false
+	}
+
+	leaf := proof.Leaf
+	if leaf.Value.Cmp(value) >= 0 {
+		return false
+	}
+	if leaf.NextValue.Sign() != 0 && leaf.NextValue.Cmp(value) <= 0 {
+		return false
+	}
+
+	return verifyIndexedProof(proof, root, opts)
+}
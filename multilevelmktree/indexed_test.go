@@ -0,0 +1,151 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestIndexedMerkleTree(t *testing.T) {
+	tree, err := NewIndexedMerkleTree(3)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	values := []int64{30, 10, 20, 50}
+	for i, v := range values {
+		index, err := tree.Insert(big.NewInt(v))
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if index != i+1 {
+			t.Error("Expected value", v, "at index", i+1, "got", index)
+		}
+	}
+
+	// The leaves link 0 -> 10 -> 20 -> 30 -> 50 -> end
+	expected := []IndexedLeaf{
+		{big.NewInt(0), 2, big.NewInt(10)},
+		{big.NewInt(30), 4, big.NewInt(50)},
+		{big.NewInt(10), 3, big.NewInt(20)},
+		{big.NewInt(20), 1, big.NewInt(30)},
+		{big.NewInt(50), 0, big.NewInt(0)},
+	}
+	imt, _ := NewIncrementalMerkleTree(3)
+	for _, leaf := range expected {
+		hashed, _ := leaf.hash(imt.cfg)
+		imt.Insert(hashed)
+	}
+	if tree.Root().Cmp(imt.Root()) != 0 {
+		t.Error("Expected root over the linked leaves", imt.Root(), "got", tree.Root())
+	}
+	if tree.Len() != 5 {
+		t.Error("Expected 5 leaves, got", tree.Len())
+	}
+
+	for _, v := range values {
+		proof, err := tree.ProveMembership(big.NewInt(v))
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if !VerifyMembership(big.NewInt(v), proof, tree.Root()) {
+			t.Error("Expected membership proof of", v, "to verify")
+		}
+		if VerifyNonMembership(big.NewInt(v), proof, tree.Root()) {
+			t.Error("Expected membership proof of", v, "to fail as a non-membership proof")
+		}
+	}
+
+	for _, v := range []int64{5, 25, 40, 100} {
+		proof, err := tree.ProveNonMembership(big.NewInt(v))
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if !VerifyNonMembership(big.NewInt(v), proof, tree.Root()) {
+			t.Error("Expected non-membership proof of", v, "to verify")
+		}
+		if VerifyMembership(big.NewInt(v), proof, tree.Root()) {
+			t.Error("Expected non-membership proof of", v, "to fail as a membership proof")
+		}
+	}
+
+	// A low leaf does not prove values past its successor
+	proof, _ := tree.ProveNonMembership(big.NewInt(25))
+	if VerifyNonMembership(big.NewInt(35), proof, tree.Root()) {
+		t.Error("Expected non-membership proof to fail for a value past the next leaf")
+	}
+	if VerifyNonMembership(big.NewInt(25), proof, tree.Root(), WithHasher(Keccak256)) {
+		t.Error("Expected non-membership proof to fail with another hasher")
+	}
+
+	if _, err := tree.Insert(big.NewInt(20)); !errors.Is(err, ErrValueExists) {
+		t.Error("Expected ErrValueExists, got", err)
+	}
+	if _, err := tree.ProveNonMembership(big.NewInt(20)); !errors.Is(err, ErrValueExists) {
+		t.Error("Expected ErrValueExists, got", err)
+	}
+	if _, err := tree.ProveMembership(big.NewInt(25)); !errors.Is(err, ErrValueNotFound) {
+		t.Error("Expected ErrValueNotFound, got", err)
+	}
+	if _, err := tree.Insert(big.NewInt(0)); err == nil {
+		t.Error("Expected error inserting zero, got nil")
+	}
+	if _, err := tree.ProveMembership(nil); err == nil {
+		t.Error("Expected error proving nil, got nil")
+	}
+	if _, err := tree.ProveNonMembership(nil); err == nil {
+		t.Error("Expected error proving nil, got nil")
+	}
+}
+
+func TestIndexedMerkleTreeFull(t *testing.T) {
+	tree, _ := NewIndexedMerkleTree(1)
+	tree.Insert(big.NewInt(2))
+	root := tree.Root()
+
+	if _, err := tree.Insert(big.NewInt(1)); !errors.Is(err, ErrTreeFull) {
+		t.Error("Expected ErrTreeFull, got", err)
+	}
+	if tree.Root().Cmp(root) != 0 || tree.Len() != 2 {
+		t.Error("Expected a failed insert to leave the tree unchanged")
+	}
+
+	proof, _ := tree.ProveNonMembership(big.NewInt(1))
+	if !VerifyNonMembership(big.NewInt(1), proof, root) {
+		t.Error("Expected non-membership proof to verify after a failed insert")
+	}
+}
+
+func TestIndexedMerkleTreeConcurrentUse(t *testing.T) {
+	tree, _ := NewIndexedMerkleTree(6, WithHasher(Keccak256))
+	tree.Insert(big.NewInt(1))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, value := range testLeaves(62) {
+			tree.Insert(new(big.Int).Add(value, big.NewInt(1)))
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		if _, err := tree.ProveMembership(big.NewInt(1)); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if _, err := tree.ProveNonMembership(big.NewInt(1000)); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if n := tree.Len(); n < 2 || n > 64 {
+			t.Error("Expected between 2 and 64 leaves, got", n)
+		}
+	}
+	<-done
+
+	if tree.Len() != 64 {
+		t.Error("Expected 64 leaves after concurrent inserts, got", tree.Len())
+	}
+	proof, _ := tree.ProveNonMembership(big.NewInt(1000))
+	if !VerifyNonMembership(big.NewInt(1000), proof, tree.Root(), WithHasher(Keccak256)) {
+		t.Error("Expected non-membership proof to verify once inserts finished")
+	}
+}
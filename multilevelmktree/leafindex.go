@@ -0,0 +1,152 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// ErrNoLeafIndex is returned when looking up a leaf by value in a tree built
+	// without WithLeafIndex
+	ErrNoLeafIndex = errors.New("tree has no leaf index")
+	// ErrLeafNotFound is returned when no leaf holds the value looked up
+	ErrLeafNotFound = errors.New("leaf not found")
+	// ErrDuplicateLeaf is matched by the DuplicateLeafError returned when
+	// building a tree with WithUniqueLeaves over repeated values
+	ErrDuplicateLeaf = errors.New("duplicate leaf")
+)
+
+// DuplicateLeafError reports the first leaf repeating the value of an earlier
+// one
+type DuplicateLeafError struct {
+	Value *big.Int
+	// First is the index of the earlier leaf and Index the one repeating it
+	First int
+	Index int
+}
+
+func (e *DuplicateLeafError) Error() string {
+	return fmt.Sprintf("leaf %d duplicates leaf %d with value %v", e.Index, e.First, e.Value)
+}
+
+func (e *DuplicateLeafError) Is(target error) bool {
+	return target == ErrDuplicateLeaf
+}
+
+// WithLeafIndex keeps a map from leaf values to their index, so proofs can be
+// generated by value with ProveLeafValue. It costs a map entry per leaf.
+func WithLeafIndex() Option {
+	return func(cfg *config) {
+		cfg.leafIndex = true
+	}
+}
+
+// WithUniqueLeaves rejects leaves repeating an earlier value with a
+// *DuplicateLeafError when building a tree from a slice. Padding leaves are not
+// checked.
+func WithUniqueLeaves() Option {
+	return func(cfg *config) {
+		cfg.uniqueLeaves = true
+	}
+}
+
+// DuplicateLeaves returns the indices of every value found more than once in
+// leaves, grouped by value in the order of their first occurrence. It is empty
+// when all leaves are unique.
+func DuplicateLeaves(leaves []*big.Int) [][]int {
+	groups := make(map[string]int)
+	var seen [][]int
+	for i, leaf := range leaves {
+		key := leafKey(leaf)
+		if g, ok := groups[key]; ok {
+			seen[g] = append(seen[g], i)
+			continue
+		}
+		groups[key] = len(seen)
+		seen = append(seen, []int{i})
+	}
+
+	var duplicates [][]int
+	for _, indices := range seen {
+		if len(indices) > 1 {
+			duplicates = append(duplicates, indices)
+		}
+	}
+
+	return duplicates
+}
+
+// checkUniqueLeaves returns a *DuplicateLeafError for the first repeated leaf
+func checkUniqueLeaves(leaves []*big.Int) error {
+	first := make(map[string]int, len(leaves))
+	for i, leaf := range leaves {
+		if leaf == nil {
+			// Reported as a missing leaf while hashing
+			continue
+		}
+		key := leafKey(leaf)
+		if j, ok := first[key]; ok {
+			return &DuplicateLeafError{Value: leaf, First: j, Index: i}
+		}
+		first[key] = i
+	}
+
+	return nil
+}
+
+func leafKey(value *big.Int) string {
+	return value.Text(16)
+}
+
+// buildLeafIndex maps every leaf value to the first leaf holding it
+func (t *MerkleTree) buildLeafIndex() {
+	t.leafIndex = make(map[string]int, t.numLeaves)
+	for i := t.numLeaves - 1; i >= 0; i-- {
+		t.leafIndex[leafKey(t.levels[0][i].Data)] = i
+	}
+}
+
+// reindexLeaf updates the leaf index after the leaf at index changed from old
+func (t *MerkleTree) reindexLeaf(index int, old *big.Int) {
+	if t.leafIndex == nil {
+		return
+	}
+
+	if first, ok := t.leafIndex[leafKey(old)]; ok && first == index {
+		// Fall back to the next leaf still holding the old value
+		delete(t.leafIndex, leafKey(old))
+		for i := index + 1; i < t.numLeaves; i++ {
+			if t.levels[0][i].Data.Cmp(old) == 0 {
+				t.leafIndex[leafKey(old)] = i
+				break
+			}
+		}
+	}
+
+	key := leafKey(t.levels[0][index].Data)
+	if first, ok := t.leafIndex[key]; !ok || index < first {
+		t.leafIndex[key] = index
+	}
+}
+
+// ProveLeafValue returns the index of the first leaf holding value along with
+// its proof, as returned by GenerateProof. The tree must be built with
+// WithLeafIndex.
+func (t *MerkleTree) ProveLeafValue(value *big.Int) (int, []*big.Int, []int, error) {
+	if t.leafIndex == nil {
+		return 0, nil, nil, ErrNoLeafIndex
+	}
+
+	index, ok := t.leafIndex[leafKey(value)]
+	if !ok {
+		return 0, nil, nil, ErrLeafNotFound
+	}
+
+	proof, directions, err := t.GenerateProof(index)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	return index, proof, directions, nil
+}
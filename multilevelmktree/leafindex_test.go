@@ -0,0 +1,111 @@
+package multilevelmktree
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestProveLeafValue(t *testing.T) {
+	leaves := testLeaves(8)
+	leaves[6] = big.NewInt(3)
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithLeafIndex())
+	root := merkleTree.Root.Data
+
+	index, proof, directions, err := merkleTree.ProveLeafValue(big.NewInt(5))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if index != 4 || !VerifyProof(big.NewInt(5), proof, directions, root) {
+		t.Error("Expected a valid proof for leaf 4, got index", index)
+	}
+
+	// Duplicate values resolve to the first leaf holding them
+	if index, _, _, _ := merkleTree.ProveLeafValue(big.NewInt(3)); index != 2 {
+		t.Error("Expected the first leaf holding 3, got", index)
+	}
+
+	if _, _, _, err := merkleTree.ProveLeafValue(big.NewInt(100)); !errors.Is(err, ErrLeafNotFound) {
+		t.Error("Expected ErrLeafNotFound, got", err)
+	}
+	if _, _, _, err := NewMerkleTreeWithLeaves(leaves).ProveLeafValue(big.NewInt(5)); !errors.Is(err, ErrNoLeafIndex) {
+		t.Error("Expected ErrNoLeafIndex, got", err)
+	}
+}
+
+func TestLeafIndexFollowsUpdates(t *testing.T) {
+	leaves := testLeaves(8)
+	leaves[6] = big.NewInt(3)
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithLeafIndex())
+
+	expectIndex := func(value int64, expected int) {
+		t.Helper()
+		index, _, _, err := merkleTree.ProveLeafValue(big.NewInt(value))
+		if expected < 0 {
+			if !errors.Is(err, ErrLeafNotFound) {
+				t.Error("Expected", value, "to be gone, got index", index)
+			}
+			return
+		}
+		if err != nil || index != expected {
+			t.Error("Expected", value, "at", expected, "got", index, err)
+		}
+	}
+
+	merkleTree.UpdateLeaf(2, big.NewInt(100))
+	expectIndex(100, 2)
+	expectIndex(3, 6)
+
+	// Swapping two leaves
+	merkleTree.UpdateLeaves(map[int]*big.Int{0: big.NewInt(2), 1: big.NewInt(1)})
+	expectIndex(1, 1)
+	expectIndex(2, 0)
+
+	merkleTree.UpdateLeaf(6, big.NewInt(200))
+	expectIndex(3, -1)
+
+	// The index survives serialization
+	var buf bytes.Buffer
+	merkleTree.WriteTo(&buf)
+	var restored MerkleTree
+	restored.ReadFrom(&buf)
+	if index, _, _, err := restored.ProveLeafValue(big.NewInt(200)); err != nil || index != 6 {
+		t.Error("Expected restored tree to find 200 at 6, got", index, err)
+	}
+}
+
+func TestUniqueLeaves(t *testing.T) {
+	leaves := testLeaves(8)
+	if _, err := NewMerkleTreeWithLeavesCtx(context.Background(), leaves, WithUniqueLeaves()); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if duplicates := DuplicateLeaves(leaves); len(duplicates) != 0 {
+		t.Error("Expected no duplicates, got", duplicates)
+	}
+
+	leaves[5] = big.NewInt(2)
+	leaves[7] = big.NewInt(2)
+	leaves[6] = big.NewInt(4)
+	_, err := NewMerkleTreeWithLeavesCtx(context.Background(), leaves, WithUniqueLeaves())
+	var dupErr *DuplicateLeafError
+	if !errors.Is(err, ErrDuplicateLeaf) || !errors.As(err, &dupErr) {
+		t.Fatal("Expected a DuplicateLeafError, got", err)
+	}
+	if dupErr.First != 1 || dupErr.Index != 5 || dupErr.Value.Cmp(big.NewInt(2)) != 0 {
+		t.Error("Expected leaf 5 to duplicate leaf 1, got", dupErr)
+	}
+
+	expected := [][]int{{1, 5, 7}, {3, 6}}
+	if duplicates := DuplicateLeaves(leaves); !reflect.DeepEqual(duplicates, expected) {
+		t.Error("Expected duplicates", expected, "got", duplicates)
+	}
+
+	// Padding copies of the last leaf are not duplicates
+	padded := testLeaves(5)
+	if _, err := NewMerkleTreeWithLeavesCtx(context.Background(), padded, WithUniqueLeaves(), WithPadding(PadDuplicateLast)); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+}
@@ -0,0 +1,33 @@
+package multilevelmktree
+
+import "math/big"
+
+// ForEachLeaf calls fn with every leaf given to the tree in index order,
+// skipping padding leaves, until fn returns false
+func (t *MerkleTree) ForEachLeaf(fn func(index int, leaf *big.Int) bool) {
+	for i := 0; i < t.numLeaves; i++ {
+		if !fn(i, t.levels[0][i].Data) {
+			return
+		}
+	}
+}
+
+// ForEachLeaf calls fn with every inserted leaf in index order until fn returns
+// false. It stops with the error of the node store if reading a leaf fails. fn
+// must not modify the tree.
+func (t *IncrementalMerkleTree) ForEachLeaf(fn func(index int, leaf *big.Int) bool) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for i := 0; i < t.nextIndex; i++ {
+		leaf, err := t.node(0, i)
+		if err != nil {
+			return err
+		}
+		if !fn(i, leaf) {
+			return nil
+		}
+	}
+
+	return nil
+}
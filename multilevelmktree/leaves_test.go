@@ -0,0 +1,50 @@
+package multilevelmktree
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestForEachLeaf(t *testing.T) {
+	leaves := testLeaves(5)
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithPadding(PadZeroHash))
+	imt, _ := NewIncrementalMerkleTree(3)
+	for _, leaf := range leaves {
+		imt.Insert(leaf)
+	}
+
+	var fromTree, fromIMT []*big.Int
+	merkleTree.ForEachLeaf(func(index int, leaf *big.Int) bool {
+		if index != len(fromTree) {
+			t.Error("Expected leaf index", len(fromTree), "got", index)
+		}
+		fromTree = append(fromTree, leaf)
+		return true
+	})
+	err := imt.ForEachLeaf(func(index int, leaf *big.Int) bool {
+		fromIMT = append(fromIMT, leaf)
+		return true
+	})
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if len(fromTree) != len(leaves) || len(fromIMT) != len(leaves) {
+		t.Fatal("Expected", len(leaves), "leaves without padding, got", len(fromTree), len(fromIMT))
+	}
+	for i, leaf := range leaves {
+		if fromTree[i].Cmp(leaf) != 0 || fromIMT[i].Cmp(leaf) != 0 {
+			t.Error("Expected leaf", i, "to be", leaf, "got", fromTree[i], fromIMT[i])
+		}
+	}
+
+	// Returning false stops the iteration
+	visited := 0
+	merkleTree.ForEachLeaf(func(int, *big.Int) bool {
+		visited++
+		return visited < 2
+	})
+	if visited != 2 {
+		t.Error("Expected iteration to stop after 2 leaves, got", visited)
+	}
+}
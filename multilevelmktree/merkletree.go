@@ -1,23 +1,95 @@
 package multilevelmktree
 
 import (
-	"math"
+	"context"
+	"errors"
+	"fmt"
 	"math/big"
+	"sync"
+)
 
-	"github.com/iden3/go-iden3-crypto/poseidon"
+var (
+	// ErrNoLeaves is returned when building a tree without any leaves
+	ErrNoLeaves = errors.New("no leaves")
+	// ErrLeafCount is returned when the number of leaves is not a power of the
+	// arity and no padding policy allows completing the tree
+	ErrLeafCount = errors.New("leaf count is not a power of the arity")
 )
 
+// HashError reports a node that could not be hashed, for example because a
+// leaf is outside the field of the hasher. Constructors that do not return
+// errors panic with it.
+type HashError struct {
+	// Level and Index locate the node, with level 0 holding the leaves. They
+	// are zero for nodes built with NewMerkleNode.
+	Level int
+	Index int
+	Err   error
+}
+
+func (e *HashError) Error() string {
+	return fmt.Sprintf("hashing node %d on level %d: %v", e.Index, e.Level, e.Err)
+}
+
+func (e *HashError) Unwrap() error {
+	return e.Err
+}
+
 type MerkleNode struct {
 	Left  *MerkleNode
 	Right *MerkleNode
 	Data  *big.Int
+
+	// Children holds the children of internal nodes in trees with an arity
+	// above two, where Left and Right are nil
+	Children []*MerkleNode
 }
 
 type MerkleTree struct {
 	Root *MerkleNode
+
+	cfg *config
+	// levels holds the nodes of each level, from the leaves up to the root
+	levels [][]MerkleNode
+	// numLeaves is the number of leaves before padding
+	numLeaves int
+	// leafIndex maps leaf values to the first leaf holding them, when enabled
+	// with WithLeafIndex
+	leafIndex map[string]int
+}
+
+// Depth returns the number of levels between the root and the leaves
+func (t *MerkleTree) Depth() int {
+	return len(t.levels) - 1
 }
 
+// LeafCount returns the number of leaves given to the tree, excluding padding
+func (t *MerkleTree) LeafCount() int {
+	return t.numLeaves
+}
+
+// LeafAt returns the leaf at index, which must be below LeafCount
+func (t *MerkleTree) LeafAt(index int) (*big.Int, error) {
+	if index < 0 || index >= t.numLeaves {
+		return nil, fmt.Errorf("leaf index %d out of range [0, %d)", index, t.numLeaves)
+	}
+
+	return t.levels[0][index].Data, nil
+}
+
+// NewMerkleNode returns a leaf holding data when left and right are nil, and
+// otherwise the Poseidon hash of their data. It panics with a *HashError if
+// hashing fails.
 func NewMerkleNode(left, right *MerkleNode, data *big.Int) *MerkleNode {
+	mNode, err := newMerkleNode(Poseidon, left, right, data)
+	if err != nil {
+		panic(&HashError{Err: err})
+	}
+
+	return mNode
+}
+
+func newMerkleNode(h Hasher, left, right *MerkleNode, data *big.Int) (*MerkleNode, error) {
 	mNode := MerkleNode{}
 
 	if left == nil && right == nil {
@@ -25,7 +97,10 @@ func NewMerkleNode(left, right *MerkleNode, data *big.Int) *MerkleNode {
 	} else {
 		// Hash the concatenation of the left and right data
 		input := []*big.Int{left.Data, right.Data}
-		hashed, _ := poseidon.Hash(input)
+		hashed, err := h.Hash(input)
+		if err != nil {
+			return nil, err
+		}
 
 		mNode.Data = hashed
 	}
@@ -33,56 +108,279 @@ func NewMerkleNode(left, right *MerkleNode, data *big.Int) *MerkleNode {
 	mNode.Left = left
 	mNode.Right = right
 
-	return &mNode
+	return &mNode, nil
 }
 
-func NewDeterministicMerkleTree(depth int, startIndex int) *MerkleTree {
-	numLeaves := int(math.Pow(2, float64(depth)))
-	var numBranches int
-	if depth > 6 {
-		numBranches = int(math.Pow(2, float64(int64(depth-6)))) // Assuming 64 branches
-	} else {
-		numBranches = 1
+// newParentNode returns the node hashing the given children, which may be more
+// than two in trees with a higher arity
+func newParentNode(cfg *config, children []*MerkleNode) (*MerkleNode, error) {
+	input := make([]*big.Int, len(children))
+	for i, child := range children {
+		input[i] = child.Data
+	}
+	hashed, err := cfg.hashChildren(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(children) == 2 {
+		return &MerkleNode{Left: children[0], Right: children[1], Data: hashed}, nil
 	}
 
-	branchRoots := make([]*big.Int, 0, numBranches)
+	return &MerkleNode{Data: hashed, Children: children}, nil
+}
 
-	for i := 0; i < numBranches; i++ {
-		// For each branch, generate the leaves and build the Merkle tree
-		branchLeaves := make([]*big.Int, 0, numLeaves/numBranches)
-		for j := 0; j < numLeaves/numBranches; j++ {
-			leaf, _ := poseidon.Hash([]*big.Int{big.NewInt(int64((i * numLeaves / numBranches) + j + startIndex))})
-			branchLeaves = append(branchLeaves, leaf)
+// pow returns base^exp for small non-negative exponents
+func pow(base, exp int) int {
+	result := 1
+	for i := 0; i < exp; i++ {
+		result *= base
+	}
+
+	return result
+}
+
+// powCapped returns base^exp, or the largest int if it does not fit
+func powCapped(base, exp int) int {
+	maxInt := int(^uint(0) >> 1)
+	result := 1
+	for i := 0; i < exp; i++ {
+		if result > maxInt/base {
+			return maxInt
 		}
+		result *= base
+	}
+
+	return result
+}
+
+// NewDeterministicMerkleTree builds a tree of the given depth whose leaves are
+// the hashes of startIndex, startIndex+1 and so on, or the values returned by
+// WithLeafGenerator. It panics with a *HashError if hashing fails or the
+// generator returns nil.
+func NewDeterministicMerkleTree(depth int, startIndex int, opts ...Option) *MerkleTree {
+	mTree, err := buildDeterministicTree(context.Background(), depth, startIndex, newConfig(opts))
+	if err != nil {
+		panic(err)
+	}
+
+	return mTree
+}
+
+// NewDeterministicMerkleTreeCtx is NewDeterministicMerkleTree returning an
+// error instead of panicking, and stopping with the error of ctx once it is
+// done
+func NewDeterministicMerkleTreeCtx(ctx context.Context, depth int, startIndex int, opts ...Option) (*MerkleTree, error) {
+	return buildDeterministicTree(ctx, depth, startIndex, newConfig(opts))
+}
+
+// ctxCheckInterval is the number of nodes hashed between checks of the context
+const ctxCheckInterval = 1024
+
+func buildDeterministicTree(ctx context.Context, depth int, startIndex int, cfg *config) (*MerkleTree, error) {
+	tree, err := buildBranchedTree(ctx, depth, startIndex, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-		branch := NewMerkleTreeWithLeaves(branchLeaves)
-		branchRoots = append(branchRoots, branch.Root.Data)
+	return tree.Top, nil
+}
+
+// NewMerkleTreeWithLeaves builds a tree over the given leaves. When the number
+// of leaves is not a power of the arity the tree is completed according to the
+// padding option, and it panics with ErrLeafCount under the default PadError.
+// It panics with ErrNoLeaves without leaves, with a *HashError if a node cannot
+// be hashed and with a *DuplicateLeafError for repeated leaves under
+// WithUniqueLeaves. NewMerkleTreeWithLeavesErr returns these errors instead.
+func NewMerkleTreeWithLeaves(leaves []*big.Int, opts ...Option) *MerkleTree {
+	mTree, err := NewMerkleTreeWithLeavesErr(leaves, opts...)
+	if err != nil {
+		panic(err)
 	}
 
-	return NewMerkleTreeWithLeaves(branchRoots)
+	return mTree
+}
+
+// NewMerkleTreeWithLeavesErr is NewMerkleTreeWithLeaves returning an error
+// instead of panicking: ErrNoLeaves without leaves and ErrLeafCount for a
+// number of leaves that is not a power of the arity under PadError
+func NewMerkleTreeWithLeavesErr(leaves []*big.Int, opts ...Option) (*MerkleTree, error) {
+	return buildMerkleTree(context.Background(), leaves, newConfig(opts))
+}
+
+// NewMerkleTreeWithLeavesCtx is NewMerkleTreeWithLeaves returning an error
+// instead of panicking, and stopping with the error of ctx once it is done
+func NewMerkleTreeWithLeavesCtx(ctx context.Context, leaves []*big.Int, opts ...Option) (*MerkleTree, error) {
+	return buildMerkleTree(ctx, leaves, newConfig(opts))
 }
 
-func NewMerkleTreeWithLeaves(leaves []*big.Int) *MerkleTree {
-	nodes := make([]MerkleNode, 0, len(leaves))
+func buildMerkleTree(ctx context.Context, leaves []*big.Int, cfg *config) (*MerkleTree, error) {
+	if cfg.arity < 2 {
+		return nil, fmt.Errorf("invalid arity %d", cfg.arity)
+	}
+	if len(leaves) == 0 {
+		return nil, ErrNoLeaves
+	}
+	if cfg.padding == PadPromoteOdd && cfg.arity != 2 {
+		return nil, fmt.Errorf("padding %v requires a binary tree", cfg.padding)
+	}
+	if cfg.uniqueLeaves {
+		if err := checkUniqueLeaves(leaves); err != nil {
+			return nil, err
+		}
+	}
+
+	padded, err := padLeaves(leaves, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]MerkleNode, 0, len(padded))
 
-	for _, leaf := range leaves {
+	for i, leaf := range padded {
+		if leaf == nil {
+			return nil, &HashError{Level: 0, Index: i, Err: errors.New("missing leaf")}
+		}
 		node := NewMerkleNode(nil, nil, leaf)
 		nodes = append(nodes, *node)
 	}
 
-	depth := int(math.Log2(float64(len(leaves))))
-	for i := 0; i < depth; i++ {
-		newLevel := make([]MerkleNode, 0, len(nodes)/2)
+	// Subtrees made only of padding leaves are the same on each level, so they
+	// are hashed once per level instead of once per node
+	var pad *big.Int
+	if len(padded) > len(leaves) {
+		pad = padded[len(leaves)]
+	}
+	width := len(leaves)
+
+	levels := [][]MerkleNode{nodes}
+	for len(nodes) > 1 {
+		width = (width + cfg.arity - 1) / cfg.arity
+		var data []*big.Int
+		if pad != nil && width < (len(nodes)+cfg.arity-1)/cfg.arity {
+			children := make([]*big.Int, cfg.arity)
+			for i := range children {
+				children[i] = pad
+			}
+			if pad, err = cfg.hashChildren(children); err != nil {
+				return nil, &HashError{Level: len(levels), Index: width, Err: err}
+			}
 
-		for j := 0; j < len(nodes); j += 2 {
-			node := NewMerkleNode(&nodes[j], &nodes[j+1], nil)
-			newLevel = append(newLevel, *node)
+			data = make([]*big.Int, (len(nodes)+cfg.arity-1)/cfg.arity)
+			for p := width; p < len(data); p++ {
+				data[p] = pad
+			}
 		}
 
-		nodes = newLevel
+		nodes, err = linkLevel(ctx, nodes, len(levels), cfg, data)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, nodes)
+	}
+
+	mTree := MerkleTree{Root: &nodes[0], cfg: cfg, levels: levels, numLeaves: len(leaves)}
+	if cfg.leafIndex {
+		mTree.buildLeafIndex()
 	}
 
-	mTree := MerkleTree{&nodes[0]}
+	return &mTree, nil
+}
+
+// parallelLevelSize is the number of parents from which a level is hashed by
+// several workers
+const parallelLevelSize = 256
+
+// linkLevel returns the given level above nodes, with every parent linked to its
+// children. Parents are hashed from their children unless data holds their
+// value, by up to the workers of cfg on large levels.
+func linkLevel(ctx context.Context, nodes []MerkleNode, level int, cfg *config, data []*big.Int) ([]MerkleNode, error) {
+	newLevel := make([]MerkleNode, (len(nodes)+cfg.arity-1)/cfg.arity)
+
+	// link fills the parents in [start, end)
+	link := func(start, end int) error {
+		for p := start; p < end; p++ {
+			if (p-start)%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			j := p * cfg.arity
+			if cfg.padding == PadPromoteOdd && j+1 == len(nodes) {
+				// Carry the lone last node up unchanged
+				newLevel[p] = nodes[j]
+				continue
+			}
+
+			children := make([]*MerkleNode, cfg.arity)
+			for k := range children {
+				// Past the end of the level only with PadDuplicateOdd
+				if j+k < len(nodes) {
+					children[k] = &nodes[j+k]
+				} else {
+					children[k] = &nodes[len(nodes)-1]
+				}
+			}
+
+			if data == nil || data[p] == nil {
+				node, err := newParentNode(cfg, children)
+				if err != nil {
+					return &HashError{Level: level, Index: p, Err: err}
+				}
+				newLevel[p] = *node
+				continue
+			}
+
+			newLevel[p].Data = data[p]
+			if len(children) == 2 {
+				newLevel[p].Left, newLevel[p].Right = children[0], children[1]
+			} else {
+				newLevel[p].Children = children
+			}
+		}
+
+		return nil
+	}
+
+	workers := cfg.numWorkers()
+	if len(newLevel) < parallelLevelSize || workers < 2 {
+		if err := link(0, len(newLevel)); err != nil {
+			return nil, err
+		}
+
+		return newLevel, nil
+	}
+
+	// The first chunk is linked on this goroutine, so no more goroutines than
+	// workers are running
+	chunk := (len(newLevel) + workers - 1) / workers
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 1; w < workers; w++ {
+		start, end := w*chunk, (w+1)*chunk
+		if end > len(newLevel) {
+			end = len(newLevel)
+		}
+		if start >= end {
+			break
+		}
+
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs[w] = link(start, end)
+		}(w)
+	}
+	errs[0] = link(0, chunk)
+	wg.Wait()
+
+	// Report the error of the leftmost failing node
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return &mTree
+	return newLevel, nil
 }
@@ -1,7 +1,10 @@
 package multilevelmktree
 
 import (
+	"context"
+	"errors"
 	"math/big"
+	"runtime"
 	"testing"
 
 	"github.com/iden3/go-iden3-crypto/poseidon"
@@ -53,3 +56,203 @@ func TestNewMerkleTree(t *testing.T) {
 		}
 	}
 }
+
+func TestNewMerkleTreeWithArity(t *testing.T) {
+	for _, arity := range []int{4, 8} {
+		leaves := testLeaves(arity * arity)
+		merkleTree := NewMerkleTreeWithLeaves(leaves, WithArity(arity))
+
+		// Hash each group of children with a single call
+		parents := make([]*big.Int, arity)
+		for i := range parents {
+			parents[i], _ = poseidon.Hash(leaves[i*arity : (i+1)*arity])
+		}
+		expected, _ := poseidon.Hash(parents)
+
+		if merkleTree.Root.Data.Cmp(expected) != 0 {
+			t.Error("Expected arity", arity, "root to be", expected, "got", merkleTree.Root.Data)
+		}
+		if len(merkleTree.Root.Children) != arity {
+			t.Error("Expected root to have", arity, "children, got", len(merkleTree.Root.Children))
+		}
+	}
+}
+
+func TestNewDeterministicMerkleTreeWithArity(t *testing.T) {
+	leaves := make([]*big.Int, 16)
+	for i := range leaves {
+		leaves[i], _ = poseidon.Hash([]*big.Int{big.NewInt(int64(i + 3))})
+	}
+	expected := NewMerkleTreeWithLeaves(leaves, WithArity(4)).Root.Data
+
+	merkleTree := NewDeterministicMerkleTree(2, 3, WithArity(4))
+	if merkleTree.Root.Data.Cmp(expected) != 0 {
+		t.Error("Expected root node data to be", expected, "got", merkleTree.Root.Data)
+	}
+}
+
+func TestHashErrors(t *testing.T) {
+	expectHashError := func(level, index int, build func()) {
+		t.Helper()
+		defer func() {
+			var hashErr *HashError
+			err, _ := recover().(error)
+			if !errors.As(err, &hashErr) || !errors.Is(err, ErrNotInField) {
+				t.Fatal("Expected panic with a *HashError, got", err)
+			}
+			if hashErr.Level != level || hashErr.Index != index {
+				t.Error("Expected hash error at node", index, "on level", level, "got", hashErr.Index, hashErr.Level)
+			}
+		}()
+		build()
+	}
+
+	leaves := testLeaves(8)
+	leaves[5] = big.NewInt(-1)
+	expectHashError(1, 2, func() { NewMerkleTreeWithLeaves(leaves) })
+
+	expectHashError(0, 0, func() {
+		NewMerkleNode(NewMerkleNode(nil, nil, big.NewInt(-1)), NewMerkleNode(nil, nil, big.NewInt(1)), nil)
+	})
+
+	// Preimages of deterministic leaves must be inside the field too
+	expectHashError(0, 0, func() { NewDeterministicMerkleTree(2, -1) })
+}
+
+func TestConstructionWithContext(t *testing.T) {
+	leaves := testLeaves(16)
+
+	merkleTree, err := NewMerkleTreeWithLeavesCtx(context.Background(), leaves)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if expected := NewMerkleTreeWithLeaves(leaves).Root.Data; merkleTree.Root.Data.Cmp(expected) != 0 {
+		t.Error("Expected root", expected, "got", merkleTree.Root.Data)
+	}
+
+	deterministic, err := NewDeterministicMerkleTreeCtx(context.Background(), 7, 3)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if expected := NewDeterministicMerkleTree(7, 3).Root.Data; deterministic.Root.Data.Cmp(expected) != 0 {
+		t.Error("Expected deterministic root", expected, "got", deterministic.Root.Data)
+	}
+
+	if _, err := NewMerkleTreeWithLeavesCtx(context.Background(), testLeaves(3)); !errors.Is(err, ErrLeafCount) {
+		t.Error("Expected ErrLeafCount instead of a panic, got", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewMerkleTreeWithLeavesCtx(ctx, leaves); !errors.Is(err, context.Canceled) {
+		t.Error("Expected context.Canceled, got", err)
+	}
+	if _, err := NewDeterministicMerkleTreeCtx(ctx, 20, 0); !errors.Is(err, context.Canceled) {
+		t.Error("Expected context.Canceled, got", err)
+	}
+}
+
+func TestNewMerkleTreeWithLeavesErr(t *testing.T) {
+	leaves := testLeaves(16)
+	merkleTree, err := NewMerkleTreeWithLeavesErr(leaves)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if expected := NewMerkleTreeWithLeaves(leaves).Root.Data; merkleTree.Root.Data.Cmp(expected) != 0 {
+		t.Error("Expected root", expected, "got", merkleTree.Root.Data)
+	}
+
+	cases := []struct {
+		leaves []*big.Int
+		opts   []Option
+		err    error
+	}{
+		{nil, nil, ErrNoLeaves},
+		{[]*big.Int{}, []Option{WithPadding(PadZeroHash)}, ErrNoLeaves},
+		{testLeaves(3), nil, ErrLeafCount},
+		{testLeaves(7), nil, ErrLeafCount},
+		{testLeaves(8), []Option{WithArity(3)}, ErrLeafCount},
+	}
+	for _, c := range cases {
+		if _, err := NewMerkleTreeWithLeavesErr(c.leaves, c.opts...); !errors.Is(err, c.err) {
+			t.Error("Expected", c.err, "for", len(c.leaves), "leaves, got", err)
+		}
+	}
+
+	if _, err := NewMerkleTreeWithLeavesErr(testLeaves(3), WithPadding(PadZeroHash)); err != nil {
+		t.Error("Expected odd counts to be padded under PadZeroHash, got", err)
+	}
+}
+
+func TestParallelLevels(t *testing.T) {
+	// Use several workers even on a single CPU
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	cases := [][]Option{
+		{WithPadding(PadZeroHash)},
+		{WithPadding(PadPromoteOdd)},
+		{WithPadding(PadDuplicateOdd)},
+		{WithPadding(PadZeroHash), WithArity(4)},
+	}
+
+	// Large enough for the lower levels to be hashed by several workers
+	leaves := testLeaves(3 * parallelLevelSize)
+	for _, opts := range cases {
+		stream := NewStreamingTree(opts...)
+		for _, leaf := range leaves {
+			stream.Append(leaf)
+		}
+		expected, _ := stream.Root()
+
+		if root := NewMerkleTreeWithLeaves(leaves, opts...).Root.Data; root.Cmp(expected) != 0 {
+			t.Error("Expected root", expected, "got", root)
+		}
+	}
+
+	invalid := testLeaves(16 * parallelLevelSize)
+	invalid[1001] = big.NewInt(-1)
+	invalid[3001] = big.NewInt(-1)
+	var hashErr *HashError
+	_, err := NewMerkleTreeWithLeavesCtx(context.Background(), invalid)
+	if !errors.As(err, &hashErr) || hashErr.Level != 1 || hashErr.Index != 500 {
+		t.Error("Expected the leftmost hash error at node 500 on level 1, got", err)
+	}
+}
+
+func BenchmarkNewMerkleTreeWithLeaves(b *testing.B) {
+	leaves := testLeaves(1 << 12)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewMerkleTreeWithLeaves(leaves)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	leaves := testLeaves(5)
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithPadding(PadZeroHash))
+
+	if merkleTree.Depth() != 3 {
+		t.Error("Expected depth 3, got", merkleTree.Depth())
+	}
+	if merkleTree.LeafCount() != 5 {
+		t.Error("Expected 5 leaves, got", merkleTree.LeafCount())
+	}
+
+	for i, leaf := range leaves {
+		got, err := merkleTree.LeafAt(i)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if got.Cmp(leaf) != 0 {
+			t.Error("Expected leaf", i, "to be", leaf, "got", got)
+		}
+	}
+
+	if _, err := merkleTree.LeafAt(5); err == nil {
+		t.Error("Expected error for a padding leaf, got nil")
+	}
+	if _, err := merkleTree.LeafAt(-1); err == nil {
+		t.Error("Expected error for a negative index, got nil")
+	}
+}
@@ -0,0 +1,85 @@
+package multilevelmktree
+
+import (
+	"math/big"
+
+	"github.com/iden3/go-iden3-crypto/constants"
+	"github.com/iden3/go-iden3-crypto/ff"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	mimcSpongeSeed   = "mimcsponge"
+	mimcSpongeRounds = 220
+)
+
+// mimcSpongeConstants are the round constants of circomlib's MiMCSponge,
+// derived by chaining keccak256 over the seed
+var mimcSpongeConstants = func() []*ff.Element {
+	cts := make([]*ff.Element, mimcSpongeRounds)
+	cts[0] = ff.NewElement()
+	cts[mimcSpongeRounds-1] = ff.NewElement()
+
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(mimcSpongeSeed))
+	c := hash.Sum(nil)
+	for i := 1; i < mimcSpongeRounds-1; i++ {
+		hash.Reset()
+		hash.Write(c)
+		c = hash.Sum(nil)
+		cts[i] = ff.NewElement().SetBigInt(new(big.Int).SetBytes(c))
+	}
+
+	return cts
+}()
+
+// mimcFeistel runs the MiMC Feistel permutation over (xL, xR) in place with
+// key k
+func mimcFeistel(xL, xR, k *ff.Element) {
+	var t, t5 ff.Element
+
+	for i, c := range mimcSpongeConstants {
+		t.Add(xL, k)
+		t.Add(&t, c)
+
+		// t^5
+		t5.Square(&t)
+		t5.Square(&t5)
+		t5.Mul(&t5, &t)
+
+		if i < mimcSpongeRounds-1 {
+			t5.Add(xR, &t5)
+			*xR = *xL
+			*xL = t5
+		} else {
+			xR.Add(xR, &t5)
+		}
+	}
+}
+
+type mimcSpongeHasher struct{}
+
+func (mimcSpongeHasher) Name() string { return "mimc" }
+
+// Hash absorbs the inputs into the sponge with a zero key and squeezes a single
+// output, like circomlib's MiMCSponge(nInputs, 220, 1) and circomlibjs'
+// mimcsponge.multiHash(inputs).
+func (mimcSpongeHasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	if err := checkInField(inputs, constants.Q); err != nil {
+		return nil, err
+	}
+
+	r := ff.NewElement()
+	c := ff.NewElement()
+	k := ff.NewElement()
+	for _, input := range inputs {
+		r.Add(r, ff.NewElement().SetBigInt(input))
+		mimcFeistel(r, c, k)
+	}
+
+	return r.ToBigIntRegular(new(big.Int)), nil
+}
+
+// MiMC hashes with circomlib's MiMCSponge over the BN254 scalar field, as used
+// by older circom circuits such as Tornado Cash.
+var MiMC = registerHasher(mimcSpongeHasher{})
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package multilevelmktree
+
+import (
+	"errors"
+	"os"
+)
+
+func mmapFile(file *os.File, size int) ([]byte, error) {
+	return nil, errors.New("memory-mapped node stores are only supported on unix")
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,131 @@
+package multilevelmktree
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// mmapSlotSize is the size of a node in an MmapNodeStore: a presence byte
+// followed by the value as a 32-byte big-endian word
+const mmapSlotSize = 33
+
+// MmapNodeStore keeps the nodes of a tree of fixed depth and arity in a
+// memory-mapped file, one fixed-size slot per node laid out level by level from
+// the leaves up. The position of every node follows from its key, so nothing but
+// the pages in use has to be held in memory, which lets trees far larger than
+// RAM be generated by inserting into an IncrementalMerkleTree backed by it. The
+// file is created sparse and nodes survive reopening it with the same depth and
+// arity.
+type MmapNodeStore struct {
+	file *os.File
+	data []byte
+	// offsets holds the first slot of each level
+	offsets []int
+	// sizes holds the number of nodes on each level
+	sizes []int
+}
+
+// NewMmapNodeStore opens or creates the file at path for a tree with room for
+// arity^depth leaves
+func NewMmapNodeStore(path string, depth, arity int) (*MmapNodeStore, error) {
+	if arity < 2 {
+		return nil, fmt.Errorf("invalid arity %d", arity)
+	}
+	if depth < 0 {
+		return nil, fmt.Errorf("invalid depth %d", depth)
+	}
+
+	offsets := make([]int, depth+1)
+	sizes := make([]int, depth+1)
+	slots := 0
+	for level := depth; level >= 0; level-- {
+		size := pow(arity, depth-level)
+		sizes[level] = size
+		slots += size
+	}
+	for level := 1; level <= depth; level++ {
+		offsets[level] = offsets[level-1] + sizes[level-1]
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(int64(slots) * mmapSlotSize); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	data, err := mmapFile(file, slots*mmapSlotSize)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &MmapNodeStore{file: file, data: data, offsets: offsets, sizes: sizes}, nil
+}
+
+// slot returns the bytes holding the node at key
+func (s *MmapNodeStore) slot(key NodeKey) ([]byte, error) {
+	if key.Level < 0 || key.Level >= len(s.sizes) || key.Index < 0 || key.Index >= s.sizes[key.Level] {
+		return nil, fmt.Errorf("node key %v out of range", key)
+	}
+	start := (s.offsets[key.Level] + key.Index) * mmapSlotSize
+
+	return s.data[start : start+mmapSlotSize], nil
+}
+
+func (s *MmapNodeStore) Get(key NodeKey) (*big.Int, bool, error) {
+	slot, err := s.slot(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if slot[0] == 0 {
+		return nil, false, nil
+	}
+
+	return new(big.Int).SetBytes(slot[1:]), true, nil
+}
+
+func (s *MmapNodeStore) Put(key NodeKey, value *big.Int) error {
+	slot, err := s.slot(key)
+	if err != nil {
+		return err
+	}
+	word, err := toWord(value)
+	if err != nil {
+		return err
+	}
+	slot[0] = 1
+	copy(slot[1:], word[:])
+
+	return nil
+}
+
+func (s *MmapNodeStore) Delete(key NodeKey) error {
+	slot, err := s.slot(key)
+	if err != nil {
+		return err
+	}
+	for i := range slot {
+		slot[i] = 0
+	}
+
+	return nil
+}
+
+// Close flushes the nodes to the file and unmaps it
+func (s *MmapNodeStore) Close() error {
+	if s.data == nil {
+		return nil
+	}
+
+	err := munmapFile(s.data)
+	s.data = nil
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,72 @@
+//go:build unix
+
+package multilevelmktree
+
+import (
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+)
+
+func TestMmapNodeStore(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, arity := range []int{2, 4} {
+		store, err := NewMmapNodeStore(filepath.Join(dir, fmt.Sprint("nodes", arity)), 3, arity)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+
+		imt, _ := NewIncrementalMerkleTree(3, WithArity(arity), WithNodeStore(store))
+		expected, _ := NewIncrementalMerkleTree(3, WithArity(arity))
+
+		// Zero is a valid leaf and must not read back as empty
+		leaves := append([]*big.Int{big.NewInt(0)}, testLeaves(7)...)
+		for _, leaf := range leaves {
+			if _, err := imt.Insert(leaf); err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+			expected.Insert(leaf)
+		}
+		if imt.Root().Cmp(expected.Root()) != 0 {
+			t.Error("Expected arity", arity, "mmap backed root to be", expected.Root(), "got", imt.Root())
+		}
+
+		proof, directions, _ := imt.GenerateProof(0)
+		if !VerifyProof(big.NewInt(0), proof, directions, imt.Root(), WithArity(arity)) {
+			t.Error("Expected arity", arity, "proof from the mmap store to verify")
+		}
+
+		if err := store.Put(NodeKey{Level: 3, Index: 1}, big.NewInt(1)); err == nil {
+			t.Error("Expected error for a node past the end of the root level, got nil")
+		}
+		if err := store.Close(); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+}
+
+func TestMmapNodeStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes")
+	key := NodeKey{Level: 1, Index: 3}
+
+	store, _ := NewMmapNodeStore(path, 4, 2)
+	store.Put(key, big.NewInt(42))
+	store.Put(NodeKey{Level: 0, Index: 0}, big.NewInt(1))
+	store.Delete(NodeKey{Level: 0, Index: 0})
+	store.Close()
+
+	reopened, err := NewMmapNodeStore(path, 4, 2)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	defer reopened.Close()
+
+	if value, ok, _ := reopened.Get(key); !ok || value.Cmp(big.NewInt(42)) != 0 {
+		t.Error("Expected node 42 after reopening, got", value)
+	}
+	if _, ok, _ := reopened.Get(NodeKey{Level: 0, Index: 0}); ok {
+		t.Error("Expected deleted node to stay deleted")
+	}
+}
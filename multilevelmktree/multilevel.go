@@ -0,0 +1,133 @@
+package multilevelmktree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// MultilevelTree is a deterministic tree built in tiers. The bottom tier holds
+// trees over the leaves, and the roots of every tier are the leaves of the
+// trees on the tier above, up to a single top tree. It has the root of one tree
+// whose depth is the sum of the tiers.
+type MultilevelTree struct {
+	// Levels holds the depth of the trees on each tier, from the top down
+	Levels []int
+	// Roots holds the roots of the trees on each tier, from the top down, so
+	// Roots[0] holds only the root
+	Roots [][]*big.Int
+
+	cfg        *config
+	startIndex int
+}
+
+// NewMultilevelTree builds a tree whose leaves are the hashes of startIndex,
+// startIndex+1 and so on, in tiers of the given depths from the top down. The
+// levels 4 and 16 build the trees of the CLI's -hLevel=4 -lLevel=16. The bottom
+// tier is built like NewDeterministicMerkleTreeCtx.
+func NewMultilevelTree(ctx context.Context, levels []int, startIndex int, opts ...Option) (*MultilevelTree, error) {
+	return buildMultilevelTree(ctx, levels, startIndex, newConfig(opts))
+}
+
+// Root returns the root of the top tree
+func (t *MultilevelTree) Root() *big.Int {
+	return t.Roots[0][0]
+}
+
+func buildMultilevelTree(ctx context.Context, levels []int, startIndex int, cfg *config) (*MultilevelTree, error) {
+	if len(levels) == 0 {
+		return nil, errors.New("no levels")
+	}
+	for _, depth := range levels {
+		if depth < 0 {
+			return nil, fmt.Errorf("invalid level depth %d", depth)
+		}
+	}
+	if cfg.arity < 2 {
+		return nil, fmt.Errorf("invalid arity %d", cfg.arity)
+	}
+
+	last := len(levels) - 1
+	above := 0
+	for _, depth := range levels[:last] {
+		above += depth
+	}
+
+	roots := make([][]*big.Int, len(levels))
+	roots[last] = make([]*big.Int, pow(cfg.arity, above))
+	span := pow(cfg.arity, levels[last])
+	for i := range roots[last] {
+		tree, err := buildBranchedTree(ctx, levels[last], startIndex+i*span, cfg)
+		if err != nil {
+			return nil, err
+		}
+		roots[last][i] = tree.Root()
+	}
+
+	// Every tier above hashes the roots of the tier below in groups
+	for tier := last - 1; tier >= 0; tier-- {
+		width := pow(cfg.arity, levels[tier])
+		below := roots[tier+1]
+		roots[tier] = make([]*big.Int, len(below)/width)
+		for i := range roots[tier] {
+			tree, err := buildMerkleTree(ctx, below[i*width:(i+1)*width], cfg)
+			if err != nil {
+				return nil, err
+			}
+			roots[tier][i] = tree.Root.Data
+		}
+	}
+
+	return &MultilevelTree{
+		Levels:     append([]int(nil), levels...),
+		Roots:      roots,
+		cfg:        cfg,
+		startIndex: startIndex,
+	}, nil
+}
+
+// Proof returns the proof of the leaf at leafIndex against the root, composed
+// from its proofs in the trees of every tier. The bottom tree holding the leaf
+// is rebuilt, and the trees above it from the kept roots.
+func (t *MultilevelTree) Proof(leafIndex int) (*Proof, error) {
+	last := len(t.Levels) - 1
+	span := pow(t.cfg.arity, t.Levels[last])
+	if numLeaves := len(t.Roots[last]) * span; leafIndex < 0 || leafIndex >= numLeaves {
+		return nil, fmt.Errorf("leaf index %d out of range [0, %d)", leafIndex, numLeaves)
+	}
+
+	ctx := context.Background()
+	leaves, err := deterministicLeaves(ctx, t.cfg, t.startIndex, leafIndex-leafIndex%span, span)
+	if err != nil {
+		return nil, err
+	}
+	bottom, err := buildMerkleTree(ctx, leaves, t.cfg)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := bottom.Proof(leafIndex % span)
+	if err != nil {
+		return nil, err
+	}
+
+	index := leafIndex / span
+	for tier := last - 1; tier >= 0; tier-- {
+		width := pow(t.cfg.arity, t.Levels[tier])
+		group := index / width
+		tree, err := buildMerkleTree(ctx, t.Roots[tier+1][group*width:(group+1)*width], t.cfg)
+		if err != nil {
+			return nil, err
+		}
+		upper, err := tree.Proof(index % width)
+		if err != nil {
+			return nil, err
+		}
+		if proof, err = ComposeProofs(proof, upper); err != nil {
+			return nil, err
+		}
+		index = group
+	}
+
+	return proof, nil
+}
@@ -0,0 +1,81 @@
+package multilevelmktree
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMultilevelTree(t *testing.T) {
+	leaves := poseidonLeaves(t, 512, 3)
+	expected := NewMerkleTreeWithLeaves(leaves).Root.Data
+
+	tree, err := NewMultilevelTree(context.Background(), []int{2, 3, 4}, 3)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if tree.Root().Cmp(expected) != 0 {
+		t.Error("Expected root", expected, "got", tree.Root())
+	}
+
+	for tier, count := range []int{1, 4, 32} {
+		if len(tree.Roots[tier]) != count {
+			t.Fatal("Expected", count, "roots on tier", tier, "got", len(tree.Roots[tier]))
+		}
+	}
+	for i, root := range tree.Roots[2] {
+		if expected := NewMerkleTreeWithLeaves(leaves[i*16 : (i+1)*16]).Root.Data; root.Cmp(expected) != 0 {
+			t.Error("Expected root of bottom tree", i, "to be", expected, "got", root)
+		}
+	}
+	for i, root := range tree.Roots[1] {
+		if expected := NewMerkleTreeWithLeaves(leaves[i*128 : (i+1)*128]).Root.Data; root.Cmp(expected) != 0 {
+			t.Error("Expected root of middle tree", i, "to be", expected, "got", root)
+		}
+	}
+
+	single, err := NewMultilevelTree(context.Background(), []int{9}, 3)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if single.Root().Cmp(expected) != 0 {
+		t.Error("Expected single level root", expected, "got", single.Root())
+	}
+
+	if _, err := NewMultilevelTree(context.Background(), nil, 0); err == nil {
+		t.Error("Expected error for no levels, got nil")
+	}
+	if _, err := NewMultilevelTree(context.Background(), []int{2, -1}, 0); err == nil {
+		t.Error("Expected error for negative level, got nil")
+	}
+}
+
+func TestMultilevelTreeProof(t *testing.T) {
+	leaves := poseidonLeaves(t, 512, 3)
+	full := NewMerkleTreeWithLeaves(leaves)
+
+	tree, err := NewMultilevelTree(context.Background(), []int{2, 0, 3, 4}, 3)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	for _, index := range []int{0, 17, 200, 511} {
+		proof, err := tree.Proof(index)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		expected, err := full.Proof(index)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if !reflect.DeepEqual(proof, expected) {
+			t.Error("Expected proof of leaf", index, "to be", expected, "got", proof)
+		}
+		if !proof.Verify() {
+			t.Error("Expected proof of leaf", index, "to verify")
+		}
+	}
+
+	if _, err := tree.Proof(512); err == nil {
+		t.Error("Expected error for out of range leaf index, got nil")
+	}
+}
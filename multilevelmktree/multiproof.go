@@ -0,0 +1,183 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// MultiProof proves the inclusion of several leaves at once. Siblings holds
+// only the nodes that cannot be derived from the proven leaves themselves,
+// ordered level by level from the leaves up and by index within a level.
+// NumLeaves is the number of leaves of the tree, which is needed to verify trees
+// built with PadPromoteOdd.
+type MultiProof struct {
+	Depth     int
+	NumLeaves int
+	Indices   []int
+	Siblings  []*big.Int
+}
+
+// sortedIndices returns a sorted copy of indices with duplicates removed
+func sortedIndices(indices []int) []int {
+	sorted := append([]int(nil), indices...)
+	sort.Ints(sorted)
+
+	unique := sorted[:0]
+	for i, index := range sorted {
+		if i == 0 || index != sorted[i-1] {
+			unique = append(unique, index)
+		}
+	}
+
+	return unique
+}
+
+// GenerateMultiProof returns a proof for all leaves at the given indices with a
+// deduplicated sibling set. Indices are sorted and deduplicated, the leaves
+// passed to VerifyMultiProof must follow the order of MultiProof.Indices.
+func (t *MerkleTree) GenerateMultiProof(indices []int) (*MultiProof, error) {
+	carried := func(level, parent int) bool {
+		return t.cfg.padding == PadPromoteOdd && parent*t.cfg.arity+1 == len(t.levels[level])
+	}
+	node := func(level, index int) (*big.Int, error) {
+		return t.nodeAt(level, index).Data, nil
+	}
+
+	return buildMultiProof(indices, t.Depth(), t.cfg.arity, t.numLeaves, carried, node)
+}
+
+// GenerateMultiProof returns a proof for all leaves at the given indices
+// against the current root, in the format of MerkleTree.GenerateMultiProof. The
+// tree is walked once for all of them. Like GenerateProof it accepts leaves not
+// inserted yet.
+func (t *IncrementalMerkleTree) GenerateMultiProof(indices []int) (*MultiProof, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return buildMultiProof(indices, t.depth, t.cfg.arity, t.capacity(), nil, t.node)
+}
+
+// buildMultiProof collects the siblings proving the leaves at indices, below
+// numLeaves, in a tree of the given depth. carried, if set, reports parents
+// that are a copy of their only child, and node reads the nodes of the tree.
+func buildMultiProof(indices []int, depth, arity, numLeaves int, carried func(level, parent int) bool, node func(level, index int) (*big.Int, error)) (*MultiProof, error) {
+	if len(indices) == 0 {
+		return nil, errors.New("no leaf indices to prove")
+	}
+
+	known := sortedIndices(indices)
+	for _, index := range known {
+		if index < 0 || index >= numLeaves {
+			return nil, fmt.Errorf("leaf index %d out of range [0, %d)", index, numLeaves)
+		}
+	}
+
+	proof := MultiProof{
+		Depth:     depth,
+		NumLeaves: numLeaves,
+		Indices:   append([]int(nil), known...),
+	}
+
+	for level := 0; level < depth; level++ {
+		parents := make([]int, 0, len(known))
+
+		for i := 0; i < len(known); {
+			parent := known[i] / arity
+			parents = append(parents, parent)
+			if carried != nil && carried(level, parent) {
+				// Carried up unchanged
+				i++
+				continue
+			}
+
+			// Add every child of this parent that is not already known
+			for j := parent * arity; j < (parent+1)*arity; j++ {
+				if i < len(known) && known[i] == j {
+					i++
+					continue
+				}
+
+				sibling, err := node(level, j)
+				if err != nil {
+					return nil, err
+				}
+				proof.Siblings = append(proof.Siblings, sibling)
+			}
+		}
+
+		known = parents
+	}
+
+	return &proof, nil
+}
+
+// VerifyMultiProof checks that leaves, given in the order of proof.Indices,
+// together with the proof siblings hash up to root. The options must select the
+// same hasher and arity the tree was built with.
+func VerifyMultiProof(leaves []*big.Int, proof *MultiProof, root *big.Int, opts ...Option) bool {
+	cfg := newConfig(opts)
+	arity := cfg.arity
+	if proof == nil || arity < 2 || len(leaves) == 0 || len(leaves) != len(proof.Indices) {
+		return false
+	}
+
+	numLeaves := powCapped(arity, proof.Depth)
+	promote := cfg.padding == PadPromoteOdd
+	if promote {
+		numLeaves = proof.NumLeaves
+	}
+	for i, index := range proof.Indices {
+		if index < 0 || index >= numLeaves || (i > 0 && index <= proof.Indices[i-1]) {
+			return false
+		}
+	}
+
+	known := append([]int(nil), proof.Indices...)
+	nodes := append([]*big.Int(nil), leaves...)
+	siblings := proof.Siblings
+	width := numLeaves
+
+	for level := 0; level < proof.Depth; level++ {
+		parents := make([]int, 0, len(known))
+		parentNodes := make([]*big.Int, 0, len(nodes))
+
+		for i := 0; i < len(known); {
+			parent := known[i] / arity
+			if promote && parent*arity+1 == width {
+				parents = append(parents, parent)
+				parentNodes = append(parentNodes, nodes[i])
+				i++
+				continue
+			}
+
+			input := make([]*big.Int, 0, arity)
+			for j := parent * arity; j < (parent+1)*arity; j++ {
+				switch {
+				case i < len(known) && known[i] == j:
+					input = append(input, nodes[i])
+					i++
+				case len(siblings) == 0:
+					return false
+				default:
+					input = append(input, siblings[0])
+					siblings = siblings[1:]
+				}
+			}
+
+			hashed, err := cfg.hashChildren(input)
+			if err != nil {
+				return false
+			}
+			parents = append(parents, parent)
+			parentNodes = append(parentNodes, hashed)
+		}
+
+		known = parents
+		nodes = parentNodes
+		width = (width + arity - 1) / arity
+	}
+
+	return len(siblings) == 0 && nodes[0].Cmp(root) == 0
+}
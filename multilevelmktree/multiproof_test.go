@@ -0,0 +1,126 @@
+package multilevelmktree
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestMultiProof(t *testing.T) {
+	leaves := testLeaves(16)
+	merkleTree := NewMerkleTreeWithLeaves(leaves)
+	root := merkleTree.Root.Data
+
+	cases := [][]int{
+		{0},
+		{15, 0},
+		{2, 3},
+		{1, 4, 9, 14},
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	}
+
+	for _, indices := range cases {
+		proof, err := merkleTree.GenerateMultiProof(indices)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+
+		proven := make([]*big.Int, len(proof.Indices))
+		for i, index := range proof.Indices {
+			proven[i] = leaves[index]
+		}
+
+		if !VerifyMultiProof(proven, proof, root) {
+			t.Error("Expected multiproof for", indices, "to verify")
+		}
+	}
+}
+
+func TestIncrementalMerkleTreeMultiProof(t *testing.T) {
+	for _, depth := range []int{4, 80} {
+		imt, _ := NewIncrementalMerkleTree(depth, WithHasher(Keccak256))
+		leaves := testLeaves(11)
+		imt.InsertBatch(leaves)
+
+		proof, err := imt.GenerateMultiProof([]int{10, 0, 3, 4})
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		proven := []*big.Int{leaves[0], leaves[3], leaves[4], leaves[10]}
+		if !VerifyMultiProof(proven, proof, imt.Root(), WithHasher(Keccak256)) {
+			t.Error("Expected incremental multiproof of depth", depth, "to verify")
+		}
+		if VerifyMultiProof(proven, proof, imt.Root()) {
+			t.Error("Expected incremental multiproof to fail with another hasher")
+		}
+
+		if _, err := imt.GenerateMultiProof([]int{-1}); err == nil {
+			t.Error("Expected error for a negative leaf index, got nil")
+		}
+	}
+}
+
+func TestMultiProofDeduplicatesSiblings(t *testing.T) {
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(16))
+
+	// Leaves 2 and 3 share every sibling above the first level
+	proof, _ := merkleTree.GenerateMultiProof([]int{3, 2, 3})
+	if len(proof.Indices) != 2 {
+		t.Error("Expected duplicate indices to be removed, got", proof.Indices)
+	}
+	if len(proof.Siblings) != 3 {
+		t.Error("Expected 3 siblings, got", len(proof.Siblings))
+	}
+
+	proof, _ = merkleTree.GenerateMultiProof([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})
+	if len(proof.Siblings) != 0 {
+		t.Error("Expected no siblings when proving every leaf, got", len(proof.Siblings))
+	}
+}
+
+func TestVerifyMultiProofRejectsTampering(t *testing.T) {
+	leaves := testLeaves(8)
+	merkleTree := NewMerkleTreeWithLeaves(leaves)
+	root := merkleTree.Root.Data
+
+	proof, _ := merkleTree.GenerateMultiProof([]int{1, 6})
+
+	if VerifyMultiProof([]*big.Int{leaves[1], leaves[5]}, proof, root) {
+		t.Error("Expected multiproof to fail for a wrong leaf")
+	}
+
+	if VerifyMultiProof([]*big.Int{leaves[6], leaves[1]}, proof, root) {
+		t.Error("Expected multiproof to fail for leaves out of order")
+	}
+
+	truncated := *proof
+	truncated.Siblings = proof.Siblings[1:]
+	if VerifyMultiProof([]*big.Int{leaves[1], leaves[6]}, &truncated, root) {
+		t.Error("Expected multiproof to fail with missing siblings")
+	}
+
+	if _, err := merkleTree.GenerateMultiProof(nil); err == nil {
+		t.Error("Expected error for empty indices, got nil")
+	}
+	if _, err := merkleTree.GenerateMultiProof([]int{8}); err == nil {
+		t.Error("Expected error for out of range index, got nil")
+	}
+}
+
+func TestMultiProofWithArity(t *testing.T) {
+	leaves := testLeaves(16)
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithArity(4))
+
+	proof, err := merkleTree.GenerateMultiProof([]int{1, 2, 9})
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	// 2 siblings next to leaves 1 and 2, 3 next to leaf 9 and 2 on the top level
+	if len(proof.Siblings) != 7 {
+		t.Error("Expected 7 siblings, got", len(proof.Siblings))
+	}
+
+	proven := []*big.Int{leaves[1], leaves[2], leaves[9]}
+	if !VerifyMultiProof(proven, proof, merkleTree.Root.Data, WithArity(4)) {
+		t.Error("Expected arity 4 multiproof to verify")
+	}
+}
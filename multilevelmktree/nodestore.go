@@ -0,0 +1,100 @@
+package multilevelmktree
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// NodeKey identifies a node by its level, counted from the leaves, and its
+// index within that level
+type NodeKey struct {
+	Level int
+	Index int
+}
+
+func (k NodeKey) String() string {
+	return fmt.Sprintf("%d/%d", k.Level, k.Index)
+}
+
+// sortNodeKeys sorts keys level by level from the leaves up and by index within
+// a level
+func sortNodeKeys(keys []NodeKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Level != keys[j].Level {
+			return keys[i].Level < keys[j].Level
+		}
+
+		return keys[i].Index < keys[j].Index
+	})
+}
+
+// NodeStore holds the non-empty nodes of an incremental tree, so they can live
+// in memory, on disk or in a remote service
+type NodeStore interface {
+	// Get returns the value stored for key and whether there is one
+	Get(key NodeKey) (*big.Int, bool, error)
+	Put(key NodeKey, value *big.Int) error
+	Delete(key NodeKey) error
+}
+
+// MemoryNodeStore keeps nodes in memory, one slice per level. It is the default
+// store of incremental trees.
+type MemoryNodeStore struct {
+	levels [][]*big.Int
+}
+
+// NewMemoryNodeStore returns an empty in-memory store
+func NewMemoryNodeStore() *MemoryNodeStore {
+	return &MemoryNodeStore{}
+}
+
+func (s *MemoryNodeStore) Get(key NodeKey) (*big.Int, bool, error) {
+	if key.Level < 0 || key.Level >= len(s.levels) || key.Index < 0 || key.Index >= len(s.levels[key.Level]) {
+		return nil, false, nil
+	}
+	value := s.levels[key.Level][key.Index]
+
+	return value, value != nil, nil
+}
+
+func (s *MemoryNodeStore) Put(key NodeKey, value *big.Int) error {
+	if key.Level < 0 || key.Index < 0 {
+		return fmt.Errorf("invalid node key %v", key)
+	}
+	for len(s.levels) <= key.Level {
+		s.levels = append(s.levels, nil)
+	}
+
+	level := s.levels[key.Level]
+	for len(level) <= key.Index {
+		level = append(level, nil)
+	}
+	level[key.Index] = value
+	s.levels[key.Level] = level
+
+	return nil
+}
+
+func (s *MemoryNodeStore) Delete(key NodeKey) error {
+	if key.Level < 0 || key.Level >= len(s.levels) || key.Index < 0 || key.Index >= len(s.levels[key.Level]) {
+		return nil
+	}
+
+	level := s.levels[key.Level]
+	level[key.Index] = nil
+	for len(level) > 0 && level[len(level)-1] == nil {
+		level = level[:len(level)-1]
+	}
+	s.levels[key.Level] = level
+
+	return nil
+}
+
+// WithNodeStore keeps the nodes of an incremental tree in store instead of a new
+// MemoryNodeStore. The store must be empty and must not be shared between trees.
+func WithNodeStore(store NodeStore) Option {
+	return func(cfg *config) {
+		cfg.store = store
+	}
+}
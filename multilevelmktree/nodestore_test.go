@@ -0,0 +1,89 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestMemoryNodeStore(t *testing.T) {
+	store := NewMemoryNodeStore()
+	key := NodeKey{Level: 2, Index: 5}
+
+	if _, ok, _ := store.Get(key); ok {
+		t.Error("Expected empty store to have no node at", key)
+	}
+
+	store.Put(key, big.NewInt(7))
+	if value, ok, _ := store.Get(key); !ok || value.Cmp(big.NewInt(7)) != 0 {
+		t.Error("Expected node 7 at", key, "got", value)
+	}
+	if _, ok, _ := store.Get(NodeKey{Level: 2, Index: 4}); ok {
+		t.Error("Expected no node before", key)
+	}
+
+	store.Delete(key)
+	if _, ok, _ := store.Get(key); ok {
+		t.Error("Expected node at", key, "to be deleted")
+	}
+
+	if err := store.Put(NodeKey{Level: -1}, big.NewInt(1)); err == nil {
+		t.Error("Expected error for a negative level, got nil")
+	}
+}
+
+// failingStore fails every Put once puts is exhausted
+type failingStore struct {
+	*MemoryNodeStore
+	puts int
+}
+
+func (s *failingStore) Put(key NodeKey, value *big.Int) error {
+	if s.puts == 0 {
+		return errors.New("store unavailable")
+	}
+	s.puts--
+
+	return s.MemoryNodeStore.Put(key, value)
+}
+
+func TestIncrementalMerkleTreeNodeStore(t *testing.T) {
+	store := &failingStore{MemoryNodeStore: NewMemoryNodeStore(), puts: 3 * 4}
+	imt, _ := NewIncrementalMerkleTree(2, WithNodeStore(store))
+	expected, _ := NewIncrementalMerkleTree(2)
+
+	for _, leaf := range testLeaves(3) {
+		imt.Insert(leaf)
+		expected.Insert(leaf)
+	}
+	if imt.Root().Cmp(expected.Root()) != 0 {
+		t.Error("Expected store backed root to be", expected.Root(), "got", imt.Root())
+	}
+	if value, ok, _ := store.Get(NodeKey{Level: 0, Index: 2}); !ok || value.Cmp(big.NewInt(3)) != 0 {
+		t.Error("Expected leaf 3 in the store, got", value)
+	}
+
+	// A failing store leaves the tree as it was
+	store.puts = 2
+	if _, err := imt.Insert(big.NewInt(4)); err == nil {
+		t.Fatal("Expected error from the store, got nil")
+	}
+	if imt.NextIndex() != 3 || imt.Root().Cmp(expected.Root()) != 0 {
+		t.Error("Expected failed insert to leave the tree unchanged")
+	}
+
+	store.puts = 3
+	imt.Insert(big.NewInt(4))
+	expected.Insert(big.NewInt(4))
+	if imt.Root().Cmp(expected.Root()) != 0 {
+		t.Error("Expected root after retry to be", expected.Root(), "got", imt.Root())
+	}
+
+	proof, directions, err := imt.GenerateProof(3)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if !VerifyProof(big.NewInt(4), proof, directions, imt.Root()) {
+		t.Error("Expected proof from the store to verify")
+	}
+}
@@ -0,0 +1,232 @@
+package multilevelmktree
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// NullifierSet is a set of spent nullifiers kept in a sparse Merkle tree. The
+// leaf at index n holds n once nullifier n is added and the empty leaf before,
+// so every nullifier has a fixed place and the root only depends on which
+// nullifiers were added, not on their order. It is safe for concurrent use.
+type NullifierSet struct {
+	// mu guards nodes
+	mu sync.RWMutex
+
+	cfg   *config
+	depth int
+	// zeros holds the root of an empty subtree on each level
+	zeros []*big.Int
+	// nodes holds the nodes above added nullifiers
+	nodes map[sparseKey]*big.Int
+}
+
+// sparseKey is the place of a node of a NullifierSet, its index given as the
+// big-endian bytes of the index within the level
+type sparseKey struct {
+	level int
+	index string
+}
+
+// NullifierProof proves the leaf at the place of Nullifier, with one sibling
+// per level from the leaf up. The path follows the bits of the nullifier, so
+// a proof cannot be passed off for another nullifier.
+type NullifierProof struct {
+	Nullifier *big.Int
+	Siblings  []*big.Int
+}
+
+// NewNullifierSet returns an empty set for nullifiers below 2^depth. A depth of
+// 254 holds every element of the BN254 field. Empty leaves hold the hash of
+// zero. The tree must be binary with children hashed by position, so that a
+// proof is bound to the place of its nullifier.
+func NewNullifierSet(depth int, opts ...Option) (*NullifierSet, error) {
+	cfg := newConfig(opts)
+	if cfg.arity != 2 || cfg.sortPairs {
+		return nil, fmt.Errorf("invalid arity %d or sorted pairs, nullifier sets hash pairs by position", cfg.arity)
+	}
+	if depth < 1 || depth > 256 {
+		return nil, fmt.Errorf("invalid depth %d, must be between 1 and 256", depth)
+	}
+
+	zero, err := cfg.zeroLeaf()
+	if err != nil {
+		return nil, err
+	}
+
+	zeros := make([]*big.Int, depth+1)
+	zeros[0] = zero
+	for level := 1; level <= depth; level++ {
+		zeros[level], err = cfg.hashChildren([]*big.Int{zeros[level-1], zeros[level-1]})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &NullifierSet{cfg: cfg, depth: depth, zeros: zeros, nodes: map[sparseKey]*big.Int{}}, nil
+}
+
+// Depth returns the number of levels between the root and the leaves
+func (s *NullifierSet) Depth() int {
+	return s.depth
+}
+
+// Root returns the current root
+func (s *NullifierSet) Root() *big.Int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.node(s.depth, new(big.Int))
+}
+
+// node returns the node at index within level, or the empty subtree root if no
+// nullifier was added below it
+func (s *NullifierSet) node(level int, index *big.Int) *big.Int {
+	if value, ok := s.nodes[sparseKey{level, string(index.Bytes())}]; ok {
+		return value
+	}
+
+	return s.zeros[level]
+}
+
+// checkNullifier returns an error if nullifier has no place in the set
+func (s *NullifierSet) checkNullifier(nullifier *big.Int) error {
+	if nullifier == nil || nullifier.Sign() <= 0 || nullifier.BitLen() > s.depth {
+		return fmt.Errorf("invalid nullifier %v, nullifiers must be positive and below 2^%d", nullifier, s.depth)
+	}
+
+	return nil
+}
+
+// spent reports whether nullifier was added
+func (s *NullifierSet) spent(nullifier *big.Int) bool {
+	_, ok := s.nodes[sparseKey{0, string(nullifier.Bytes())}]
+
+	return ok
+}
+
+// siblings returns the siblings of the path of nullifier, from the leaf up
+func (s *NullifierSet) siblings(nullifier *big.Int) []*big.Int {
+	siblings := make([]*big.Int, s.depth)
+	index := new(big.Int).Set(nullifier)
+	for level := range siblings {
+		sibling := new(big.Int).SetBit(index, 0, index.Bit(0)^1)
+		siblings[level] = s.node(level, sibling)
+		index.Rsh(index, 1)
+	}
+
+	return siblings
+}
+
+// Add marks nullifier as spent, rehashing its path to the root. It returns
+// ErrValueExists if the nullifier was already spent. The set is left unchanged
+// on failure.
+func (s *NullifierSet) Add(nullifier *big.Int) error {
+	if err := s.checkNullifier(nullifier); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.spent(nullifier) {
+		return ErrValueExists
+	}
+
+	// Hash the new path before touching the tree, so a failure leaves it as is
+	path := make([]*big.Int, s.depth+1)
+	path[0] = nullifier
+	for level, sibling := range s.siblings(nullifier) {
+		children := []*big.Int{path[level], sibling}
+		if nullifier.Bit(level) == 1 {
+			children[0], children[1] = sibling, path[level]
+		}
+
+		parent, err := s.cfg.hashChildren(children)
+		if err != nil {
+			return err
+		}
+		path[level+1] = parent
+	}
+
+	for level, value := range path {
+		index := new(big.Int).Rsh(nullifier, uint(level))
+		s.nodes[sparseKey{level, string(index.Bytes())}] = value
+	}
+
+	return nil
+}
+
+// ProveSpent returns the proof that nullifier was added, or ErrValueNotFound
+// if it was not
+func (s *NullifierSet) ProveSpent(nullifier *big.Int) (*NullifierProof, error) {
+	if err := s.checkNullifier(nullifier); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.spent(nullifier) {
+		return nil, ErrValueNotFound
+	}
+
+	return &NullifierProof{Nullifier: nullifier, Siblings: s.siblings(nullifier)}, nil
+}
+
+// ProveUnspent returns the proof that the place of nullifier holds the empty
+// leaf, or ErrValueExists if it was added
+func (s *NullifierSet) ProveUnspent(nullifier *big.Int) (*NullifierProof, error) {
+	if err := s.checkNullifier(nullifier); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.spent(nullifier) {
+		return nil, ErrValueExists
+	}
+
+	return &NullifierProof{Nullifier: nullifier, Siblings: s.siblings(nullifier)}, nil
+}
+
+// verifyNullifierProof checks that leaf is at the place of nullifier in the set
+// with the given root
+func verifyNullifierProof(nullifier, leaf *big.Int, proof *NullifierProof, root *big.Int, opts []Option) bool {
+	cfg := newConfig(opts)
+	if cfg.arity != 2 || cfg.sortPairs {
+		return false
+	}
+	if proof == nil || nullifier == nil || proof.Nullifier == nil || proof.Nullifier.Cmp(nullifier) != 0 {
+		return false
+	}
+	if nullifier.Sign() <= 0 || nullifier.BitLen() > len(proof.Siblings) {
+		return false
+	}
+
+	directions := make([]int, len(proof.Siblings))
+	for level := range directions {
+		directions[level] = int(nullifier.Bit(level))
+	}
+
+	return VerifyProof(leaf, proof.Siblings, directions, root, opts...)
+}
+
+// VerifySpent checks that proof shows nullifier spent in the set with the given
+// root. The options must match those the set was built with.
+func VerifySpent(nullifier *big.Int, proof *NullifierProof, root *big.Int, opts ...Option) bool {
+	return verifyNullifierProof(nullifier, nullifier, proof, root, opts)
+}
+
+// VerifyUnspent checks that proof shows nullifier not spent in the set with
+// the given root. The options must match those the set was built with.
+func VerifyUnspent(nullifier *big.Int, proof *NullifierProof, root *big.Int, opts ...Option) bool {
+	zero, err := newConfig(opts).zeroLeaf()
+	if err != nil {
+		return false
+	}
+
+	return verifyNullifierProof(nullifier, zero, proof, root, opts)
+}
@@ -0,0 +1,136 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestNullifierSetOrderIndependent(t *testing.T) {
+	empty, err := NewNullifierSet(8)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	var roots []*big.Int
+	for _, order := range [][]int64{{5, 200, 17, 3}, {3, 17, 200, 5}, {200, 3, 5, 17}} {
+		set, err := NewNullifierSet(8)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		for _, n := range order {
+			if err := set.Add(big.NewInt(n)); err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+		}
+		roots = append(roots, set.Root())
+	}
+
+	if roots[0].Cmp(empty.Root()) == 0 {
+		t.Error("Expected the root to change once nullifiers are added")
+	}
+	for i, root := range roots[1:] {
+		if root.Cmp(roots[0]) != 0 {
+			t.Error("Expected the root of order", i+1, "to be", roots[0], "got", root)
+		}
+	}
+}
+
+func TestNullifierSetProofs(t *testing.T) {
+	set, err := NewNullifierSet(254)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	spent := []*big.Int{big.NewInt(12345), new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 253), big.NewInt(7))}
+	unspent := big.NewInt(12344)
+	before, err := set.ProveUnspent(spent[0])
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	for _, n := range spent {
+		if err := set.Add(n); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+	root := set.Root()
+
+	for _, n := range spent {
+		proof, err := set.ProveSpent(n)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if !VerifySpent(n, proof, root) {
+			t.Error("Expected the proof of", n, "to show it spent")
+		}
+		if VerifyUnspent(n, proof, root) {
+			t.Error("Expected the proof of", n, "not to show it unspent")
+		}
+	}
+
+	proof, err := set.ProveUnspent(unspent)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if !VerifyUnspent(unspent, proof, root) {
+		t.Error("Expected the proof of", unspent, "to show it unspent")
+	}
+	if VerifySpent(unspent, proof, root) {
+		t.Error("Expected the proof of", unspent, "not to show it spent")
+	}
+	if VerifyUnspent(spent[0], before, root) {
+		t.Error("Expected a proof from before", spent[0], "was added to fail against the new root")
+	}
+
+	// The path follows the nullifier, so changing it breaks the proof
+	proof.Nullifier = big.NewInt(12346)
+	if VerifyUnspent(big.NewInt(12346), proof, root) {
+		t.Error("Expected the proof of", unspent, "to fail for another nullifier")
+	}
+	if VerifyUnspent(unspent, &NullifierProof{Nullifier: unspent, Siblings: proof.Siblings[:10]}, root) {
+		t.Error("Expected a truncated proof to fail")
+	}
+}
+
+func TestNullifierSetErrors(t *testing.T) {
+	set, err := NewNullifierSet(8)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if err := set.Add(big.NewInt(9)); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	root := set.Root()
+
+	if err := set.Add(big.NewInt(9)); !errors.Is(err, ErrValueExists) {
+		t.Error("Expected ErrValueExists adding a spent nullifier, got", err)
+	}
+	if _, err := set.ProveUnspent(big.NewInt(9)); !errors.Is(err, ErrValueExists) {
+		t.Error("Expected ErrValueExists proving a spent nullifier unspent, got", err)
+	}
+	if _, err := set.ProveSpent(big.NewInt(10)); !errors.Is(err, ErrValueNotFound) {
+		t.Error("Expected ErrValueNotFound proving an unspent nullifier spent, got", err)
+	}
+	for _, n := range []*big.Int{nil, big.NewInt(0), big.NewInt(-1), big.NewInt(256)} {
+		if err := set.Add(n); err == nil {
+			t.Error("Expected error adding nullifier", n, "got nil")
+		}
+		if _, err := set.ProveUnspent(n); err == nil {
+			t.Error("Expected error proving nullifier", n, "got nil")
+		}
+	}
+	if set.Root().Cmp(root) != 0 {
+		t.Error("Expected failed calls to leave the root", root, "got", set.Root())
+	}
+
+	for _, opts := range [][]Option{{WithArity(4)}, {WithSortedPairs()}} {
+		if _, err := NewNullifierSet(8, opts...); err == nil {
+			t.Error("Expected error for options", opts, "got nil")
+		}
+	}
+	for _, depth := range []int{0, 257} {
+		if _, err := NewNullifierSet(depth); err == nil {
+			t.Error("Expected error for depth", depth, "got nil")
+		}
+	}
+}
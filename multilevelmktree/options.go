@@ -0,0 +1,143 @@
+package multilevelmktree
+
+import (
+	"math/big"
+	"runtime"
+	"sort"
+)
+
+// Option configures how a tree is built and how its proofs are verified
+type Option func(*config)
+
+type config struct {
+	hasher  Hasher
+	arity   int
+	padding Padding
+	// sortPairs hashes children in ascending order instead of by position
+	sortPairs bool
+	// rootHistory is the number of recent roots an incremental tree accepts
+	rootHistory int
+	// zero overrides the value of empty leaves when set
+	zero *big.Int
+	// store holds the nodes of an incremental tree when set
+	store NodeStore
+	// leafIndex keeps a map from leaf values to their index
+	leafIndex bool
+	// uniqueLeaves rejects repeated leaf values when building from a slice
+	uniqueLeaves bool
+	// leafHook is called with every leaf change
+	leafHook func(LeafEvent)
+	// domainTag is hashed before the children of every internal node when set
+	domainTag *big.Int
+	// branchTrees keeps the branch trees of a BranchedTree
+	branchTrees bool
+	// branchDepth is the depth of the branches of deterministic trees
+	branchDepth int
+	// leafGenerator returns the leaves of deterministic trees when set
+	leafGenerator func(index int) *big.Int
+	// workers is the number of goroutines building a tree, GOMAXPROCS when not
+	// positive
+	workers int
+}
+
+// numWorkers returns the number of goroutines building a tree
+func (cfg *config) numWorkers() int {
+	if cfg.workers < 1 {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	return cfg.workers
+}
+
+// hashChildren hashes the values of sibling nodes into their parent
+func (cfg *config) hashChildren(children []*big.Int) (*big.Int, error) {
+	if cfg.sortPairs {
+		children = append([]*big.Int(nil), children...)
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].Cmp(children[j]) < 0
+		})
+	}
+	if cfg.domainTag != nil {
+		children = append([]*big.Int{cfg.domainTag}, children...)
+	}
+
+	return cfg.hasher.Hash(children)
+}
+
+func newConfig(opts []Option) *config {
+	cfg := config{
+		hasher:  Poseidon,
+		arity:   2,
+		padding: PadError,
+
+		rootHistory: DefaultRootHistorySize,
+		branchDepth: DefaultBranchDepth,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &cfg
+}
+
+// WithHasher selects the hash function used for leaves and internal nodes.
+// Trees use Poseidon by default.
+func WithHasher(h Hasher) Option {
+	return func(cfg *config) {
+		cfg.hasher = h
+	}
+}
+
+// WithArity sets the number of children hashed together by every internal node.
+// Arities above two hash all children with a single call, which must be
+// supported by the hasher (Poseidon takes at most 16 inputs). Trees are binary
+// by default.
+func WithArity(arity int) Option {
+	return func(cfg *config) {
+		cfg.arity = arity
+	}
+}
+
+// WithPadding selects how trees over a number of leaves that is not a power of
+// the arity are completed. Such trees are rejected by default.
+func WithPadding(padding Padding) Option {
+	return func(cfg *config) {
+		cfg.padding = padding
+	}
+}
+
+// WithZeroLeaf sets the value of empty leaves, used by PadZeroHash and by the
+// empty subtrees of incremental trees, to match protocols that use raw zero or
+// a value such as keccak256("empty") instead of the default hash of zero
+func WithZeroLeaf(value *big.Int) Option {
+	return func(cfg *config) {
+		cfg.zero = value
+	}
+}
+
+// WithDomainTag mixes tag into every internal node, hashing it before the
+// children like Poseidon(tag, left, right), so trees of different protocols
+// cannot share roots. Leaves are not affected. The tag takes one of the inputs
+// of the hasher, so Poseidon trees can have an arity of at most 15.
+func WithDomainTag(tag *big.Int) Option {
+	return func(cfg *config) {
+		cfg.domainTag = tag
+	}
+}
+
+// WithSortedPairs hashes the children of every node in ascending order, so
+// proofs do not depend on direction bits. Combined with Keccak256 this matches
+// OpenZeppelin's MerkleProof.verify.
+func WithSortedPairs() Option {
+	return func(cfg *config) {
+		cfg.sortPairs = true
+	}
+}
+
+// WithRootHistory sets how many of the most recent roots an incremental tree
+// remembers for IsKnownRoot, DefaultRootHistorySize by default
+func WithRootHistory(size int) Option {
+	return func(cfg *config) {
+		cfg.rootHistory = size
+	}
+}
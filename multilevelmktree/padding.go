@@ -0,0 +1,113 @@
+package multilevelmktree
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Padding selects how a tree is completed when the number of leaves is not a
+// power of the arity
+type Padding int
+
+const (
+	// PadError refuses to build the tree
+	PadError Padding = iota
+	// PadZeroHash appends empty leaves, the hash of zero unless set with
+	// WithZeroLeaf
+	PadZeroHash
+	// PadDuplicateLast appends copies of the last leaf
+	PadDuplicateLast
+	// PadDuplicateOdd repeats the last node of every level that does not fill
+	// its parent, following Bitcoin's consensus rules for block Merkle roots
+	PadDuplicateOdd
+	// PadPromoteOdd carries the last node of every odd level up unchanged, which
+	// gives the left-balanced trees of RFC 6962. Only binary trees support it.
+	PadPromoteOdd
+)
+
+func (p Padding) String() string {
+	switch p {
+	case PadError:
+		return "error"
+	case PadZeroHash:
+		return "zero-hash"
+	case PadDuplicateLast:
+		return "duplicate-last"
+	case PadDuplicateOdd:
+		return "duplicate-odd"
+	case PadPromoteOdd:
+		return "promote-odd"
+	default:
+		return fmt.Sprintf("Padding(%d)", int(p))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler using the names returned by
+// String
+func (p Padding) MarshalText() ([]byte, error) {
+	if p < PadError || p > PadPromoteOdd {
+		return nil, fmt.Errorf("unknown padding %v", p)
+	}
+
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (p *Padding) UnmarshalText(text []byte) error {
+	for padding := PadError; padding <= PadPromoteOdd; padding++ {
+		if padding.String() == string(text) {
+			*p = padding
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown padding %q", text)
+}
+
+// zeroLeaf returns the value of an empty leaf, the hash of zero unless set with
+// WithZeroLeaf
+func (cfg *config) zeroLeaf() (*big.Int, error) {
+	if cfg.zero != nil {
+		return cfg.zero, nil
+	}
+
+	return cfg.hasher.Hash([]*big.Int{big.NewInt(0)})
+}
+
+// padLeaves returns leaves completed up to the next power of the arity
+func padLeaves(leaves []*big.Int, cfg *config) ([]*big.Int, error) {
+	size := 1
+	for size < len(leaves) {
+		size *= cfg.arity
+	}
+	if size == len(leaves) {
+		return leaves, nil
+	}
+
+	var pad *big.Int
+	switch cfg.padding {
+	case PadZeroHash:
+		zero, err := cfg.zeroLeaf()
+		if err != nil {
+			return nil, err
+		}
+		pad = zero
+	case PadDuplicateLast:
+		pad = leaves[len(leaves)-1]
+	case PadDuplicateOdd, PadPromoteOdd:
+		// Completed level by level while building the tree
+		return leaves, nil
+	case PadError:
+		return nil, fmt.Errorf("%w: got %d leaves for arity %d", ErrLeafCount, len(leaves), cfg.arity)
+	default:
+		return nil, fmt.Errorf("unknown padding %v", cfg.padding)
+	}
+
+	padded := make([]*big.Int, size)
+	copy(padded, leaves)
+	for i := len(leaves); i < size; i++ {
+		padded[i] = pad
+	}
+
+	return padded, nil
+}
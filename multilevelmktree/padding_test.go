@@ -0,0 +1,185 @@
+package multilevelmktree
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+func TestPadding(t *testing.T) {
+	leaves := testLeaves(5)
+	zero, _ := poseidon.Hash([]*big.Int{big.NewInt(0)})
+
+	cases := []struct {
+		padding Padding
+		pad     *big.Int
+	}{
+		{PadZeroHash, zero},
+		{PadDuplicateLast, leaves[4]},
+	}
+
+	for _, c := range cases {
+		padded := append(append([]*big.Int(nil), leaves...), c.pad, c.pad, c.pad)
+		expected := NewMerkleTreeWithLeaves(padded).Root.Data
+
+		merkleTree := NewMerkleTreeWithLeaves(leaves, WithPadding(c.padding))
+		if merkleTree.Root.Data.Cmp(expected) != 0 {
+			t.Error("Expected", c.padding, "root to be", expected, "got", merkleTree.Root.Data)
+		}
+
+		proof, directions, err := merkleTree.GenerateProof(4)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if !VerifyProof(leaves[4], proof, directions, expected) {
+			t.Error("Expected", c.padding, "proof to verify")
+		}
+		if _, _, err := merkleTree.GenerateProof(5); err == nil {
+			t.Error("Expected error for proving a padding leaf, got nil")
+		}
+	}
+}
+
+// countingHasher counts the calls to the hasher it wraps
+type countingHasher struct {
+	Hasher
+	calls int
+}
+
+func (h *countingHasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	h.calls++
+
+	return h.Hasher.Hash(inputs)
+}
+
+func TestPaddingSubtreesHashedOnce(t *testing.T) {
+	leaves := testLeaves(17)
+	for _, padding := range []Padding{PadZeroHash, PadDuplicateLast} {
+		hasher := &countingHasher{Hasher: Poseidon}
+		merkleTree := NewMerkleTreeWithLeaves(leaves, WithPadding(padding), WithHasher(hasher))
+
+		padded := merkleTree.levels[0]
+		full := make([]*big.Int, len(padded))
+		for i := range padded {
+			full[i] = padded[i].Data
+		}
+		if expected := NewMerkleTreeWithLeaves(full).Root.Data; merkleTree.Root.Data.Cmp(expected) != 0 {
+			t.Error("Expected", padding, "root to be", expected, "got", merkleTree.Root.Data)
+		}
+
+		// 9+1, 5+1, 3+1, 2 and 1 parents from 17 leaves padded to 32, instead
+		// of 31
+		expected := 23
+		if padding == PadZeroHash {
+			// The empty leaf
+			expected++
+		}
+		if hasher.calls != expected {
+			t.Error("Expected", expected, "hashes for", padding, "got", hasher.calls)
+		}
+	}
+}
+
+func TestPaddingError(t *testing.T) {
+	leaves := testLeaves(6)
+
+	if _, err := buildMerkleTree(context.Background(), leaves, newConfig(nil)); !errors.Is(err, ErrLeafCount) {
+		t.Error("Expected ErrLeafCount, got", err)
+	}
+	if _, err := buildMerkleTree(context.Background(), testLeaves(16), newConfig([]Option{WithArity(8)})); !errors.Is(err, ErrLeafCount) {
+		t.Error("Expected ErrLeafCount for 16 leaves of arity 8, got", err)
+	}
+	if _, err := buildMerkleTree(context.Background(), nil, newConfig(nil)); !errors.Is(err, ErrNoLeaves) {
+		t.Error("Expected ErrNoLeaves, got", err)
+	}
+
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrLeafCount) {
+			t.Error("Expected panic with ErrLeafCount, got", err)
+		}
+	}()
+	NewMerkleTreeWithLeaves(leaves)
+}
+
+func TestPadDuplicateOdd(t *testing.T) {
+	leaves := testLeaves(5)
+
+	// 5 leaves pair up as (0,1) (2,3) (4,4), then ((01),(23)) ((44),(44))
+	l0, _ := poseidon.Hash([]*big.Int{leaves[0], leaves[1]})
+	l1, _ := poseidon.Hash([]*big.Int{leaves[2], leaves[3]})
+	l2, _ := poseidon.Hash([]*big.Int{leaves[4], leaves[4]})
+	m0, _ := poseidon.Hash([]*big.Int{l0, l1})
+	m1, _ := poseidon.Hash([]*big.Int{l2, l2})
+	expected, _ := poseidon.Hash([]*big.Int{m0, m1})
+
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithPadding(PadDuplicateOdd))
+	if merkleTree.Root.Data.Cmp(expected) != 0 {
+		t.Error("Expected root to be", expected, "got", merkleTree.Root.Data)
+	}
+
+	for i, leaf := range leaves {
+		proof, directions, _ := merkleTree.GenerateProof(i)
+		if !VerifyProof(leaf, proof, directions, expected) {
+			t.Error("Expected proof for leaf", i, "to verify")
+		}
+	}
+
+	multiProof, _ := merkleTree.GenerateMultiProof([]int{3, 4})
+	if !VerifyMultiProof([]*big.Int{leaves[3], leaves[4]}, multiProof, expected) {
+		t.Error("Expected multiproof to verify")
+	}
+}
+
+func TestBitcoinMerkleRoot(t *testing.T) {
+	// Transactions of Bitcoin block 100000
+	txids := []string{
+		"8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87",
+		"fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4",
+		"6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4",
+		"e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d",
+	}
+	expected, _ := new(big.Int).SetString("f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766", 16)
+
+	leaves := make([]*big.Int, len(txids))
+	for i, txid := range txids {
+		leaves[i], _ = new(big.Int).SetString(txid, 16)
+	}
+
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithHasher(SHA256d), WithPadding(PadDuplicateOdd))
+	if merkleTree.Root.Data.Cmp(expected) != 0 {
+		t.Errorf("Expected Merkle root %x, got %x", expected, merkleTree.Root.Data)
+	}
+
+	// Three transactions duplicate the last one
+	odd := NewMerkleTreeWithLeaves(leaves[:3], WithHasher(SHA256d), WithPadding(PadDuplicateOdd))
+	even := NewMerkleTreeWithLeaves(append(leaves[:3:3], leaves[2]), WithHasher(SHA256d))
+	if odd.Root.Data.Cmp(even.Root.Data) != 0 {
+		t.Error("Expected odd level to duplicate its last node")
+	}
+}
+
+func TestZeroLeaf(t *testing.T) {
+	empty := keccakShifted([]byte("empty"))
+
+	for _, zero := range []*big.Int{big.NewInt(0), empty} {
+		leaves := testLeaves(3)
+		padded := append(append([]*big.Int(nil), leaves...), zero)
+		expected := NewMerkleTreeWithLeaves(padded).Root.Data
+
+		merkleTree := NewMerkleTreeWithLeaves(leaves, WithPadding(PadZeroHash), WithZeroLeaf(zero))
+		if merkleTree.Root.Data.Cmp(expected) != 0 {
+			t.Error("Expected root padded with", zero, "to be", expected, "got", merkleTree.Root.Data)
+		}
+
+		imt, _ := NewIncrementalMerkleTree(2, WithZeroLeaf(zero))
+		for _, leaf := range leaves {
+			imt.Insert(leaf)
+		}
+		if imt.Root().Cmp(expected) != 0 {
+			t.Error("Expected incremental root with empty leaf", zero, "to be", expected, "got", imt.Root())
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package multilevelmktree
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// runParallel calls run for every i below n on up to workers goroutines,
+// GOMAXPROCS if workers is not positive. Indices are handed out in order and
+// none are started once a call fails, returning the error of the leftmost
+// failing index.
+func runParallel(n, workers int, run func(i int) error) error {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	if workers < 2 {
+		for i := 0; i < n; i++ {
+			if err := run(i); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	var next atomic.Int64
+	var failed atomic.Bool
+	errs := make([]error, workers)
+	failedAt := make([]int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				if err := run(i); err != nil {
+					errs[w], failedAt[w] = err, i
+					failed.Store(true)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	var err error
+	leftmost := n
+	for w := range errs {
+		if errs[w] != nil && failedAt[w] < leftmost {
+			err, leftmost = errs[w], failedAt[w]
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,59 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunParallel(t *testing.T) {
+	for _, workers := range []int{0, 1, 3, 100} {
+		var mu sync.Mutex
+		seen := make([]bool, 50)
+		var running, most atomic.Int64
+		err := runParallel(len(seen), workers, func(i int) error {
+			if n := running.Add(1); n > most.Load() {
+				most.Store(n)
+			}
+			defer running.Add(-1)
+
+			mu.Lock()
+			defer mu.Unlock()
+			seen[i] = true
+
+			return nil
+		})
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		for i, ok := range seen {
+			if !ok {
+				t.Fatal("Expected index", i, "to run with", workers, "workers")
+			}
+		}
+		if workers > 0 && most.Load() > int64(workers) {
+			t.Error("Expected at most", workers, "calls at once, got", most.Load())
+		}
+	}
+}
+
+func TestRunParallelLeftmostError(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		err := runParallel(20, workers, func(i int) error {
+			if i == 7 || i == 12 {
+				return fmt.Errorf("index %d", i)
+			}
+
+			return nil
+		})
+		if err == nil || err.Error() != "index 7" {
+			t.Error("Expected the error of index 7 with", workers, "workers, got", err)
+		}
+	}
+
+	if err := runParallel(0, 4, func(int) error { return errors.New("called") }); err != nil {
+		t.Error("Expected no calls for n = 0, got", err)
+	}
+}
@@ -0,0 +1,66 @@
+package multilevelmktree
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// LeafHasher maps a leaf payload to the value stored in a tree
+type LeafHasher[T any] interface {
+	HashLeaf(payload T) (*big.Int, error)
+}
+
+// LeafHasherFunc adapts a function to a LeafHasher
+type LeafHasherFunc[T any] func(payload T) (*big.Int, error)
+
+func (f LeafHasherFunc[T]) HashLeaf(payload T) (*big.Int, error) {
+	return f(payload)
+}
+
+// keccakShifted returns keccak256(data) >> 8, which fits the field of every
+// built-in hasher
+func keccakShifted(data []byte) *big.Int {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(data)
+	value := new(big.Int).SetBytes(hash.Sum(nil))
+
+	return value.Rsh(value, 8)
+}
+
+// BytesLeafHasher hashes byte payloads to keccak256(payload) >> 8, the way
+// Semaphore hashes signals and external nullifiers into the field
+var BytesLeafHasher LeafHasher[[]byte] = LeafHasherFunc[[]byte](func(payload []byte) (*big.Int, error) {
+	return keccakShifted(payload), nil
+})
+
+// JSONLeafHasher returns a LeafHasher hashing payloads of any type, such as
+// structs, by their JSON encoding with BytesLeafHasher
+func JSONLeafHasher[T any]() LeafHasher[T] {
+	return LeafHasherFunc[T](func(payload T) (*big.Int, error) {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return keccakShifted(encoded), nil
+	})
+}
+
+// NewMerkleTreeWithPayloads builds a tree over the values leafHasher maps the
+// payloads to. Proofs are verified against the same values, e.g.
+// VerifyProof(leafHasher.HashLeaf(payload)).
+func NewMerkleTreeWithPayloads[T any](payloads []T, leafHasher LeafHasher[T], opts ...Option) (*MerkleTree, error) {
+	leaves := make([]*big.Int, len(payloads))
+	for i, payload := range payloads {
+		leaf, err := leafHasher.HashLeaf(payload)
+		if err != nil {
+			return nil, &HashError{Level: 0, Index: i, Err: err}
+		}
+		leaves[i] = leaf
+	}
+
+	return buildMerkleTree(context.Background(), leaves, newConfig(opts))
+}
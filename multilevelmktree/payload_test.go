@@ -0,0 +1,61 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestNewMerkleTreeWithPayloads(t *testing.T) {
+	payloads := [][]byte{[]byte("alice"), []byte("bob"), []byte("carol"), []byte("dave")}
+
+	merkleTree, err := NewMerkleTreeWithPayloads(payloads, BytesLeafHasher)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	leaves := make([]*big.Int, len(payloads))
+	for i, payload := range payloads {
+		leaves[i], _ = BytesLeafHasher.HashLeaf(payload)
+		if leaves[i].BitLen() > 248 {
+			t.Error("Expected leaf to fit in 248 bits, got", leaves[i].BitLen())
+		}
+	}
+	if expected := NewMerkleTreeWithLeaves(leaves).Root.Data; merkleTree.Root.Data.Cmp(expected) != 0 {
+		t.Error("Expected payload root", expected, "got", merkleTree.Root.Data)
+	}
+
+	proof, directions, _ := merkleTree.GenerateProof(1)
+	leaf, _ := BytesLeafHasher.HashLeaf([]byte("bob"))
+	if !VerifyProof(leaf, proof, directions, merkleTree.Root.Data) {
+		t.Error("Expected proof for the hashed payload to verify")
+	}
+}
+
+func TestStructPayloads(t *testing.T) {
+	type account struct {
+		Address string
+		Balance int
+	}
+	accounts := []account{{"0x01", 10}, {"0x02", 20}}
+
+	merkleTree, err := NewMerkleTreeWithPayloads(accounts, JSONLeafHasher[account]())
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if changed, _ := JSONLeafHasher[account]().HashLeaf(account{"0x01", 11}); changed.Cmp(merkleTree.levels[0][0].Data) == 0 {
+		t.Error("Expected a different leaf for a different payload")
+	}
+
+	// Custom leaf hashers report failures with the payload index
+	var failing LeafHasher[account] = LeafHasherFunc[account](func(a account) (*big.Int, error) {
+		if a.Balance > 15 {
+			return nil, errors.New("balance too large")
+		}
+		return big.NewInt(int64(a.Balance)), nil
+	})
+	var hashErr *HashError
+	if _, err := NewMerkleTreeWithPayloads(accounts, failing); !errors.As(err, &hashErr) || hashErr.Index != 1 {
+		t.Error("Expected hash error for payload 1, got", err)
+	}
+}
@@ -0,0 +1,234 @@
+package multilevelmktree
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+// blsModulus is the order of the BLS12-381 scalar field
+var blsModulus, _ = new(big.Int).SetString("52435875175126190479447740508185965837690552500527637822603658699938581184513", 10)
+
+const poseidonFullRounds = 8
+
+var big5 = big.NewInt(5)
+
+// poseidonParams holds the round constants and MDS matrix for one state width
+type poseidonParams struct {
+	roundsP   int
+	constants []*big.Int
+	mds       [][]*big.Int
+}
+
+// fieldPoseidon is Poseidon with an x^5 S-box over an arbitrary prime field.
+// Parameters are generated per state width on first use.
+type fieldPoseidon struct {
+	name    string
+	modulus *big.Int
+
+	mu     sync.Mutex
+	params map[int]*poseidonParams
+}
+
+// NewPoseidonHasher returns a Poseidon hasher over the prime field of the given
+// modulus. Round constants and MDS matrices are derived with the Grain LFSR of
+// the reference implementation (generate_parameters_grain.sage), so the BN254
+// instance matches circomlib and iden3 and other fields match the reference
+// test vectors. The MDS security checks of the reference script are not run.
+func NewPoseidonHasher(name string, modulus *big.Int) (Hasher, error) {
+	pMinusOne := new(big.Int).Sub(modulus, big.NewInt(1))
+	if modulus.Sign() <= 0 || new(big.Int).GCD(nil, nil, big5, pMinusOne).Cmp(big.NewInt(1)) != 0 {
+		return nil, fmt.Errorf("x^5 is not a permutation of the field of modulus %s", modulus)
+	}
+
+	return &fieldPoseidon{
+		name:    name,
+		modulus: new(big.Int).Set(modulus),
+		params:  map[int]*poseidonParams{},
+	}, nil
+}
+
+func (h *fieldPoseidon) Name() string { return h.name }
+
+// Hash computes the Poseidon hash of up to 16 inputs, using a state of width
+// len(inputs)+1 with the capacity element first like circomlib
+func (h *fieldPoseidon) Hash(inputs []*big.Int) (*big.Int, error) {
+	t := len(inputs) + 1
+	if len(inputs) == 0 || len(inputs) > len(poseidon.NROUNDSP) {
+		return nil, fmt.Errorf("invalid inputs length %d, max %d", len(inputs), len(poseidon.NROUNDSP))
+	}
+	if err := checkInField(inputs, h.modulus); err != nil {
+		return nil, err
+	}
+
+	params := h.paramsFor(t)
+	p := h.modulus
+
+	state := make([]*big.Int, t)
+	state[0] = big.NewInt(0)
+	for i, input := range inputs {
+		state[i+1] = new(big.Int).Set(input)
+	}
+
+	rounds := poseidonFullRounds + params.roundsP
+	mixed := make([]*big.Int, t)
+	for i := range mixed {
+		mixed[i] = new(big.Int)
+	}
+	mul := new(big.Int)
+	for r := 0; r < rounds; r++ {
+		for i := range state {
+			state[i].Add(state[i], params.constants[r*t+i]).Mod(state[i], p)
+		}
+
+		full := r < poseidonFullRounds/2 || r >= poseidonFullRounds/2+params.roundsP
+		for i := range state {
+			if full || i == 0 {
+				state[i].Exp(state[i], big5, p)
+			}
+		}
+
+		for i := range mixed {
+			mixed[i].SetUint64(0)
+			for j := range state {
+				mixed[i].Add(mixed[i], mul.Mul(params.mds[i][j], state[j]))
+			}
+			mixed[i].Mod(mixed[i], p)
+		}
+		state, mixed = mixed, state
+	}
+
+	return state[0], nil
+}
+
+func (h *fieldPoseidon) paramsFor(t int) *poseidonParams {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	params, ok := h.params[t]
+	if !ok {
+		params = generatePoseidonParams(h.modulus, t, poseidon.NROUNDSP[t-2])
+		h.params[t] = params
+	}
+
+	return params
+}
+
+// grainLFSR is the self-shrinking Grain LFSR used by the Poseidon reference
+// implementation to derive parameters
+type grainLFSR struct {
+	state []byte
+}
+
+func newGrainLFSR(n, t, roundsF, roundsP int) *grainLFSR {
+	g := grainLFSR{state: make([]byte, 0, 80)}
+	push := func(value, width int) {
+		for i := width - 1; i >= 0; i-- {
+			g.state = append(g.state, byte((value>>i)&1))
+		}
+	}
+
+	push(1, 2) // prime field
+	push(0, 4) // x^alpha S-box
+	push(n, 12)
+	push(t, 12)
+	push(roundsF, 10)
+	push(roundsP, 10)
+	push(1<<30-1, 30)
+
+	for i := 0; i < 160; i++ {
+		g.update()
+	}
+
+	return &g
+}
+
+func (g *grainLFSR) update() byte {
+	s := g.state
+	bit := s[62] ^ s[51] ^ s[38] ^ s[23] ^ s[13] ^ s[0]
+	copy(s, s[1:])
+	s[len(s)-1] = bit
+
+	return bit
+}
+
+func (g *grainLFSR) nextBit() byte {
+	for g.update() == 0 {
+		g.update()
+	}
+
+	return g.update()
+}
+
+func (g *grainLFSR) nextInt(n int) *big.Int {
+	r := new(big.Int)
+	for i := 0; i < n; i++ {
+		r.Lsh(r, 1)
+		r.SetBit(r, 0, uint(g.nextBit()))
+	}
+
+	return r
+}
+
+func generatePoseidonParams(p *big.Int, t, roundsP int) *poseidonParams {
+	n := p.BitLen()
+	g := newGrainLFSR(n, t, poseidonFullRounds, roundsP)
+
+	params := poseidonParams{roundsP: roundsP}
+	for i := 0; i < (poseidonFullRounds+roundsP)*t; i++ {
+		c := g.nextInt(n)
+		for c.Cmp(p) >= 0 {
+			c = g.nextInt(n)
+		}
+		params.constants = append(params.constants, c)
+	}
+
+	// Cauchy matrix 1/(x_i + y_j) over 2t distinct random elements
+	for params.mds == nil {
+		xs := make([]*big.Int, 2*t)
+		for distinct := false; !distinct; {
+			seen := map[string]bool{}
+			distinct = true
+			for i := range xs {
+				xs[i] = g.nextInt(n)
+				xs[i].Mod(xs[i], p)
+				distinct = distinct && !seen[xs[i].String()]
+				seen[xs[i].String()] = true
+			}
+		}
+
+		mds := make([][]*big.Int, t)
+		for i := range mds {
+			mds[i] = make([]*big.Int, t)
+			for j := range mds[i] {
+				sum := new(big.Int).Add(xs[i], xs[t+j])
+				if sum.Mod(sum, p).Sign() == 0 {
+					mds = nil
+					break
+				}
+				mds[i][j] = sum.ModInverse(sum, p)
+			}
+			if mds == nil {
+				break
+			}
+		}
+		params.mds = mds
+	}
+
+	return &params
+}
+
+func mustPoseidonHasher(name string, modulus *big.Int) Hasher {
+	h, err := NewPoseidonHasher(name, modulus)
+	if err != nil {
+		panic(err)
+	}
+
+	return h
+}
+
+// PoseidonBLS12381 hashes with Poseidon over the BLS12-381 scalar field, for
+// trees consumed by gnark or arkworks circuits targeting BLS12-381.
+var PoseidonBLS12381 = registerHasher(mustPoseidonHasher("poseidon-bls12-381", blsModulus))
@@ -0,0 +1,543 @@
+package multilevelmktree
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// nodeAt returns the node at index within the given level, where level 0 holds
+// the leaves. Indices past the end of a level refer to its last node, which is
+// repeated to complete the level with PadDuplicateOdd.
+func (t *MerkleTree) nodeAt(level, index int) *MerkleNode {
+	nodes := t.levels[level]
+	if index >= len(nodes) {
+		index = len(nodes) - 1
+	}
+
+	return &nodes[index]
+}
+
+// GenerateProof returns the sibling hashes and direction bits proving the
+// inclusion of the leaf at leafIndex, ordered from the leaf up to the root.
+// A direction of 0 means the node on the path is the left child at that level,
+// 1 means it is the right child. In trees with a higher arity every level
+// contributes arity-1 siblings in child order and the direction is the
+// position of the path node among its siblings. Levels where the path node is
+// carried up unchanged by PadPromoteOdd contribute nothing.
+func (t *MerkleTree) GenerateProof(leafIndex int) ([]*big.Int, []int, error) {
+	depth := t.Depth()
+	arity := t.cfg.arity
+	numLeaves := t.numLeaves
+	if leafIndex < 0 || leafIndex >= numLeaves {
+		return nil, nil, fmt.Errorf("leaf index %d out of range [0, %d)", leafIndex, numLeaves)
+	}
+
+	proof := make([]*big.Int, 0, depth*(arity-1))
+	directions := make([]int, 0, depth)
+
+	index := leafIndex
+	for level := 0; level < depth; level++ {
+		position := index % arity
+		first := index - position
+		if t.cfg.padding == PadPromoteOdd && first+1 == len(t.levels[level]) {
+			index /= arity
+			continue
+		}
+
+		for j := first; j < first+arity; j++ {
+			if j != index {
+				proof = append(proof, t.nodeAt(level, j).Data)
+			}
+		}
+
+		directions = append(directions, position)
+		index /= arity
+	}
+
+	return proof, directions, nil
+}
+
+// VerifyProof checks that leaf, combined with the sibling hashes and direction
+// bits produced by GenerateProof, hashes up to root. The options must select the
+// same hasher and arity the tree was built with. With WithSortedPairs the
+// direction bits do not matter and may be nil.
+func VerifyProof(leaf *big.Int, proof []*big.Int, directions []int, root *big.Int, opts ...Option) bool {
+	return VerifyProofDetailed(leaf, proof, directions, root, opts...).Valid
+}
+
+// ErrRootMismatch is the error of a Verification whose computed root differs
+// from the expected one
+var ErrRootMismatch = errors.New("computed root does not match")
+
+// Verification is the detailed result of checking a proof, for example to find
+// where a circuit disagrees
+type Verification struct {
+	Valid bool
+	// Root is the root computed from the leaf and the proof
+	Root *big.Int
+	// Trace holds the node on the path at each level, from the leaf up to the
+	// last one computed
+	Trace []*big.Int
+	// Level is the level at which verification failed, the depth of the proof
+	// when only the root differs
+	Level int
+	// Err says why the proof does not verify, nil if it does
+	Err error
+}
+
+// VerifyProofDetailed checks a proof like VerifyProof, returning the computed
+// path and the reason it fails instead of a bool
+func VerifyProofDetailed(leaf *big.Int, proof []*big.Int, directions []int, root *big.Int, opts ...Option) *Verification {
+	cfg := newConfig(opts)
+	siblings := cfg.arity - 1
+	if cfg.sortPairs && directions == nil && siblings > 0 {
+		directions = make([]int, len(proof)/siblings)
+	}
+	if siblings < 1 || len(proof) != len(directions)*siblings {
+		return &Verification{Err: fmt.Errorf("got %d siblings for %d levels of arity %d", len(proof), len(directions), cfg.arity)}
+	}
+	if leaf == nil || root == nil {
+		return &Verification{Err: errors.New("missing leaf or root")}
+	}
+
+	result := &Verification{Trace: []*big.Int{leaf}}
+	node := leaf
+	for level, position := range directions {
+		result.Level = level
+		if position < 0 || position > siblings {
+			result.Err = fmt.Errorf("invalid direction %d on level %d", position, level)
+			return result
+		}
+
+		// Insert the path node among its siblings
+		input := make([]*big.Int, 0, cfg.arity)
+		input = append(input, proof[level*siblings:level*siblings+position]...)
+		input = append(input, node)
+		input = append(input, proof[level*siblings+position:(level+1)*siblings]...)
+
+		hashed, err := cfg.hashChildren(input)
+		if err != nil {
+			result.Err = fmt.Errorf("hashing level %d: %w", level+1, err)
+			return result
+		}
+		node = hashed
+		result.Trace = append(result.Trace, node)
+	}
+
+	result.Root = node
+	result.Level = len(directions)
+	if node.Cmp(root) != 0 {
+		result.Err = ErrRootMismatch
+		return result
+	}
+	result.Valid = true
+
+	return result
+}
+
+// Proof is a self-describing inclusion proof, carrying everything needed to
+// verify it besides trusting the root
+type Proof struct {
+	// Hasher is a name accepted by HasherByName
+	Hasher    string
+	Arity     int
+	SortPairs bool
+	DomainTag *big.Int
+	LeafIndex int
+	// TreeSize is the number of leaves of a PadPromoteOdd tree whose path
+	// skips the levels the leaf is promoted on, and 0 for every other proof
+	TreeSize   int
+	Leaf       *big.Int
+	Root       *big.Int
+	Siblings   []*big.Int
+	Directions []int
+}
+
+// Proof returns the inclusion proof of the leaf at leafIndex as a Proof
+func (t *MerkleTree) Proof(leafIndex int) (*Proof, error) {
+	siblings, directions, err := t.GenerateProof(leafIndex)
+	if err != nil {
+		return nil, err
+	}
+	treeSize := 0
+	if len(directions) < t.Depth() {
+		treeSize = t.numLeaves
+	}
+
+	return &Proof{
+		Hasher:     t.cfg.hasher.Name(),
+		Arity:      t.cfg.arity,
+		SortPairs:  t.cfg.sortPairs,
+		DomainTag:  t.cfg.domainTag,
+		LeafIndex:  leafIndex,
+		TreeSize:   treeSize,
+		Leaf:       t.levels[0][leafIndex].Data,
+		Root:       t.Root.Data,
+		Siblings:   siblings,
+		Directions: directions,
+	}, nil
+}
+
+// Verify checks that the leaf hashes up to the root with the hasher and arity
+// recorded in the proof, along the path of the leaf index. With SortPairs the
+// hashes do not depend on the directions, so they do not bind the leaf to its
+// index.
+func (p *Proof) Verify() bool {
+	h, err := HasherByName(p.Hasher)
+	if err != nil || p.Leaf == nil || p.Root == nil {
+		return false
+	}
+	if p.TreeSize > 0 {
+		if p.Arity != 2 || p.LeafIndex < 0 || p.LeafIndex >= p.TreeSize ||
+			!equalInts(p.Directions, promotedDirections(p.LeafIndex, p.TreeSize)) {
+			return false
+		}
+	} else if !indexDirections(p.LeafIndex, p.Directions, p.Arity) {
+		return false
+	}
+
+	opts := []Option{WithHasher(h), WithArity(p.Arity)}
+	if p.SortPairs {
+		opts = append(opts, WithSortedPairs())
+	}
+	if p.DomainTag != nil {
+		opts = append(opts, WithDomainTag(p.DomainTag))
+	}
+
+	return VerifyProof(p.Leaf, p.Siblings, p.Directions, p.Root, opts...)
+}
+
+// promotedDirections returns the directions of the path of the leaf at index in
+// a binary PadPromoteOdd tree of treeSize leaves, leaving out the levels it is
+// promoted on
+func promotedDirections(index, treeSize int) []int {
+	var directions []int
+	for size := treeSize; size > 1; size = (size + 1) / 2 {
+		if index%2 == 1 || index+1 < size {
+			directions = append(directions, index%2)
+		}
+		index /= 2
+	}
+
+	return directions
+}
+
+// equalInts reports whether a and b hold the same values
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ComposeProofs joins the proof of a leaf in a lower tree with the proof of
+// that tree's root in an upper tree, into the proof of the leaf against the root
+// of the upper tree. Both trees must be complete and hashed the same way.
+func ComposeProofs(lower, upper *Proof) (*Proof, error) {
+	if lower == nil || upper == nil || lower.Root == nil || upper.Leaf == nil {
+		return nil, errors.New("incomplete proof")
+	}
+	if lower.TreeSize > 0 || upper.TreeSize > 0 {
+		return nil, errors.New("proofs skipping promoted levels cannot be composed")
+	}
+	if lower.Hasher != upper.Hasher || lower.Arity != upper.Arity || lower.SortPairs != upper.SortPairs ||
+		(lower.DomainTag == nil) != (upper.DomainTag == nil) ||
+		(lower.DomainTag != nil && lower.DomainTag.Cmp(upper.DomainTag) != 0) {
+		return nil, errors.New("proofs are not hashed the same way")
+	}
+	if lower.Root.Cmp(upper.Leaf) != 0 {
+		return nil, errors.New("root of the lower proof is not the leaf of the upper proof")
+	}
+
+	return &Proof{
+		Hasher:     lower.Hasher,
+		Arity:      lower.Arity,
+		SortPairs:  lower.SortPairs,
+		DomainTag:  lower.DomainTag,
+		LeafIndex:  upper.LeafIndex*pow(lower.Arity, len(lower.Directions)) + lower.LeafIndex,
+		Leaf:       lower.Leaf,
+		Root:       upper.Root,
+		Siblings:   append(append([]*big.Int(nil), lower.Siblings...), upper.Siblings...),
+		Directions: append(append([]int(nil), lower.Directions...), upper.Directions...),
+	}, nil
+}
+
+// proofJSON is the JSON form of a Proof, with values as 0x-prefixed 32-byte
+// hex strings
+type proofJSON struct {
+	Hasher     string   `json:"hasher"`
+	Arity      int      `json:"arity"`
+	SortPairs  bool     `json:"sortPairs"`
+	DomainTag  string   `json:"domainTag,omitempty"`
+	LeafIndex  int      `json:"leafIndex"`
+	TreeSize   int      `json:"treeSize,omitempty"`
+	Leaf       string   `json:"leaf"`
+	Root       string   `json:"root"`
+	Siblings   []string `json:"siblings"`
+	Directions []int    `json:"directions"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *Proof) MarshalJSON() ([]byte, error) {
+	if p.Leaf == nil || p.Root == nil {
+		return nil, errors.New("proof has no leaf or root")
+	}
+
+	siblings := make([]string, len(p.Siblings))
+	for i, sibling := range p.Siblings {
+		siblings[i] = hexValue(sibling)
+	}
+
+	out := proofJSON{
+		Hasher:     p.Hasher,
+		Arity:      p.Arity,
+		SortPairs:  p.SortPairs,
+		LeafIndex:  p.LeafIndex,
+		TreeSize:   p.TreeSize,
+		Leaf:       hexValue(p.Leaf),
+		Root:       hexValue(p.Root),
+		Siblings:   siblings,
+		Directions: p.Directions,
+	}
+	if p.DomainTag != nil {
+		out.DomainTag = hexValue(p.DomainTag)
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *Proof) UnmarshalJSON(data []byte) error {
+	var in proofJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	leaf, err := parseHexValue(in.Leaf)
+	if err != nil {
+		return err
+	}
+	root, err := parseHexValue(in.Root)
+	if err != nil {
+		return err
+	}
+	siblings := make([]*big.Int, len(in.Siblings))
+	for i, sibling := range in.Siblings {
+		if siblings[i], err = parseHexValue(sibling); err != nil {
+			return err
+		}
+	}
+	var tag *big.Int
+	if in.DomainTag != "" {
+		if tag, err = parseHexValue(in.DomainTag); err != nil {
+			return err
+		}
+	}
+
+	*p = Proof{
+		Hasher:     in.Hasher,
+		Arity:      in.Arity,
+		SortPairs:  in.SortPairs,
+		DomainTag:  tag,
+		LeafIndex:  in.LeafIndex,
+		TreeSize:   in.TreeSize,
+		Leaf:       leaf,
+		Root:       root,
+		Siblings:   siblings,
+		Directions: in.Directions,
+	}
+
+	return nil
+}
+
+// proofVersion is the first byte of the compact encoding of a Proof
+const proofVersion = 1
+
+// MarshalBinary implements encoding.BinaryMarshaler with a compact encoding:
+// the version byte, the hasher name prefixed by its length, the arity, a flags
+// byte, the domain tag when flagged, the leaf index, the tree size when flagged,
+// the leaf and the root, the number of levels and their directions, and finally
+// the siblings. Integers are uvarints and values 32-byte big-endian words.
+func (p *Proof) MarshalBinary() ([]byte, error) {
+	if p.Leaf == nil || p.Root == nil {
+		return nil, errors.New("proof has no leaf or root")
+	}
+
+	buf := make([]byte, 0, 16+len(p.Hasher)+len(p.Directions)+32*(2+len(p.Siblings)))
+	appendWord := func(value *big.Int) error {
+		word, err := toWord(value)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, word[:]...)
+
+		return nil
+	}
+
+	buf = append(buf, proofVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(p.Hasher)))
+	buf = append(buf, p.Hasher...)
+	buf = binary.AppendUvarint(buf, uint64(p.Arity))
+	var flags byte
+	if p.SortPairs {
+		flags |= flagSortPairs
+	}
+	if p.DomainTag != nil {
+		flags |= flagDomainTag
+	}
+	if p.TreeSize > 0 {
+		flags |= flagTreeSize
+	}
+	buf = append(buf, flags)
+	if p.DomainTag != nil {
+		if err := appendWord(p.DomainTag); err != nil {
+			return nil, err
+		}
+	}
+	buf = binary.AppendUvarint(buf, uint64(p.LeafIndex))
+	if p.TreeSize > 0 {
+		buf = binary.AppendUvarint(buf, uint64(p.TreeSize))
+	}
+
+	if err := appendWord(p.Leaf); err != nil {
+		return nil, err
+	}
+	if err := appendWord(p.Root); err != nil {
+		return nil, err
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(p.Directions)))
+	for _, direction := range p.Directions {
+		buf = binary.AppendUvarint(buf, uint64(direction))
+	}
+	for _, sibling := range p.Siblings {
+		if err := appendWord(sibling); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+func (p *Proof) UnmarshalBinary(data []byte) error {
+	invalid := errors.New("invalid proof encoding")
+
+	if len(data) == 0 || data[0] != proofVersion {
+		return invalid
+	}
+	data = data[1:]
+
+	readUvarint := func() (int, error) {
+		v, n := binary.Uvarint(data)
+		if n <= 0 || v > uint64(^uint(0)>>1) {
+			return 0, invalid
+		}
+		data = data[n:]
+
+		return int(v), nil
+	}
+	readWord := func() (*big.Int, error) {
+		if len(data) < 32 {
+			return nil, invalid
+		}
+		v := new(big.Int).SetBytes(data[:32])
+		data = data[32:]
+
+		return v, nil
+	}
+
+	var out Proof
+	nameLen, err := readUvarint()
+	if err != nil || nameLen > len(data) {
+		return invalid
+	}
+	out.Hasher = string(data[:nameLen])
+	data = data[nameLen:]
+
+	if out.Arity, err = readUvarint(); err != nil || out.Arity < 2 {
+		return invalid
+	}
+	if len(data) == 0 {
+		return invalid
+	}
+	out.SortPairs = data[0]&flagSortPairs != 0
+	tagged := data[0]&flagDomainTag != 0
+	sized := data[0]&flagTreeSize != 0
+	data = data[1:]
+	if tagged {
+		if out.DomainTag, err = readWord(); err != nil {
+			return err
+		}
+	}
+
+	if out.LeafIndex, err = readUvarint(); err != nil {
+		return err
+	}
+	if sized {
+		if out.TreeSize, err = readUvarint(); err != nil || out.TreeSize == 0 {
+			return invalid
+		}
+	}
+	if out.Leaf, err = readWord(); err != nil {
+		return err
+	}
+	if out.Root, err = readWord(); err != nil {
+		return err
+	}
+
+	levels, err := readUvarint()
+	if err != nil || levels > len(data) {
+		return invalid
+	}
+	out.Directions = make([]int, levels)
+	for i := range out.Directions {
+		if out.Directions[i], err = readUvarint(); err != nil {
+			return err
+		}
+	}
+
+	if len(data)%32 != 0 || len(data)/32 != levels*(out.Arity-1) {
+		return invalid
+	}
+	out.Siblings = make([]*big.Int, len(data)/32)
+	for i := range out.Siblings {
+		out.Siblings[i], _ = readWord()
+	}
+
+	*p = out
+
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler with the compact encoding of
+// MarshalBinary as a 0x-prefixed hex string
+func (p *Proof) MarshalText() ([]byte, error) {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte("0x" + hex.EncodeToString(data)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (p *Proof) UnmarshalText(text []byte) error {
+	data, err := hex.DecodeString(strings.TrimPrefix(string(text), "0x"))
+	if err != nil {
+		return err
+	}
+
+	return p.UnmarshalBinary(data)
+}
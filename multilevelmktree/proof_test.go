@@ -0,0 +1,344 @@
+package multilevelmktree
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+func testLeaves(n int) []*big.Int {
+	leaves := make([]*big.Int, n)
+	for i := range leaves {
+		leaves[i] = big.NewInt(int64(i + 1))
+	}
+
+	return leaves
+}
+
+func TestGenerateProof(t *testing.T) {
+	leaves := testLeaves(8)
+	merkleTree := NewMerkleTreeWithLeaves(leaves)
+
+	for i, leaf := range leaves {
+		proof, directions, err := merkleTree.GenerateProof(i)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if len(proof) != 3 || len(directions) != 3 {
+			t.Fatal("Expected proof of length 3, got", len(proof), len(directions))
+		}
+
+		// Recompute the root from the leaf and its siblings
+		node := leaf
+		for level, sibling := range proof {
+			if directions[level] == 0 {
+				node, _ = poseidon.Hash([]*big.Int{node, sibling})
+			} else {
+				node, _ = poseidon.Hash([]*big.Int{sibling, node})
+			}
+		}
+
+		if node.Cmp(merkleTree.Root.Data) != 0 {
+			t.Error("Expected proof for leaf", i, "to hash to root", merkleTree.Root.Data, "got", node)
+		}
+
+		if !VerifyProof(leaf, proof, directions, merkleTree.Root.Data) {
+			t.Error("Expected proof for leaf", i, "to verify")
+		}
+	}
+}
+
+func TestGenerateProofOutOfRange(t *testing.T) {
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(4))
+
+	if _, _, err := merkleTree.GenerateProof(4); err == nil {
+		t.Error("Expected error for out of range leaf index, got nil")
+	}
+	if _, _, err := merkleTree.GenerateProof(-1); err == nil {
+		t.Error("Expected error for negative leaf index, got nil")
+	}
+}
+
+func TestVerifyProofRejectsTampering(t *testing.T) {
+	leaves := testLeaves(8)
+	merkleTree := NewMerkleTreeWithLeaves(leaves)
+	root := merkleTree.Root.Data
+
+	proof, directions, _ := merkleTree.GenerateProof(5)
+
+	if VerifyProof(leaves[4], proof, directions, root) {
+		t.Error("Expected proof to fail for a different leaf")
+	}
+
+	flipped := append([]int(nil), directions...)
+	flipped[0] ^= 1
+	if VerifyProof(leaves[5], proof, flipped, root) {
+		t.Error("Expected proof to fail with flipped direction bit")
+	}
+
+	if VerifyProof(leaves[5], proof[:2], directions[:2], root) {
+		t.Error("Expected truncated proof to fail")
+	}
+
+	if VerifyProof(leaves[5], proof, directions[:2], root) {
+		t.Error("Expected proof with mismatched lengths to fail")
+	}
+}
+
+func TestVerifyProofDetailed(t *testing.T) {
+	leaves := testLeaves(8)
+	merkleTree := NewMerkleTreeWithLeaves(leaves)
+	root := merkleTree.Root.Data
+	proof, directions, _ := merkleTree.GenerateProof(5)
+
+	result := VerifyProofDetailed(leaves[5], proof, directions, root)
+	if !result.Valid || result.Err != nil || result.Root.Cmp(root) != 0 {
+		t.Fatal("Expected a valid result with root", root, "got", result)
+	}
+	if len(result.Trace) != 4 {
+		t.Fatal("Expected the leaf and 3 computed nodes, got", len(result.Trace))
+	}
+	index := 5
+	for level, node := range result.Trace {
+		if expected := merkleTree.nodeAt(level, index).Data; node.Cmp(expected) != 0 {
+			t.Error("Expected trace node", expected, "on level", level, "got", node)
+		}
+		index /= 2
+	}
+
+	result = VerifyProofDetailed(leaves[4], proof, directions, root)
+	if result.Valid || !errors.Is(result.Err, ErrRootMismatch) || result.Level != 3 {
+		t.Error("Expected a root mismatch at level 3, got", result.Err, result.Level)
+	}
+	if result.Root == nil || result.Root.Cmp(root) == 0 {
+		t.Error("Expected the differing computed root, got", result.Root)
+	}
+
+	bad := append([]int(nil), directions...)
+	bad[1] = 2
+	result = VerifyProofDetailed(leaves[5], proof, bad, root)
+	if result.Valid || result.Err == nil || result.Level != 1 || len(result.Trace) != 2 {
+		t.Error("Expected failure at level 1 with a partial trace, got", result.Err, result.Level, len(result.Trace))
+	}
+
+	if result := VerifyProofDetailed(leaves[5], proof[:2], directions, root); result.Valid || result.Err == nil {
+		t.Error("Expected an error for a malformed proof")
+	}
+	if result := VerifyProofDetailed(big.NewInt(-1), proof, directions, root); result.Valid || errors.Is(result.Err, ErrRootMismatch) {
+		t.Error("Expected a hashing error for a leaf outside the field, got", result.Err)
+	}
+}
+
+func TestProofWithArity(t *testing.T) {
+	for _, arity := range []int{4, 8} {
+		leaves := testLeaves(arity * arity)
+		merkleTree := NewMerkleTreeWithLeaves(leaves, WithArity(arity))
+		root := merkleTree.Root.Data
+
+		for _, i := range []int{0, arity + 1, len(leaves) - 1} {
+			proof, directions, err := merkleTree.GenerateProof(i)
+			if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+			if len(directions) != 2 || len(proof) != 2*(arity-1) {
+				t.Fatal("Expected 2 levels of", arity-1, "siblings, got", len(directions), len(proof))
+			}
+
+			if !VerifyProof(leaves[i], proof, directions, root, WithArity(arity)) {
+				t.Error("Expected arity", arity, "proof for leaf", i, "to verify")
+			}
+			if VerifyProof(leaves[i], proof, directions, root) {
+				t.Error("Expected arity", arity, "proof to fail as a binary proof")
+			}
+		}
+	}
+}
+
+func TestSortedPairsProof(t *testing.T) {
+	// Descending leaves, so every pair is swapped before hashing
+	leaves := []*big.Int{big.NewInt(4), big.NewInt(3), big.NewInt(2), big.NewInt(1)}
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithHasher(Keccak256), WithSortedPairs())
+	root := merkleTree.Root.Data
+
+	left, _ := Keccak256.Hash([]*big.Int{leaves[1], leaves[0]})
+	right, _ := Keccak256.Hash([]*big.Int{leaves[3], leaves[2]})
+	if left.Cmp(right) > 0 {
+		left, right = right, left
+	}
+	expected, _ := Keccak256.Hash([]*big.Int{left, right})
+	if root.Cmp(expected) != 0 {
+		t.Error("Expected sorted pairs root", expected, "got", root)
+	}
+
+	for i, leaf := range leaves {
+		proof, _, _ := merkleTree.GenerateProof(i)
+		if !VerifyProof(leaf, proof, nil, root, WithHasher(Keccak256), WithSortedPairs()) {
+			t.Error("Expected sorted proof for leaf", i, "to verify without directions")
+		}
+	}
+}
+
+func TestDomainTagProof(t *testing.T) {
+	leaves := testLeaves(4)
+	tag := big.NewInt(7)
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithDomainTag(tag))
+	root := merkleTree.Root.Data
+
+	left, _ := poseidon.Hash([]*big.Int{tag, leaves[0], leaves[1]})
+	right, _ := poseidon.Hash([]*big.Int{tag, leaves[2], leaves[3]})
+	expected, _ := poseidon.Hash([]*big.Int{tag, left, right})
+	if root.Cmp(expected) != 0 {
+		t.Error("Expected tagged root", expected, "got", root)
+	}
+	if root.Cmp(NewMerkleTreeWithLeaves(leaves).Root.Data) == 0 {
+		t.Error("Expected tagged root to differ from the untagged root")
+	}
+
+	proof, directions, _ := merkleTree.GenerateProof(2)
+	if !VerifyProof(leaves[2], proof, directions, root, WithDomainTag(tag)) {
+		t.Error("Expected tagged proof to verify")
+	}
+	if VerifyProof(leaves[2], proof, directions, root) {
+		t.Error("Expected tagged proof to fail without the tag")
+	}
+	if VerifyProof(leaves[2], proof, directions, root, WithDomainTag(big.NewInt(8))) {
+		t.Error("Expected tagged proof to fail with another tag")
+	}
+}
+
+func TestProofEncodings(t *testing.T) {
+	cases := []struct {
+		numLeaves int
+		opts      []Option
+	}{
+		{8, nil},
+		{16, []Option{WithArity(4), WithHasher(Keccak256)}},
+		{5, []Option{WithRFC6962()}},
+		{6, []Option{WithHasher(Keccak256), WithSortedPairs(), WithPadding(PadZeroHash)}},
+		{8, []Option{WithDomainTag(big.NewInt(42)), WithSortedPairs()}},
+	}
+
+	for _, c := range cases {
+		merkleTree := NewMerkleTreeWithLeaves(testLeaves(c.numLeaves), c.opts...)
+		proof, err := merkleTree.Proof(c.numLeaves - 1)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if !proof.Verify() {
+			t.Error("Expected proof to verify with its recorded hasher")
+		}
+
+		fromJSON := &Proof{}
+		data, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if err := json.Unmarshal(data, fromJSON); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+
+		fromText := &Proof{}
+		text, err := proof.MarshalText()
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if err := fromText.UnmarshalText(text); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if len(text) >= len(data) {
+			t.Error("Expected the hex encoding to be more compact than JSON, got", len(text), "and", len(data))
+		}
+
+		for _, decoded := range []*Proof{fromJSON, fromText} {
+			if !reflect.DeepEqual(decoded, proof) {
+				t.Error("Expected decoded proof", proof, "got", decoded)
+			}
+			if !decoded.Verify() {
+				t.Error("Expected decoded proof to verify")
+			}
+		}
+	}
+}
+
+func TestProofRejectsTampering(t *testing.T) {
+	proof, _ := NewMerkleTreeWithLeaves(testLeaves(8)).Proof(3)
+
+	tampered := *proof
+	tampered.Leaf = big.NewInt(5)
+	if tampered.Verify() {
+		t.Error("Expected proof for another leaf to fail")
+	}
+
+	tampered = *proof
+	tampered.Hasher = "keccak256"
+	if tampered.Verify() {
+		t.Error("Expected proof with another hasher to fail")
+	}
+
+	for _, index := range []int{2, 4, 11, -1} {
+		tampered = *proof
+		tampered.LeafIndex = index
+		if tampered.Verify() {
+			t.Error("Expected proof of leaf 3 to fail as a proof of leaf", index)
+		}
+	}
+
+	// The path of leaf 4 of 5 skips the levels it is promoted on
+	promoted, _ := NewMerkleTreeWithLeaves(testLeaves(5), WithRFC6962()).Proof(4)
+	if promoted.TreeSize != 5 || len(promoted.Directions) != 1 || !promoted.Verify() {
+		t.Fatal("Expected a verifying proof of one level in a tree of 5 leaves, got", promoted.TreeSize, promoted.Directions)
+	}
+	for _, c := range []struct{ index, treeSize int }{{1, 5}, {2, 5}, {4, 6}, {4, 9}, {5, 5}} {
+		tampered = *promoted
+		tampered.LeafIndex, tampered.TreeSize = c.index, c.treeSize
+		if tampered.Verify() {
+			t.Error("Expected promoted proof of leaf 4 of 5 to fail as leaf", c.index, "of", c.treeSize)
+		}
+	}
+	if _, err := ComposeProofs(promoted, proof); err == nil {
+		t.Error("Expected error composing a proof that skips levels, got nil")
+	}
+
+	text, _ := proof.MarshalText()
+	for _, bad := range []string{"0x", "0x02", string(text[:len(text)-2]), string(text) + "00", "zz"} {
+		if err := (&Proof{}).UnmarshalText([]byte(bad)); err == nil {
+			t.Error("Expected error decoding", bad)
+		}
+	}
+}
+
+func TestComposeProofs(t *testing.T) {
+	leaves := testLeaves(16)
+	full := NewMerkleTreeWithLeaves(leaves)
+	lowerTree := NewMerkleTreeWithLeaves(leaves[8:12])
+
+	roots := make([]*big.Int, 4)
+	for i := range roots {
+		roots[i] = NewMerkleTreeWithLeaves(leaves[i*4 : (i+1)*4]).Root.Data
+	}
+	upperTree := NewMerkleTreeWithLeaves(roots)
+
+	lower, _ := lowerTree.Proof(1)
+	upper, _ := upperTree.Proof(2)
+	proof, err := ComposeProofs(lower, upper)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if expected, _ := full.Proof(9); !reflect.DeepEqual(proof, expected) {
+		t.Error("Expected composed proof", expected, "got", proof)
+	}
+
+	wrong, _ := upperTree.Proof(1)
+	if _, err := ComposeProofs(lower, wrong); err == nil {
+		t.Error("Expected error for an upper proof of another root, got nil")
+	}
+	tagged := *upper
+	tagged.DomainTag = big.NewInt(1)
+	if _, err := ComposeProofs(lower, &tagged); err == nil {
+		t.Error("Expected error for proofs hashed differently, got nil")
+	}
+}
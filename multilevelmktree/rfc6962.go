@@ -0,0 +1,95 @@
+package multilevelmktree
+
+import (
+	"crypto/sha256"
+	"math/big"
+)
+
+// RFC 6962 domain separation prefixes for leaf and interior node hashes
+const (
+	rfc6962LeafPrefix = 0x00
+	rfc6962NodePrefix = 0x01
+)
+
+type rfc6962Hasher struct{}
+
+func (rfc6962Hasher) Name() string { return "rfc6962" }
+
+// Hash returns SHA-256 of the interior node prefix followed by the inputs
+// encoded as 32-byte big-endian words
+func (rfc6962Hasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	hash := sha256.New()
+	hash.Write([]byte{rfc6962NodePrefix})
+	for _, input := range inputs {
+		word, err := toWord(input)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(word[:])
+	}
+
+	return new(big.Int).SetBytes(hash.Sum(nil)), nil
+}
+
+// RFC6962 hashes interior nodes like certificate transparency logs. Leaves
+// must already be hashed with RFC6962LeafHash.
+var RFC6962 = registerHasher(rfc6962Hasher{})
+
+// RFC6962LeafHash returns the RFC 6962 hash of a log entry, SHA-256 of the leaf
+// prefix followed by the entry
+func RFC6962LeafHash(entry []byte) *big.Int {
+	hash := sha256.New()
+	hash.Write([]byte{rfc6962LeafPrefix})
+	hash.Write(entry)
+
+	return new(big.Int).SetBytes(hash.Sum(nil))
+}
+
+// WithRFC6962 builds trees like RFC 6962 Merkle Tree Hashes: interior nodes are
+// hashed with the RFC6962 hasher and odd nodes are promoted, so the root of a
+// tree over RFC6962LeafHash values is the log's tree head hash and
+// GenerateProof returns its audit paths.
+func WithRFC6962() Option {
+	return func(cfg *config) {
+		cfg.hasher = RFC6962
+		cfg.arity = 2
+		cfg.padding = PadPromoteOdd
+	}
+}
+
+// VerifyRFC6962Proof checks an RFC 6962 audit path for the leaf hash at index in
+// a tree of treeSize leaves, following the algorithm of RFC 9162 section
+// 2.1.3.2. Unlike VerifyProof it needs no direction bits.
+func VerifyRFC6962Proof(leafHash *big.Int, index, treeSize int, path []*big.Int, root *big.Int) bool {
+	if index < 0 || index >= treeSize {
+		return false
+	}
+
+	fn := index
+	sn := treeSize - 1
+	r := leafHash
+	for _, p := range path {
+		if sn == 0 {
+			return false
+		}
+
+		var err error
+		if fn&1 == 1 || fn == sn {
+			r, err = RFC6962.Hash([]*big.Int{p, r})
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r, err = RFC6962.Hash([]*big.Int{r, p})
+		}
+		if err != nil {
+			return false
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && r.Cmp(root) == 0
+}
@@ -0,0 +1,83 @@
+package multilevelmktree
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// rfc6962Entries are the log entries of the certificate transparency reference
+// test vectors
+var rfc6962Entries = []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+
+func rfc6962LeafHashes(t *testing.T) []*big.Int {
+	leaves := make([]*big.Int, len(rfc6962Entries))
+	for i, entry := range rfc6962Entries {
+		data, err := hex.DecodeString(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaves[i] = RFC6962LeafHash(data)
+	}
+
+	return leaves
+}
+
+func TestRFC6962Roots(t *testing.T) {
+	roots := []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+	leaves := rfc6962LeafHashes(t)
+
+	for size := 1; size <= len(leaves); size++ {
+		expected, _ := new(big.Int).SetString(roots[size-1], 16)
+		merkleTree := NewMerkleTreeWithLeaves(leaves[:size], WithRFC6962())
+
+		if merkleTree.Root.Data.Cmp(expected) != 0 {
+			t.Errorf("Expected root of size %d to be %x, got %x", size, expected, merkleTree.Root.Data)
+		}
+	}
+}
+
+func TestRFC6962Proofs(t *testing.T) {
+	leaves := rfc6962LeafHashes(t)
+
+	for size := 1; size <= len(leaves); size++ {
+		merkleTree := NewMerkleTreeWithLeaves(leaves[:size], WithRFC6962())
+		root := merkleTree.Root.Data
+
+		for i := 0; i < size; i++ {
+			path, directions, err := merkleTree.GenerateProof(i)
+			if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			if !VerifyRFC6962Proof(leaves[i], i, size, path, root) {
+				t.Error("Expected audit path for leaf", i, "of size", size, "to verify")
+			}
+			if !VerifyProof(leaves[i], path, directions, root, WithRFC6962()) {
+				t.Error("Expected proof for leaf", i, "of size", size, "to verify")
+			}
+			if i^1 < size && VerifyRFC6962Proof(leaves[i], i^1, size, path, root) {
+				t.Error("Expected audit path for leaf", i, "to fail with a wrong index")
+			}
+		}
+
+		indices := []int{0, size / 2, size - 1}
+		proof, _ := merkleTree.GenerateMultiProof(indices)
+		proven := make([]*big.Int, len(proof.Indices))
+		for i, index := range proof.Indices {
+			proven[i] = leaves[index]
+		}
+		if !VerifyMultiProof(proven, proof, root, WithRFC6962()) {
+			t.Error("Expected multiproof of size", size, "to verify")
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package multilevelmktree
+
+import "math/big"
+
+// SemaphoreZeroValue returns the empty leaf of a Semaphore group,
+// uint256(keccak256(abi.encodePacked(groupId))) >> 8
+func SemaphoreZeroValue(groupID *big.Int) (*big.Int, error) {
+	word, err := toWord(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	return keccakShifted(word[:]), nil
+}
+
+// NewSemaphoreTree returns an empty incremental tree for a Semaphore group,
+// matching the IncrementalBinaryTree of Semaphore's group contracts: binary
+// Poseidon nodes, the group's zero value for empty leaves and identity
+// commitments inserted as leaves without hashing. Its roots can be registered
+// in existing Semaphore group contracts. Options that would hash nodes another
+// way, such as WithHasher, WithArity, WithSortedPairs, WithDomainTag or
+// WithPadding, are overridden.
+func NewSemaphoreTree(groupID *big.Int, depth int, opts ...Option) (*IncrementalMerkleTree, error) {
+	zero, err := SemaphoreZeroValue(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	semaphore := func(cfg *config) {
+		cfg.hasher = Poseidon
+		cfg.arity = 2
+		cfg.sortPairs = false
+		cfg.domainTag = nil
+		cfg.padding = PadError
+		cfg.zero = zero
+	}
+
+	return NewIncrementalMerkleTree(depth, append(opts, semaphore)...)
+}
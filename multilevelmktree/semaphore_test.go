@@ -0,0 +1,104 @@
+package multilevelmktree
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/iden3/go-iden3-crypto/poseidon"
+	"golang.org/x/crypto/sha3"
+)
+
+func TestSemaphoreTree(t *testing.T) {
+	groupID := big.NewInt(42)
+
+	var word [32]byte
+	word[31] = 42
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(word[:])
+	zero := new(big.Int).Rsh(new(big.Int).SetBytes(hash.Sum(nil)), 8)
+
+	semaphoreTree, err := NewSemaphoreTree(groupID, 2)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	// Empty group: zeroes chained with Poseidon
+	z1, _ := poseidon.Hash([]*big.Int{zero, zero})
+	z2, _ := poseidon.Hash([]*big.Int{z1, z1})
+	if semaphoreTree.Root().Cmp(z2) != 0 {
+		t.Error("Expected empty group root to be", z2, "got", semaphoreTree.Root())
+	}
+
+	// Commitments are inserted as is
+	commitment := big.NewInt(12345)
+	semaphoreTree.Insert(commitment)
+	l0, _ := poseidon.Hash([]*big.Int{commitment, zero})
+	root, _ := poseidon.Hash([]*big.Int{l0, z1})
+	if semaphoreTree.Root().Cmp(root) != 0 {
+		t.Error("Expected root after one member to be", root, "got", semaphoreTree.Root())
+	}
+}
+
+// TestSemaphoreFixtures checks the parts of a Semaphore group tree against
+// published constants rather than this package's own output
+func TestSemaphoreFixtures(t *testing.T) {
+	// keccak256(bytes32(0)) is 0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563
+	zero, err := SemaphoreZeroValue(big.NewInt(0))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	expected, _ := new(big.Int).SetString("00290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e5", 16)
+	if zero.Cmp(expected) != 0 {
+		t.Errorf("Expected zero value of group 0 to be %x, got %x", expected, zero)
+	}
+
+	// The empty roots of zk-kit's IncrementalBinaryTree, which Semaphore's
+	// group contracts use, with Poseidon over a zero value of 0
+	roots := []string{
+		"2098f5fb9e239eab3ceac3f27b81e481dc3124d55ffed523a839ee8446b64864",
+		"1069673dcdb12263df301a6ff584a7ec261a44cb9dc68df067a4774460b1f1e1",
+		"18f43331537ee2af2e3d758d50f72106467c6eea50371dd528d57eb2b856d238",
+	}
+	for i, root := range roots {
+		tree, err := NewIncrementalMerkleTree(i+1, WithHasher(Poseidon), WithZeroLeaf(big.NewInt(0)))
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		expected, _ := new(big.Int).SetString(root, 16)
+		if tree.Root().Cmp(expected) != 0 {
+			t.Errorf("Expected empty root of depth %d to be %x, got %x", i+1, expected, tree.Root())
+		}
+	}
+}
+
+func TestSemaphoreTreeOverridesOptions(t *testing.T) {
+	groupID := big.NewInt(7)
+
+	cases := [][]Option{
+		{WithDomainTag(big.NewInt(99))},
+		{WithPadding(PadZeroHash)},
+		{WithHasher(Keccak256), WithArity(4), WithSortedPairs()},
+		{WithZeroLeaf(big.NewInt(1))},
+	}
+	for _, opts := range cases {
+		plain, err := NewSemaphoreTree(groupID, 10)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		semaphoreTree, err := NewSemaphoreTree(groupID, 10, opts...)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if semaphoreTree.Root().Cmp(plain.Root()) != 0 {
+			t.Error("Expected the empty Semaphore root with", len(opts), "options, got", semaphoreTree.Root())
+		}
+		for _, tree := range []*IncrementalMerkleTree{plain, semaphoreTree} {
+			if _, err := tree.Insert(big.NewInt(12345)); err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+		}
+		if semaphoreTree.Root().Cmp(plain.Root()) != 0 {
+			t.Error("Expected the Semaphore root after an insert with", len(opts), "options, got", semaphoreTree.Root())
+		}
+	}
+}
@@ -0,0 +1,567 @@
+package multilevelmktree
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// JSONVersion is the version of the schema written by MarshalJSON
+const JSONVersion = 1
+
+// treeSnapshot holds everything needed to restore a MerkleTree without
+// rehashing: its options and the data of every node, level by level from the
+// leaves up
+type treeSnapshot struct {
+	Hasher    string
+	Arity     int
+	Padding   Padding
+	SortPairs bool
+	Zero      *big.Int
+	NumLeaves int
+	Levels    [][]*big.Int
+	LeafIndex bool
+	DomainTag *big.Int
+}
+
+func (t *MerkleTree) snapshot() (*treeSnapshot, error) {
+	name := t.cfg.hasher.Name()
+	if h, ok := hashers[name]; !ok || h != t.cfg.hasher {
+		return nil, fmt.Errorf("hasher %q is not a built-in hasher and cannot be restored", name)
+	}
+
+	levels := make([][]*big.Int, len(t.levels))
+	for l, nodes := range t.levels {
+		levels[l] = make([]*big.Int, len(nodes))
+		for i := range nodes {
+			levels[l][i] = nodes[i].Data
+		}
+	}
+
+	return &treeSnapshot{
+		Hasher:    name,
+		Arity:     t.cfg.arity,
+		Padding:   t.cfg.padding,
+		SortPairs: t.cfg.sortPairs,
+		Zero:      t.cfg.zero,
+		NumLeaves: t.numLeaves,
+		Levels:    levels,
+		LeafIndex: t.leafIndex != nil,
+		DomainTag: t.cfg.domainTag,
+	}, nil
+}
+
+func treeFromSnapshot(s *treeSnapshot) (*MerkleTree, error) {
+	h, err := HasherByName(s.Hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := newConfig([]Option{WithHasher(h), WithArity(s.Arity), WithPadding(s.Padding)})
+	cfg.sortPairs = s.SortPairs
+	cfg.zero = s.Zero
+	cfg.leafIndex = s.LeafIndex
+	cfg.domainTag = s.DomainTag
+	if cfg.arity < 2 {
+		return nil, fmt.Errorf("invalid arity %d", cfg.arity)
+	}
+	if len(s.Levels) == 0 || len(s.Levels[0]) == 0 || s.NumLeaves <= 0 || s.NumLeaves > len(s.Levels[0]) {
+		return nil, errors.New("invalid tree levels")
+	}
+
+	nodes := make([]MerkleNode, len(s.Levels[0]))
+	for i, leaf := range s.Levels[0] {
+		if leaf == nil {
+			return nil, errors.New("missing node data")
+		}
+		nodes[i].Data = leaf
+	}
+
+	levels := [][]MerkleNode{nodes}
+	for l, data := range s.Levels[1:] {
+		size := (len(nodes) + cfg.arity - 1) / cfg.arity
+		if len(nodes) <= 1 || len(data) != size {
+			return nil, errors.New("invalid tree levels")
+		}
+		for _, d := range data {
+			if d == nil {
+				return nil, errors.New("missing node data")
+			}
+		}
+
+		nodes, err = linkLevel(context.Background(), nodes, l+1, cfg, data)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, nodes)
+	}
+	if len(nodes) != 1 {
+		return nil, errors.New("invalid tree levels")
+	}
+
+	mTree := MerkleTree{Root: &nodes[0], cfg: cfg, levels: levels, numLeaves: s.NumLeaves}
+	if cfg.leafIndex {
+		mTree.buildLeafIndex()
+	}
+
+	return &mTree, nil
+}
+
+// GobEncode implements gob.GobEncoder, storing the options and every node so
+// the tree can be restored without rehashing. Only trees using a built-in
+// hasher can be encoded.
+func (t *MerkleTree) GobEncode() ([]byte, error) {
+	s, err := t.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder
+func (t *MerkleTree) GobDecode(data []byte) error {
+	var s treeSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+
+	decoded, err := treeFromSnapshot(&s)
+	if err != nil {
+		return err
+	}
+	*t = *decoded
+
+	return nil
+}
+
+// treeJSON is the JSON schema of a MerkleTree, see MarshalJSON
+type treeJSON struct {
+	Version   int        `json:"version"`
+	Hasher    string     `json:"hasher"`
+	Arity     int        `json:"arity"`
+	Padding   Padding    `json:"padding"`
+	SortPairs bool       `json:"sortPairs"`
+	ZeroLeaf  string     `json:"zeroLeaf,omitempty"`
+	NumLeaves int        `json:"numLeaves"`
+	Levels    [][]string `json:"levels"`
+	LeafIndex bool       `json:"leafIndex,omitempty"`
+	DomainTag string     `json:"domainTag,omitempty"`
+}
+
+// hexValue formats a node value as a 0x-prefixed 32-byte hex string
+func hexValue(v *big.Int) string {
+	return fmt.Sprintf("0x%064s", v.Text(16))
+}
+
+// parseHexValue parses a node value written by hexValue
+func parseHexValue(s string) (*big.Int, error) {
+	v, ok := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+	if !ok || v.Sign() < 0 || !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("invalid node value %q", s)
+	}
+
+	return v, nil
+}
+
+// MarshalJSON implements json.Marshaler with a versioned schema that can be
+// read without this package:
+//
+//	{
+//	    "version": 1,
+//	    "hasher": "poseidon",
+//	    "arity": 2,
+//	    "padding": "zero-hash",
+//	    "sortPairs": false,
+//	    "zeroLeaf": "0x...",
+//	    "domainTag": "0x...",
+//	    "numLeaves": 3,
+//	    "levels": [["0x...", "0x...", "0x...", "0x..."], ["0x...", "0x..."], ["0x..."]]
+//	}
+//
+// hasher is a name accepted by HasherByName and padding one of the names
+// returned by Padding.String. zeroLeaf is only present when the value of empty
+// leaves is overridden, domainTag only when set with WithDomainTag and
+// leafIndex only when the tree was built with WithLeafIndex. levels holds every level from the leaves up to the
+// root, where the first numLeaves leaves were given to the tree and the rest
+// are padding. Levels of trees padded with PadDuplicateOdd do not contain the
+// repeated last node. Values are 0x-prefixed 32-byte big-endian hex strings.
+func (t *MerkleTree) MarshalJSON() ([]byte, error) {
+	s, err := t.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	levels := make([][]string, len(s.Levels))
+	for l, values := range s.Levels {
+		levels[l] = make([]string, len(values))
+		for i, v := range values {
+			levels[l][i] = hexValue(v)
+		}
+	}
+
+	out := treeJSON{
+		Version:   JSONVersion,
+		Hasher:    s.Hasher,
+		Arity:     s.Arity,
+		Padding:   s.Padding,
+		SortPairs: s.SortPairs,
+		NumLeaves: s.NumLeaves,
+		Levels:    levels,
+		LeafIndex: s.LeafIndex,
+	}
+	if s.Zero != nil {
+		out.ZeroLeaf = hexValue(s.Zero)
+	}
+	if s.DomainTag != nil {
+		out.DomainTag = hexValue(s.DomainTag)
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for the schema written by
+// MarshalJSON. Node values are trusted and not rehashed.
+func (t *MerkleTree) UnmarshalJSON(data []byte) error {
+	var in treeJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Version != JSONVersion {
+		return fmt.Errorf("unsupported tree JSON version %d", in.Version)
+	}
+
+	s := treeSnapshot{
+		Hasher:    in.Hasher,
+		Arity:     in.Arity,
+		Padding:   in.Padding,
+		SortPairs: in.SortPairs,
+		NumLeaves: in.NumLeaves,
+		Levels:    make([][]*big.Int, len(in.Levels)),
+		LeafIndex: in.LeafIndex,
+	}
+	if in.ZeroLeaf != "" {
+		zero, err := parseHexValue(in.ZeroLeaf)
+		if err != nil {
+			return err
+		}
+		s.Zero = zero
+	}
+	if in.DomainTag != "" {
+		tag, err := parseHexValue(in.DomainTag)
+		if err != nil {
+			return err
+		}
+		s.DomainTag = tag
+	}
+	for l, values := range in.Levels {
+		s.Levels[l] = make([]*big.Int, len(values))
+		for i, value := range values {
+			v, err := parseHexValue(value)
+			if err != nil {
+				return err
+			}
+			s.Levels[l][i] = v
+		}
+	}
+
+	decoded, err := treeFromSnapshot(&s)
+	if err != nil {
+		return err
+	}
+	*t = *decoded
+
+	return nil
+}
+
+// binaryMagic starts every tree written by WriteTo
+var binaryMagic = [4]byte{'M', 'K', 'T', 'R'}
+
+// BinaryVersion is the version of the format written by WriteTo
+const BinaryVersion = 1
+
+const (
+	flagSortPairs = 1 << iota
+	flagZeroLeaf
+	flagLeafIndex
+	flagDomainTag
+	// flagTreeSize is only used in the encoding of a Proof
+	flagTreeSize
+)
+
+// binaryHeader is the fixed-size start of the binary format, encoded big-endian
+type binaryHeader struct {
+	Magic     [4]byte
+	Version   uint8
+	Arity     uint32
+	Padding   uint8
+	Flags     uint8
+	NumLeaves uint64
+	NumLevels uint32
+	HasherLen uint8
+}
+
+// maxBinaryLevels bounds the number of levels accepted by ReadFrom, as no tree
+// with an arity of at least two and fewer than 2^64 leaves has more
+const maxBinaryLevels = 65
+
+// wordsPerRead is the number of values ReadFrom reads at once
+const wordsPerRead = 4096
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+
+	return n, err
+}
+
+// WriteTo implements io.WriterTo with a compact binary encoding: the header
+// described by binaryHeader followed by the hasher name, the overridden empty
+// leaf and the domain tag when flagged and then every level from the leaves up
+// to the root, each as a uint64 node count and the node values as 32-byte
+// big-endian words. Only trees using a built-in hasher can be written.
+func (t *MerkleTree) WriteTo(w io.Writer) (int64, error) {
+	s, err := t.snapshot()
+	if err != nil {
+		return 0, err
+	}
+	if len(s.Hasher) > 255 {
+		return 0, fmt.Errorf("hasher name %q is too long", s.Hasher)
+	}
+
+	header := binaryHeader{
+		Magic:     binaryMagic,
+		Version:   BinaryVersion,
+		Arity:     uint32(s.Arity),
+		Padding:   uint8(s.Padding),
+		NumLeaves: uint64(s.NumLeaves),
+		NumLevels: uint32(len(s.Levels)),
+		HasherLen: uint8(len(s.Hasher)),
+	}
+	if s.SortPairs {
+		header.Flags |= flagSortPairs
+	}
+	if s.Zero != nil {
+		header.Flags |= flagZeroLeaf
+	}
+	if s.LeafIndex {
+		header.Flags |= flagLeafIndex
+	}
+	if s.DomainTag != nil {
+		header.Flags |= flagDomainTag
+	}
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	binary.Write(bw, binary.BigEndian, &header)
+	bw.WriteString(s.Hasher)
+
+	writeWord := func(v *big.Int) error {
+		word, err := toWord(v)
+		if err != nil {
+			return err
+		}
+		bw.Write(word[:])
+
+		return nil
+	}
+
+	if s.Zero != nil {
+		if err := writeWord(s.Zero); err != nil {
+			return cw.n, err
+		}
+	}
+	if s.DomainTag != nil {
+		if err := writeWord(s.DomainTag); err != nil {
+			return cw.n, err
+		}
+	}
+	for _, values := range s.Levels {
+		binary.Write(bw, binary.BigEndian, uint64(len(values)))
+		for _, v := range values {
+			if err := writeWord(v); err != nil {
+				return cw.n, err
+			}
+		}
+	}
+
+	// Write errors are kept by the buffered writer and returned here
+	err = bw.Flush()
+
+	return cw.n, err
+}
+
+// ReadFrom implements io.ReaderFrom for the format written by WriteTo, reading
+// exactly one tree from r. Node values are trusted and not rehashed.
+func (t *MerkleTree) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+
+	var header binaryHeader
+	if err := binary.Read(cr, binary.BigEndian, &header); err != nil {
+		return cr.n, err
+	}
+	if header.Magic != binaryMagic {
+		return cr.n, errors.New("not a binary Merkle tree")
+	}
+	if header.Version != BinaryVersion {
+		return cr.n, fmt.Errorf("unsupported binary tree version %d", header.Version)
+	}
+	if header.NumLevels > maxBinaryLevels || header.NumLeaves > uint64(^uint(0)>>1) {
+		return cr.n, errors.New("invalid tree levels")
+	}
+
+	name := make([]byte, header.HasherLen)
+	if _, err := io.ReadFull(cr, name); err != nil {
+		return cr.n, err
+	}
+
+	s := treeSnapshot{
+		Hasher:    string(name),
+		Arity:     int(header.Arity),
+		Padding:   Padding(header.Padding),
+		SortPairs: header.Flags&flagSortPairs != 0,
+		LeafIndex: header.Flags&flagLeafIndex != 0,
+		NumLeaves: int(header.NumLeaves),
+		Levels:    make([][]*big.Int, header.NumLevels),
+	}
+
+	buf := make([]byte, 32*wordsPerRead)
+	readWords := func(count uint64) ([]*big.Int, error) {
+		values := make([]*big.Int, 0, minUint64(count, wordsPerRead))
+		for count > 0 {
+			n := minUint64(count, wordsPerRead)
+			if _, err := io.ReadFull(cr, buf[:32*n]); err != nil {
+				return nil, err
+			}
+			for i := uint64(0); i < n; i++ {
+				values = append(values, new(big.Int).SetBytes(buf[32*i:32*(i+1)]))
+			}
+			count -= n
+		}
+
+		return values, nil
+	}
+
+	if header.Flags&flagZeroLeaf != 0 {
+		zero, err := readWords(1)
+		if err != nil {
+			return cr.n, err
+		}
+		s.Zero = zero[0]
+	}
+	if header.Flags&flagDomainTag != 0 {
+		tag, err := readWords(1)
+		if err != nil {
+			return cr.n, err
+		}
+		s.DomainTag = tag[0]
+	}
+	for l := range s.Levels {
+		var count uint64
+		if err := binary.Read(cr, binary.BigEndian, &count); err != nil {
+			return cr.n, err
+		}
+		values, err := readWords(count)
+		if err != nil {
+			return cr.n, err
+		}
+		s.Levels[l] = values
+	}
+
+	decoded, err := treeFromSnapshot(&s)
+	if err != nil {
+		return cr.n, err
+	}
+	*t = *decoded
+
+	return cr.n, nil
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// SaveToFile writes the tree to path, as JSON when the file name ends in .json,
+// with encoding/gob when it ends in .gob and in the binary format of WriteTo
+// otherwise
+func (t *MerkleTree) SaveToFile(path string) error {
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		data, err = json.MarshalIndent(t, "", "    ")
+	case ".gob":
+		var buf bytes.Buffer
+		err = gob.NewEncoder(&buf).Encode(t)
+		data = buf.Bytes()
+	default:
+		var buf bytes.Buffer
+		_, err = t.WriteTo(&buf)
+		data = buf.Bytes()
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadTreeFromFile reads a tree written by SaveToFile, detecting the format
+// from the content of the file rather than its name
+func LoadTreeFromFile(path string) (*MerkleTree, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &MerkleTree{}
+	switch {
+	case bytes.HasPrefix(data, binaryMagic[:]):
+		_, err = t.ReadFrom(bytes.NewReader(data))
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+		err = json.Unmarshal(data, t)
+	default:
+		err = gob.NewDecoder(bytes.NewReader(data)).Decode(t)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading tree from %s: %w", path, err)
+	}
+
+	return t, nil
+}
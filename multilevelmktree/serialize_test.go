@@ -0,0 +1,245 @@
+package multilevelmktree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// serializationCases covers trees whose structure differs by options
+var serializationCases = []struct {
+	numLeaves int
+	opts      []Option
+}{
+	{8, nil},
+	{16, []Option{WithArity(4), WithHasher(Keccak256)}},
+	{11, []Option{WithPadding(PadDuplicateOdd), WithSortedPairs()}},
+	{11, []Option{WithRFC6962()}},
+	{5, []Option{WithPadding(PadZeroHash)}},
+	{8, []Option{WithDomainTag(big.NewInt(3))}},
+}
+
+// checkRestoredTree compares a restored tree against the original
+func checkRestoredTree(t *testing.T, original, restored *MerkleTree) {
+	t.Helper()
+
+	if restored.Root.Data.Cmp(original.Root.Data) != 0 {
+		t.Error("Expected restored root to be", original.Root.Data, "got", restored.Root.Data)
+	}
+
+	for i := 0; i < original.numLeaves; i++ {
+		proof, directions, err := restored.GenerateProof(i)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		expected, expectedDirections, _ := original.GenerateProof(i)
+		if len(proof) != len(expected) || len(directions) != len(expectedDirections) {
+			t.Fatal("Expected restored proof for leaf", i, "to match the original")
+		}
+		for j := range proof {
+			if proof[j].Cmp(expected[j]) != 0 {
+				t.Error("Expected restored proof for leaf", i, "to match the original")
+			}
+		}
+	}
+
+	// Restored trees stay updatable
+	value := big.NewInt(1000)
+	original.UpdateLeaf(0, value)
+	if err := restored.UpdateLeaf(0, value); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if restored.Root.Data.Cmp(original.Root.Data) != 0 {
+		t.Error("Expected restored tree to update like the original")
+	}
+}
+
+func TestGob(t *testing.T) {
+	for _, c := range serializationCases {
+		merkleTree := NewMerkleTreeWithLeaves(testLeaves(c.numLeaves), c.opts...)
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(merkleTree); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+
+		var restored MerkleTree
+		if err := gob.NewDecoder(&buf).Decode(&restored); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+
+		checkRestoredTree(t, merkleTree, &restored)
+	}
+}
+
+func TestGobCustomHasher(t *testing.T) {
+	h, _ := NewPoseidonHasher("custom", blsModulus)
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(2), WithHasher(h))
+
+	if _, err := merkleTree.GobEncode(); err == nil {
+		t.Error("Expected error for a custom hasher, got nil")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	for _, c := range serializationCases {
+		merkleTree := NewMerkleTreeWithLeaves(testLeaves(c.numLeaves), c.opts...)
+
+		data, err := json.Marshal(merkleTree)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+
+		var restored MerkleTree
+		if err := json.Unmarshal(data, &restored); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+
+		checkRestoredTree(t, merkleTree, &restored)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(3), WithPadding(PadZeroHash))
+	data, _ := json.Marshal(merkleTree)
+
+	var schema struct {
+		Version   int        `json:"version"`
+		Hasher    string     `json:"hasher"`
+		Padding   string     `json:"padding"`
+		NumLeaves int        `json:"numLeaves"`
+		Levels    [][]string `json:"levels"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if schema.Version != JSONVersion || schema.Hasher != "poseidon" || schema.Padding != "zero-hash" || schema.NumLeaves != 3 {
+		t.Error("Unexpected tree JSON header", string(data))
+	}
+	if len(schema.Levels) != 3 || len(schema.Levels[0]) != 4 {
+		t.Fatal("Expected 3 levels over 4 leaves, got", schema.Levels)
+	}
+	root := fmt.Sprintf("0x%064s", merkleTree.Root.Data.Text(16))
+	if schema.Levels[2][0] != root {
+		t.Error("Expected root", root, "got", schema.Levels[2][0])
+	}
+}
+
+func TestJSONRejectsInvalidTrees(t *testing.T) {
+	valid, _ := json.Marshal(NewMerkleTreeWithLeaves(testLeaves(4)))
+
+	cases := []string{
+		strings.Replace(string(valid), `"version":1`, `"version":2`, 1),
+		strings.Replace(string(valid), `"hasher":"poseidon"`, `"hasher":"unknown"`, 1),
+		strings.Replace(string(valid), `"padding":"error"`, `"padding":"unknown"`, 1),
+		strings.Replace(string(valid), `"numLeaves":4`, `"numLeaves":5`, 1),
+		strings.Replace(string(valid), `"0x`, `"0xzz`, 1),
+		strings.Replace(string(valid), `],["0x`, `,"0x00"],["0x`, 1),
+	}
+
+	for _, c := range cases {
+		var restored MerkleTree
+		if err := json.Unmarshal([]byte(c), &restored); err == nil {
+			t.Error("Expected error decoding", c)
+		}
+	}
+}
+
+func TestBinary(t *testing.T) {
+	for _, c := range serializationCases {
+		merkleTree := NewMerkleTreeWithLeaves(testLeaves(c.numLeaves), c.opts...)
+
+		var buf bytes.Buffer
+		written, err := merkleTree.WriteTo(&buf)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if written != int64(buf.Len()) {
+			t.Error("Expected WriteTo to report", buf.Len(), "bytes, got", written)
+		}
+
+		// Trailing data is left unread
+		buf.WriteString("trailing")
+
+		var restored MerkleTree
+		read, err := restored.ReadFrom(&buf)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if read != written || buf.String() != "trailing" {
+			t.Error("Expected ReadFrom to read", written, "bytes, got", read)
+		}
+
+		checkRestoredTree(t, merkleTree, &restored)
+	}
+}
+
+func TestBinaryIsCompact(t *testing.T) {
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(64))
+
+	var buf bytes.Buffer
+	merkleTree.WriteTo(&buf)
+	encoded, _ := json.Marshal(merkleTree)
+
+	// 127 nodes of 32 bytes plus a small header
+	if buf.Len() > 127*32+128 || buf.Len()*2 > len(encoded) {
+		t.Error("Expected a compact binary encoding, got", buf.Len(), "bytes against", len(encoded), "for JSON")
+	}
+}
+
+func TestBinaryRejectsInvalidTrees(t *testing.T) {
+	var buf bytes.Buffer
+	NewMerkleTreeWithLeaves(testLeaves(4)).WriteTo(&buf)
+	valid := buf.Bytes()
+
+	badMagic := append([]byte(nil), valid...)
+	badMagic[0] = 'X'
+	badVersion := append([]byte(nil), valid...)
+	badVersion[4] = BinaryVersion + 1
+
+	for _, data := range [][]byte{badMagic, badVersion, valid[:len(valid)-1], valid[:10]} {
+		var restored MerkleTree
+		if _, err := restored.ReadFrom(bytes.NewReader(data)); err == nil {
+			t.Error("Expected error decoding", data)
+		}
+	}
+}
+
+func TestSaveAndLoadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"tree.json", "tree.gob", "tree.bin"} {
+		for _, c := range serializationCases {
+			merkleTree := NewMerkleTreeWithLeaves(testLeaves(c.numLeaves), c.opts...)
+
+			path := filepath.Join(dir, name)
+			if err := merkleTree.SaveToFile(path); err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			// Formats are detected from the content, not the name
+			renamed := filepath.Join(dir, "tree")
+			if err := os.Rename(path, renamed); err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			restored, err := LoadTreeFromFile(renamed)
+			if err != nil {
+				t.Fatal("Unexpected error loading", name, err)
+			}
+
+			checkRestoredTree(t, merkleTree, restored)
+		}
+	}
+
+	if _, err := LoadTreeFromFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected error loading a missing file, got nil")
+	}
+}
@@ -0,0 +1,95 @@
+package multilevelmktree
+
+import (
+	"math/big"
+	"unsafe"
+)
+
+// TreeStats describes the size of a tree
+type TreeStats struct {
+	// Nodes is the number of nodes held by the tree, including padding
+	Nodes int
+	// Leaves is the number of leaves given to the tree, excluding padding
+	Leaves int
+	// Depth is the number of levels between the root and the leaves
+	Depth int
+	// MemoryBytes estimates the memory held by the nodes and their values
+	MemoryBytes int64
+	// NonEmptySubtrees is the number of nodes, leaves included, with at least
+	// one leaf given to the tree below them
+	NonEmptySubtrees int
+}
+
+// Stats returns the size of the tree. The memory estimate grows linearly with
+// the number of nodes, so it can be scaled to size machines for larger trees.
+func (t *MerkleTree) Stats() TreeStats {
+	stats := TreeStats{
+		Leaves: t.numLeaves,
+		Depth:  t.Depth(),
+	}
+
+	values := make(map[*big.Int]bool)
+	span := 1
+	for _, nodes := range t.levels {
+		stats.Nodes += len(nodes)
+
+		// Nodes covering a leaf given to the tree come first on every level
+		nonEmpty := (t.numLeaves + span - 1) / span
+		if nonEmpty > len(nodes) {
+			nonEmpty = len(nodes)
+		}
+		stats.NonEmptySubtrees += nonEmpty
+		span *= t.cfg.arity
+
+		for i := range nodes {
+			stats.MemoryBytes += int64(unsafe.Sizeof(nodes[i])) + int64(cap(nodes[i].Children))*int64(unsafe.Sizeof(nodes[i].Left))
+
+			// Padding leaves share their value
+			if data := nodes[i].Data; data != nil && !values[data] {
+				values[data] = true
+				stats.MemoryBytes += int64(unsafe.Sizeof(*data)) + int64(cap(data.Bits()))*int64(unsafe.Sizeof(big.Word(0)))
+			}
+		}
+	}
+
+	return stats
+}
+
+// Len returns the number of leaves inserted into the tree. Removed leaves are
+// counted, as their indices are not reused.
+func (t *IncrementalMerkleTree) Len() int {
+	return t.NextIndex()
+}
+
+// Capacity returns the number of leaves the tree has room for, capped at the
+// largest int for trees too deep to count
+func (t *IncrementalMerkleTree) Capacity() int {
+	return t.capacity()
+}
+
+// Occupancy returns the number of leaves inserted below each child of the root,
+// each having room for arity^(depth-1) leaves. Leaves fill the children in
+// order, so the tree overflows once the last one is full. A tree of depth zero
+// has no children and returns nil.
+func (t *IncrementalMerkleTree) Occupancy() []int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.depth == 0 {
+		return nil
+	}
+
+	span := powCapped(t.cfg.arity, t.depth-1)
+	occupancy := make([]int, t.cfg.arity)
+	remaining := t.nextIndex
+	for i := range occupancy {
+		if remaining < span {
+			occupancy[i] = remaining
+			break
+		}
+		occupancy[i] = span
+		remaining -= span
+	}
+
+	return occupancy
+}
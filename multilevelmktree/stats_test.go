@@ -0,0 +1,81 @@
+package multilevelmktree
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	cases := []struct {
+		numLeaves int
+		opts      []Option
+		expected  TreeStats
+	}{
+		{8, nil, TreeStats{Nodes: 15, Leaves: 8, Depth: 3, NonEmptySubtrees: 15}},
+		{5, []Option{WithPadding(PadZeroHash)}, TreeStats{Nodes: 15, Leaves: 5, Depth: 3, NonEmptySubtrees: 5 + 3 + 2 + 1}},
+		{5, []Option{WithPadding(PadPromoteOdd)}, TreeStats{Nodes: 5 + 3 + 2 + 1, Leaves: 5, Depth: 3, NonEmptySubtrees: 11}},
+		{5, []Option{WithPadding(PadZeroHash), WithArity(4)}, TreeStats{Nodes: 16 + 4 + 1, Leaves: 5, Depth: 2, NonEmptySubtrees: 5 + 2 + 1}},
+	}
+
+	for _, c := range cases {
+		stats := NewMerkleTreeWithLeaves(testLeaves(c.numLeaves), c.opts...).Stats()
+		if stats.MemoryBytes <= 0 {
+			t.Error("Expected a memory estimate, got", stats.MemoryBytes)
+		}
+
+		stats.MemoryBytes = 0
+		if stats != c.expected {
+			t.Error("Expected stats", c.expected, "got", stats)
+		}
+	}
+
+	// The estimate grows with the tree
+	small := NewMerkleTreeWithLeaves(testLeaves(16)).Stats()
+	large := NewMerkleTreeWithLeaves(testLeaves(64)).Stats()
+	if large.MemoryBytes < 3*small.MemoryBytes {
+		t.Error("Expected the memory estimate to scale with the tree, got", small.MemoryBytes, "and", large.MemoryBytes)
+	}
+}
+
+func TestIncrementalMerkleTreeOccupancy(t *testing.T) {
+	imt, err := NewIncrementalMerkleTree(3, WithArity(4))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if _, err := imt.InsertBatch(testLeaves(37)); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if err := imt.Remove(3); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if imt.Len() != 37 {
+		t.Error("Expected 37 leaves, got", imt.Len())
+	}
+	if imt.Capacity() != 64 {
+		t.Error("Expected capacity 64, got", imt.Capacity())
+	}
+	if occupancy := imt.Occupancy(); !reflect.DeepEqual(occupancy, []int{16, 16, 5, 0}) {
+		t.Error("Expected occupancy [16 16 5 0], got", occupancy)
+	}
+
+	deep, err := NewIncrementalMerkleTree(80)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if _, err := deep.Insert(big.NewInt(1)); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if occupancy := deep.Occupancy(); !reflect.DeepEqual(occupancy, []int{1, 0}) {
+		t.Error("Expected occupancy [1 0], got", occupancy)
+	}
+
+	flat, err := NewIncrementalMerkleTree(0)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if occupancy := flat.Occupancy(); occupancy != nil {
+		t.Error("Expected no occupancy for depth 0, got", occupancy)
+	}
+}
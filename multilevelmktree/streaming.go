@@ -0,0 +1,144 @@
+package multilevelmktree
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// StreamingTree computes the root of a tree over leaves appended one at a time,
+// keeping only the frontier of incomplete nodes on each level instead of the
+// whole tree. Its root matches NewMerkleTreeWithLeaves over the same leaves and
+// options.
+type StreamingTree struct {
+	cfg *config
+	// frontier holds, for each level, the nodes whose parent is not complete yet
+	frontier [][]*big.Int
+	last     *big.Int
+	count    int
+}
+
+// NewStreamingTree returns an empty streaming tree configured by the options
+func NewStreamingTree(opts ...Option) *StreamingTree {
+	return &StreamingTree{cfg: newConfig(opts)}
+}
+
+// Count returns the number of leaves appended so far
+func (s *StreamingTree) Count() int {
+	return s.count
+}
+
+// Append adds a leaf, hashing every node that becomes complete
+func (s *StreamingTree) Append(leaf *big.Int) error {
+	if s.cfg.arity < 2 {
+		return fmt.Errorf("invalid arity %d", s.cfg.arity)
+	}
+
+	node := leaf
+	for level := 0; ; level++ {
+		if level == len(s.frontier) {
+			s.frontier = append(s.frontier, make([]*big.Int, 0, s.cfg.arity))
+		}
+
+		s.frontier[level] = append(s.frontier[level], node)
+		if len(s.frontier[level]) < s.cfg.arity {
+			break
+		}
+
+		parent, err := s.cfg.hashChildren(s.frontier[level])
+		if err != nil {
+			return err
+		}
+		s.frontier[level] = s.frontier[level][:0]
+		node = parent
+	}
+
+	s.last = leaf
+	s.count++
+
+	return nil
+}
+
+// Root returns the root over the leaves appended so far, completing the tree
+// according to the padding option
+func (s *StreamingTree) Root() (*big.Int, error) {
+	if s.count == 0 {
+		return nil, ErrNoLeaves
+	}
+	if s.cfg.padding == PadPromoteOdd && s.cfg.arity != 2 {
+		return nil, fmt.Errorf("padding %v requires a binary tree", s.cfg.padding)
+	}
+
+	top := len(s.frontier) - 1
+	for len(s.frontier[top]) == 0 {
+		top--
+	}
+
+	// fill is the root of a padding subtree on the current level
+	var fill *big.Int
+	switch s.cfg.padding {
+	case PadZeroHash:
+		zero, err := s.cfg.zeroLeaf()
+		if err != nil {
+			return nil, err
+		}
+		fill = zero
+	case PadDuplicateLast:
+		fill = s.last
+	}
+
+	var carry *big.Int
+	for level := 0; ; level++ {
+		var nodes []*big.Int
+		if level < len(s.frontier) {
+			nodes = append(nodes, s.frontier[level]...)
+		}
+		if carry != nil {
+			nodes = append(nodes, carry)
+		}
+
+		if level >= top && len(nodes) == 1 {
+			return nodes[0], nil
+		}
+
+		if len(nodes) > 0 {
+			switch s.cfg.padding {
+			case PadZeroHash, PadDuplicateLast:
+				for len(nodes) < s.cfg.arity {
+					nodes = append(nodes, fill)
+				}
+			case PadDuplicateOdd:
+				for len(nodes) < s.cfg.arity {
+					nodes = append(nodes, nodes[len(nodes)-1])
+				}
+			case PadPromoteOdd:
+				if len(nodes) == 1 {
+					carry = nodes[0]
+					continue
+				}
+			case PadError:
+				return nil, fmt.Errorf("%w: got %d leaves for arity %d", ErrLeafCount, s.count, s.cfg.arity)
+			default:
+				return nil, fmt.Errorf("unknown padding %v", s.cfg.padding)
+			}
+
+			hashed, err := s.cfg.hashChildren(nodes)
+			if err != nil {
+				return nil, err
+			}
+			carry = hashed
+		}
+
+		if fill != nil {
+			// Grow the padding subtree by one level
+			fills := make([]*big.Int, s.cfg.arity)
+			for i := range fills {
+				fills[i] = fill
+			}
+			hashed, err := s.cfg.hashChildren(fills)
+			if err != nil {
+				return nil, err
+			}
+			fill = hashed
+		}
+	}
+}
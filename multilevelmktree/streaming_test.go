@@ -0,0 +1,61 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStreamingTreeMatchesTree(t *testing.T) {
+	paddings := []Padding{PadZeroHash, PadDuplicateLast, PadDuplicateOdd, PadPromoteOdd}
+
+	for _, arity := range []int{2, 4} {
+		for _, padding := range paddings {
+			if padding == PadPromoteOdd && arity != 2 {
+				continue
+			}
+
+			leaves := testLeaves(20)
+			opts := []Option{WithArity(arity), WithPadding(padding)}
+			streamingTree := NewStreamingTree(opts...)
+
+			for n, leaf := range leaves {
+				if err := streamingTree.Append(leaf); err != nil {
+					t.Fatal("Unexpected error:", err)
+				}
+
+				root, err := streamingTree.Root()
+				if err != nil {
+					t.Fatal("Unexpected error:", err)
+				}
+
+				expected := NewMerkleTreeWithLeaves(leaves[:n+1], opts...).Root.Data
+				if root.Cmp(expected) != 0 {
+					t.Error("Expected streaming root of", n+1, "leaves with arity", arity, "and", padding, "to be", expected, "got", root)
+				}
+			}
+		}
+	}
+}
+
+func TestStreamingTreePadError(t *testing.T) {
+	streamingTree := NewStreamingTree()
+	if _, err := streamingTree.Root(); !errors.Is(err, ErrNoLeaves) {
+		t.Error("Expected ErrNoLeaves, got", err)
+	}
+
+	for i, leaf := range testLeaves(8) {
+		streamingTree.Append(leaf)
+
+		_, err := streamingTree.Root()
+		if powerOfTwo := (i+1)&i == 0; powerOfTwo && err != nil {
+			t.Error("Unexpected error for", i+1, "leaves:", err)
+		} else if !powerOfTwo && !errors.Is(err, ErrLeafCount) {
+			t.Error("Expected ErrLeafCount for", i+1, "leaves, got", err)
+		}
+	}
+
+	expected := NewMerkleTreeWithLeaves(testLeaves(8)).Root.Data
+	if root, _ := streamingTree.Root(); root.Cmp(expected) != 0 {
+		t.Error("Expected streaming root to be", expected, "got", root)
+	}
+}
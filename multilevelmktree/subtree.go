@@ -0,0 +1,242 @@
+package multilevelmktree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// full reports whether every level of the tree is complete, which holds for
+// all trees but those completed level by level with PadDuplicateOdd or
+// PadPromoteOdd over a number of leaves that is not a power of the arity
+func (t *MerkleTree) full() bool {
+	return len(t.levels[0]) == pow(t.cfg.arity, t.Depth())
+}
+
+// sameHashing reports whether nodes of a and b are hashed the same way
+func sameHashing(a, b *config) bool {
+	sameTag := a.domainTag == nil && b.domainTag == nil ||
+		a.domainTag != nil && b.domainTag != nil && a.domainTag.Cmp(b.domainTag) == 0
+
+	return a.hasher.Name() == b.hasher.Name() && a.arity == b.arity && a.sortPairs == b.sortPairs && sameTag
+}
+
+// Subtree returns the subtree rooted at the node at index within level, where
+// level 0 holds the leaves, as a tree of its own. It keeps the options of t and
+// the leaves below the node that were given to t, so it can be serialized and
+// grafted back with Graft or joined with JoinSubtrees.
+func (t *MerkleTree) Subtree(level, index int) (*MerkleTree, error) {
+	if !t.full() {
+		return nil, fmt.Errorf("subtrees of incomplete %v trees are not supported", t.cfg.padding)
+	}
+	if level < 0 || level > t.Depth() || index < 0 || index >= len(t.levels[level]) {
+		return nil, fmt.Errorf("no node %d on level %d", index, level)
+	}
+
+	width := pow(t.cfg.arity, level)
+	first := index * width
+	numLeaves := t.numLeaves - first
+	if numLeaves > width {
+		numLeaves = width
+	}
+	if numLeaves <= 0 {
+		return nil, errors.New("subtree holds only padding")
+	}
+
+	data := make([][]*big.Int, level+1)
+	for l := range data {
+		size := pow(t.cfg.arity, level-l)
+		offset := index * size
+		data[l] = make([]*big.Int, size)
+		for i := range data[l] {
+			data[l][i] = t.levels[l][offset+i].Data
+		}
+	}
+
+	return treeFromLevels(data, numLeaves, t.cfg)
+}
+
+// treeFromLevels links a tree over node data given level by level, without
+// rehashing
+func treeFromLevels(data [][]*big.Int, numLeaves int, cfg *config) (*MerkleTree, error) {
+	nodes := make([]MerkleNode, len(data[0]))
+	for i, leaf := range data[0] {
+		nodes[i].Data = leaf
+	}
+
+	levels := [][]MerkleNode{nodes}
+	for l := 1; l < len(data); l++ {
+		var err error
+		nodes, err = linkLevel(context.Background(), nodes, l, cfg, data[l])
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, nodes)
+	}
+
+	mTree := MerkleTree{Root: &nodes[0], cfg: cfg, levels: levels, numLeaves: numLeaves}
+	if cfg.leafIndex {
+		mTree.buildLeafIndex()
+	}
+
+	return &mTree, nil
+}
+
+// Graft replaces the subtree rooted at the node at index within level with sub
+// and rehashes the nodes above it. sub must be hashed like t, be as deep as
+// level and hold as many leaves given to the tree as the subtree it replaces.
+func (t *MerkleTree) Graft(level, index int, sub *MerkleTree) error {
+	current, err := t.Subtree(level, index)
+	if err != nil {
+		return err
+	}
+	if !sameHashing(t.cfg, sub.cfg) || !sub.full() {
+		return errors.New("subtree is not hashed like the tree")
+	}
+	if sub.Depth() != level || sub.numLeaves != current.numLeaves {
+		return fmt.Errorf("subtree of depth %d with %d leaves does not replace depth %d with %d leaves",
+			sub.Depth(), sub.numLeaves, level, current.numLeaves)
+	}
+
+	for l := 0; l <= level; l++ {
+		offset := index * pow(t.cfg.arity, level-l)
+		for i := range sub.levels[l] {
+			t.levels[l][offset+i].Data = sub.levels[l][i].Data
+		}
+	}
+
+	// Rehash the path from the grafted root up to the root of the tree
+	parent := index
+	for l := level + 1; l <= t.Depth(); l++ {
+		parent /= t.cfg.arity
+		if err := t.rehashNode(l, parent); err != nil {
+			// Put the previous subtree back, which hashed fine before
+			t.Graft(level, index, current)
+			return err
+		}
+	}
+
+	if t.cfg.padding == PadDuplicateLast && t.numLeaves < len(t.levels[0]) {
+		// Padding outside the graft copies the last leaf, which may have
+		// changed. It is not a leaf given to the tree, so no hook sees it.
+		padding := t.leafCopies(t.numLeaves - 1)[1:]
+		old := t.levels[0][padding[0]].Data
+		for _, i := range padding {
+			t.levels[0][i].Data = t.levels[0][t.numLeaves-1].Data
+		}
+		if err := t.rehash(padding); err != nil {
+			for _, i := range padding {
+				t.levels[0][i].Data = old
+			}
+			t.rehash(padding)
+			t.Graft(level, index, current)
+			return err
+		}
+	}
+	if t.leafIndex != nil {
+		t.buildLeafIndex()
+	}
+
+	return nil
+}
+
+// JoinSubtrees builds the tree whose lowest levels are the given subtrees, in
+// order, without rehashing them. The subtrees must be hashed alike, have the
+// same depth and be complete, and only the last may hold padding leaves. The
+// last may also be shallower, in which case it is rebuilt padded to the depth of
+// the others. Their number must be a power of the arity. The tree gets the
+// options of the first subtree.
+func JoinSubtrees(subtrees []*MerkleTree) (*MerkleTree, error) {
+	if len(subtrees) == 0 {
+		return nil, ErrNoLeaves
+	}
+
+	cfg := subtrees[0].cfg
+	depth := subtrees[0].Depth()
+	width := pow(cfg.arity, depth)
+	size := 1
+	for size < len(subtrees) {
+		size *= cfg.arity
+	}
+	if size != len(subtrees) {
+		return nil, fmt.Errorf("%w: got %d subtrees for arity %d", ErrLeafCount, len(subtrees), cfg.arity)
+	}
+
+	numLeaves := 0
+	data := make([][]*big.Int, depth+1)
+	for i, sub := range subtrees {
+		if i == len(subtrees)-1 && sub.Depth() < depth {
+			var err error
+			if sub, err = sub.deepen(depth); err != nil {
+				return nil, err
+			}
+		}
+		if !sameHashing(cfg, sub.cfg) || !sub.full() || sub.Depth() != depth {
+			return nil, fmt.Errorf("subtree %d does not match the first subtree", i)
+		}
+		if i < len(subtrees)-1 && sub.numLeaves != width {
+			return nil, fmt.Errorf("subtree %d holds padding but is not the last", i)
+		}
+
+		numLeaves += sub.numLeaves
+		for l := range data {
+			for _, node := range sub.levels[l] {
+				data[l] = append(data[l], node.Data)
+			}
+		}
+	}
+
+	// Hash the levels above the subtree roots
+	for len(data[len(data)-1]) > 1 {
+		below := data[len(data)-1]
+		above := make([]*big.Int, 0, len(below)/cfg.arity)
+		for j := 0; j < len(below); j += cfg.arity {
+			hashed, err := cfg.hashChildren(below[j : j+cfg.arity])
+			if err != nil {
+				return nil, &HashError{Level: len(data), Index: len(above), Err: err}
+			}
+			above = append(above, hashed)
+		}
+		data = append(data, above)
+	}
+
+	return treeFromLevels(data, numLeaves, cfg)
+}
+
+// deepen rebuilds the tree over its leaves padded up to the given depth
+func (t *MerkleTree) deepen(depth int) (*MerkleTree, error) {
+	var pad *big.Int
+	switch t.cfg.padding {
+	case PadZeroHash:
+		zero, err := t.cfg.zeroLeaf()
+		if err != nil {
+			return nil, err
+		}
+		pad = zero
+	case PadDuplicateLast:
+		pad = t.levels[0][t.numLeaves-1].Data
+	default:
+		return nil, fmt.Errorf("%v trees cannot be padded to depth %d", t.cfg.padding, depth)
+	}
+
+	leaves := make([]*big.Int, pow(t.cfg.arity, depth))
+	for i := range leaves {
+		if i < t.numLeaves {
+			leaves[i] = t.levels[0][i].Data
+		} else {
+			leaves[i] = pad
+		}
+	}
+
+	deeper, err := buildMerkleTree(context.Background(), leaves, t.cfg)
+	if err != nil {
+		return nil, err
+	}
+	deeper.numLeaves = t.numLeaves
+	if deeper.leafIndex != nil {
+		deeper.buildLeafIndex()
+	}
+
+	return deeper, nil
+}
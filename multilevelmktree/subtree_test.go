@@ -0,0 +1,133 @@
+package multilevelmktree
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestSubtree(t *testing.T) {
+	leaves := testLeaves(13)
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithPadding(PadZeroHash))
+
+	sub, err := merkleTree.Subtree(2, 1)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if expected := merkleTree.nodeAt(2, 1).Data; sub.Root.Data.Cmp(expected) != 0 {
+		t.Error("Expected subtree root", expected, "got", sub.Root.Data)
+	}
+	if expected := NewMerkleTreeWithLeaves(leaves[4:8]).Root.Data; sub.Root.Data.Cmp(expected) != 0 {
+		t.Error("Expected subtree to match a tree over its leaves, got", sub.Root.Data)
+	}
+
+	// The last subtree keeps its padding
+	last, _ := merkleTree.Subtree(2, 3)
+	if last.LeafCount() != 1 || len(last.levels[0]) != 4 {
+		t.Error("Expected 1 leaf and 3 padding leaves, got", last.LeafCount(), len(last.levels[0]))
+	}
+
+	if _, err := merkleTree.Subtree(5, 0); err == nil {
+		t.Error("Expected error for a level above the root, got nil")
+	}
+	if _, err := NewMerkleTreeWithLeaves(testLeaves(5), WithPadding(PadPromoteOdd)).Subtree(1, 0); err == nil {
+		t.Error("Expected error for an incomplete tree, got nil")
+	}
+}
+
+func TestGraft(t *testing.T) {
+	leaves := testLeaves(16)
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithLeafIndex())
+
+	// Build the replacement elsewhere and ship it serialized
+	replaced := append([]*big.Int(nil), leaves...)
+	for i := 8; i < 12; i++ {
+		replaced[i] = big.NewInt(int64(100 + i))
+	}
+	var buf bytes.Buffer
+	NewMerkleTreeWithLeaves(replaced[8:12]).WriteTo(&buf)
+	var sub MerkleTree
+	sub.ReadFrom(&buf)
+
+	if err := merkleTree.Graft(2, 2, &sub); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if expected := NewMerkleTreeWithLeaves(replaced).Root.Data; merkleTree.Root.Data.Cmp(expected) != 0 {
+		t.Error("Expected grafted root", expected, "got", merkleTree.Root.Data)
+	}
+	if index, _, _, err := merkleTree.ProveLeafValue(big.NewInt(109)); err != nil || index != 9 {
+		t.Error("Expected grafted leaf 109 at 9, got", index, err)
+	}
+
+	if err := merkleTree.Graft(1, 2, &sub); err == nil {
+		t.Error("Expected error grafting at the wrong depth, got nil")
+	}
+	keccak := NewMerkleTreeWithLeaves(replaced[8:12], WithHasher(Keccak256))
+	if err := merkleTree.Graft(2, 2, keccak); err == nil {
+		t.Error("Expected error grafting a subtree with another hasher, got nil")
+	}
+}
+
+func TestGraftDuplicateLast(t *testing.T) {
+	leaves := testLeaves(5)
+	var events []LeafEvent
+	merkleTree := NewMerkleTreeWithLeaves(leaves, WithPadding(PadDuplicateLast), WithLeafHook(func(event LeafEvent) {
+		events = append(events, event)
+	}))
+
+	replaced := append(append([]*big.Int(nil), leaves[:4]...), big.NewInt(50))
+	sub := NewMerkleTreeWithLeaves(replaced[4:], WithPadding(PadDuplicateLast))
+	if err := merkleTree.Graft(0, 4, sub); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if expected := NewMerkleTreeWithLeaves(replaced, WithPadding(PadDuplicateLast)).Root.Data; merkleTree.Root.Data.Cmp(expected) != 0 {
+		t.Error("Expected padding copies to follow the grafted leaf, got", merkleTree.Root.Data)
+	}
+	if len(events) != 0 {
+		t.Error("Expected no leaf events for the padding, got", events)
+	}
+}
+
+func TestJoinSubtrees(t *testing.T) {
+	for _, opts := range [][]Option{{WithPadding(PadZeroHash)}, {WithPadding(PadZeroHash), WithArity(4)}} {
+		leaves := testLeaves(55)
+		expected := NewMerkleTreeWithLeaves(leaves, opts...)
+
+		// Split the leaves across subtrees built independently
+		subtrees := make([]*MerkleTree, 0, 4)
+		width := len(expected.levels[0]) / 4
+		for i := 0; i < 4; i++ {
+			end := (i + 1) * width
+			if end > len(leaves) {
+				end = len(leaves)
+			}
+			subtrees = append(subtrees, NewMerkleTreeWithLeaves(leaves[i*width:end], opts...))
+		}
+
+		joined, err := JoinSubtrees(subtrees)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if joined.Root.Data.Cmp(expected.Root.Data) != 0 {
+			t.Error("Expected joined root", expected.Root.Data, "got", joined.Root.Data)
+		}
+		if joined.LeafCount() != 55 {
+			t.Error("Expected 55 leaves, got", joined.LeafCount())
+		}
+
+		proof, directions, _ := joined.GenerateProof(30)
+		if !VerifyProof(leaves[30], proof, directions, expected.Root.Data, opts...) {
+			t.Error("Expected proof from the joined tree to verify")
+		}
+	}
+
+	padded := NewMerkleTreeWithLeaves(testLeaves(3), WithPadding(PadZeroHash))
+	full := NewMerkleTreeWithLeaves(testLeaves(4))
+	if _, err := JoinSubtrees([]*MerkleTree{padded, full}); err == nil {
+		t.Error("Expected error for padding before the last subtree, got nil")
+	}
+	if _, err := JoinSubtrees([]*MerkleTree{full, full, full}); err == nil {
+		t.Error("Expected error for a subtree count that is not a power of the arity, got nil")
+	}
+}
@@ -0,0 +1,141 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// TransitionStep proves one insert: OldLeaf at Index hashes up to OldRoot and
+// NewLeaf at the same position, with the same siblings, up to NewRoot
+type TransitionStep struct {
+	Index      int
+	OldLeaf    *big.Int
+	NewLeaf    *big.Int
+	OldRoot    *big.Int
+	NewRoot    *big.Int
+	Siblings   []*big.Int
+	Directions []int
+}
+
+// TransitionProof proves that a batch of inserts took a tree from OldRoot to
+// NewRoot one leaf at a time, in the layout of rollup circuits that check a
+// pre-state and a post-state path for every insert
+type TransitionProof struct {
+	OldRoot *big.Int
+	NewRoot *big.Int
+	Steps   []TransitionStep
+}
+
+// InsertWithProof appends leaves like Insert and returns the proof of the
+// transition. If any leaf fails, none are inserted. It cannot be called while a
+// transaction is open.
+func (t *IncrementalMerkleTree) InsertWithProof(leaves []*big.Int) (*TransitionProof, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(leaves) == 0 {
+		return nil, ErrNoLeaves
+	}
+	if err := t.begin(); err != nil {
+		return nil, err
+	}
+
+	proof := TransitionProof{OldRoot: t.roots[t.currentRoot]}
+	for _, leaf := range leaves {
+		index := t.nextIndex
+		oldRoot := t.roots[t.currentRoot]
+		oldLeaf, err := t.node(0, index)
+		if err == nil {
+			_, err = t.insert(leaf)
+		}
+		var siblings []*big.Int
+		var directions []int
+		if err == nil {
+			siblings, directions, err = t.proof(index)
+		}
+		if err != nil {
+			t.rollback()
+			return nil, err
+		}
+
+		proof.Steps = append(proof.Steps, TransitionStep{
+			Index:      index,
+			OldLeaf:    oldLeaf,
+			NewLeaf:    leaf,
+			OldRoot:    oldRoot,
+			NewRoot:    t.roots[t.currentRoot],
+			Siblings:   siblings,
+			Directions: directions,
+		})
+	}
+
+	if err := t.commit(); err != nil {
+		t.rollback()
+		return nil, err
+	}
+	proof.NewRoot = t.roots[t.currentRoot]
+
+	return &proof, nil
+}
+
+// VerifyTransitionProof checks that every step of proof replaces the empty leaf
+// at the next index, along the path of that index, that each step starts from
+// the root the previous one ended at, and that the steps lead from OldRoot to
+// NewRoot. The options must select the hasher, arity and zero leaf the tree was
+// built with.
+func VerifyTransitionProof(proof *TransitionProof, opts ...Option) error {
+	if proof == nil || len(proof.Steps) == 0 || proof.OldRoot == nil || proof.NewRoot == nil {
+		return errors.New("empty transition proof")
+	}
+	cfg := newConfig(opts)
+	zero, err := cfg.zeroLeaf()
+	if err != nil {
+		return err
+	}
+
+	root := proof.OldRoot
+	for i, step := range proof.Steps {
+		if step.OldRoot == nil || step.OldRoot.Cmp(root) != 0 {
+			return fmt.Errorf("step %d does not start from the previous root", i)
+		}
+		if i > 0 && step.Index != proof.Steps[i-1].Index+1 {
+			return fmt.Errorf("step %d does not insert at the next index", i)
+		}
+		if !indexDirections(step.Index, step.Directions, cfg.arity) {
+			return fmt.Errorf("step %d does not follow the path of index %d", i, step.Index)
+		}
+		if step.OldLeaf == nil || step.OldLeaf.Cmp(zero) != 0 {
+			return fmt.Errorf("step %d replaces a leaf that is not empty", i)
+		}
+		if !VerifyProof(step.OldLeaf, step.Siblings, step.Directions, step.OldRoot, opts...) {
+			return fmt.Errorf("step %d has an invalid pre-state path", i)
+		}
+		if !VerifyProof(step.NewLeaf, step.Siblings, step.Directions, step.NewRoot, opts...) {
+			return fmt.Errorf("step %d has an invalid post-state path", i)
+		}
+		root = step.NewRoot
+	}
+	if root.Cmp(proof.NewRoot) != 0 {
+		return errors.New("steps do not end at the new root")
+	}
+
+	return nil
+}
+
+// indexDirections reports whether directions are the digits of index in base
+// arity from the lowest, the path of the leaf at index in a tree as deep as
+// there are directions
+func indexDirections(index int, directions []int, arity int) bool {
+	if index < 0 || arity < 2 {
+		return false
+	}
+	for _, position := range directions {
+		if position != index%arity {
+			return false
+		}
+		index /= arity
+	}
+
+	return index == 0
+}
@@ -0,0 +1,114 @@
+package multilevelmktree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestInsertWithProof(t *testing.T) {
+	imt, err := NewIncrementalMerkleTree(3, WithArity(2))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if _, err := imt.Insert(big.NewInt(100)); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	before := imt.Root()
+	proof, err := imt.InsertWithProof(testLeaves(4))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if proof.OldRoot.Cmp(before) != 0 || proof.NewRoot.Cmp(imt.Root()) != 0 {
+		t.Error("Expected proof to lead from", before, "to", imt.Root(), "got", proof.OldRoot, proof.NewRoot)
+	}
+	if len(proof.Steps) != 4 || proof.Steps[0].Index != 1 {
+		t.Fatal("Expected 4 steps starting at index 1, got", len(proof.Steps))
+	}
+	if err := VerifyTransitionProof(proof); err != nil {
+		t.Error("Expected transition proof to verify, got", err)
+	}
+
+	tampered := *proof
+	tampered.Steps = append([]TransitionStep(nil), proof.Steps...)
+	tampered.Steps[2].NewLeaf = big.NewInt(42)
+	if err := VerifyTransitionProof(&tampered); err == nil {
+		t.Error("Expected tampered leaf to fail verification")
+	}
+
+	// Overwriting the occupied leaf 0 while claiming the next index
+	tampered.Steps = append([]TransitionStep(nil), proof.Steps...)
+	tampered.Steps[0].Directions = []int{0, 0, 0}
+	if err := VerifyTransitionProof(&tampered); err == nil {
+		t.Error("Expected directions not matching the index to fail verification")
+	}
+
+	tampered.Steps = append([]TransitionStep(nil), proof.Steps...)
+	tampered.Steps[1].Index = 9
+	if err := VerifyTransitionProof(&tampered); err == nil {
+		t.Error("Expected index out of the tree to fail verification")
+	}
+
+	tampered.Steps = append([]TransitionStep(nil), proof.Steps...)
+	tampered.Steps[0].OldLeaf = big.NewInt(100)
+	if err := VerifyTransitionProof(&tampered); err == nil {
+		t.Error("Expected occupied old leaf to fail verification")
+	}
+
+	tampered.Steps = append(proof.Steps[:1:1], proof.Steps[2:]...)
+	if err := VerifyTransitionProof(&tampered); err == nil {
+		t.Error("Expected missing step to fail verification")
+	}
+
+	// The batch does not fit, so nothing is inserted
+	root := imt.Root()
+	if _, err := imt.InsertWithProof(testLeaves(4)); !errors.Is(err, ErrTreeFull) {
+		t.Error("Expected ErrTreeFull, got", err)
+	}
+	if imt.Root().Cmp(root) != 0 || imt.NextIndex() != 5 {
+		t.Error("Expected failed batch to leave the tree unchanged")
+	}
+}
+
+func TestTransitionProofOverwrite(t *testing.T) {
+	imt, err := NewIncrementalMerkleTree(3)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if _, err := imt.Insert(big.NewInt(100)); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	oldRoot := imt.Root()
+	siblings, directions, err := imt.GenerateProof(0)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if _, err := imt.Update(0, big.NewInt(7)); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	// Both paths hash to their roots, but the step overwrites leaf 0 while
+	// claiming to append at index 1
+	forged := &TransitionProof{
+		OldRoot: oldRoot,
+		NewRoot: imt.Root(),
+		Steps: []TransitionStep{{
+			Index:      1,
+			OldLeaf:    big.NewInt(100),
+			NewLeaf:    big.NewInt(7),
+			OldRoot:    oldRoot,
+			NewRoot:    imt.Root(),
+			Siblings:   siblings,
+			Directions: directions,
+		}},
+	}
+	if err := VerifyTransitionProof(forged); err == nil {
+		t.Error("Expected overwrite labelled as index 1 to fail verification")
+	}
+
+	forged.Steps[0].Index = 0
+	if err := VerifyTransitionProof(forged); err == nil {
+		t.Error("Expected overwrite of an occupied leaf to fail verification")
+	}
+}
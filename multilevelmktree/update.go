@@ -0,0 +1,164 @@
+package multilevelmktree
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// children returns the children of an internal node, or nil for a leaf
+func (n *MerkleNode) children() []*MerkleNode {
+	if n.Children != nil {
+		return n.Children
+	}
+	if n.Left == nil {
+		return nil
+	}
+
+	return []*MerkleNode{n.Left, n.Right}
+}
+
+// UpdateLeaf sets the leaf at index to newValue and rehashes only the nodes on
+// its path to the root. The resulting tree is the same as a rebuild over the
+// updated leaves, including padding that copies the last leaf.
+func (t *MerkleTree) UpdateLeaf(index int, newValue *big.Int) error {
+	if index < 0 || index >= t.numLeaves {
+		return fmt.Errorf("leaf index %d out of range [0, %d)", index, t.numLeaves)
+	}
+
+	indices := t.leafCopies(index)
+	old := t.levels[0][index].Data
+	oldRoot := t.Root.Data
+	for _, i := range indices {
+		t.levels[0][i].Data = newValue
+	}
+
+	if err := t.rehash(indices); err != nil {
+		// Restore the previous leaf, which hashed fine before
+		for _, i := range indices {
+			t.levels[0][i].Data = old
+		}
+		t.rehash(indices)
+
+		return err
+	}
+	t.reindexLeaf(index, old)
+	if t.cfg.leafHook != nil {
+		t.cfg.leafHook(LeafEvent{Index: index, Old: old, New: newValue, OldRoot: oldRoot, NewRoot: t.Root.Data})
+	}
+
+	return nil
+}
+
+// UpdateLeaves applies every change of updates, keyed by leaf index, and then
+// rehashes each affected ancestor once, so shared parts of the paths are not
+// recomputed for every leaf. If any index is invalid or hashing fails, the
+// tree is left unchanged.
+func (t *MerkleTree) UpdateLeaves(updates map[int]*big.Int) error {
+	indices := make([]int, 0, len(updates))
+	for index := range updates {
+		if index < 0 || index >= t.numLeaves {
+			return fmt.Errorf("leaf index %d out of range [0, %d)", index, t.numLeaves)
+		}
+		indices = append(indices, t.leafCopies(index)...)
+	}
+	sort.Ints(indices)
+
+	oldRoot := t.Root.Data
+	old := make([]*big.Int, len(indices))
+	for i, index := range indices {
+		old[i] = t.levels[0][index].Data
+		if index < t.numLeaves {
+			t.levels[0][index].Data = updates[index]
+		} else {
+			t.levels[0][index].Data = updates[t.numLeaves-1]
+		}
+	}
+
+	if err := t.rehash(indices); err != nil {
+		for i, index := range indices {
+			t.levels[0][index].Data = old[i]
+		}
+		t.rehash(indices)
+
+		return err
+	}
+	for i, index := range indices {
+		if index < t.numLeaves {
+			t.reindexLeaf(index, old[i])
+		}
+	}
+	if t.cfg.leafHook != nil {
+		for i, index := range indices {
+			if index < t.numLeaves {
+				t.cfg.leafHook(LeafEvent{Index: index, Old: old[i], New: updates[index], OldRoot: oldRoot, NewRoot: t.Root.Data})
+			}
+		}
+	}
+
+	return nil
+}
+
+// leafCopies returns index along with the padding leaves that copy it
+func (t *MerkleTree) leafCopies(index int) []int {
+	indices := []int{index}
+	if t.cfg.padding == PadDuplicateLast && index == t.numLeaves-1 {
+		for i := t.numLeaves; i < len(t.levels[0]); i++ {
+			indices = append(indices, i)
+		}
+	}
+
+	return indices
+}
+
+// rehash recomputes every ancestor of the given sorted leaf indices, each
+// exactly once, level by level up to the root
+func (t *MerkleTree) rehash(indices []int) error {
+	arity := t.cfg.arity
+	dirty := indices
+
+	for level := 1; level <= t.Depth(); level++ {
+		parents := make([]int, 0, len(dirty))
+		for _, index := range dirty {
+			parent := index / arity
+			if len(parents) > 0 && parents[len(parents)-1] == parent {
+				continue
+			}
+			parents = append(parents, parent)
+
+			if err := t.rehashNode(level, parent); err != nil {
+				return err
+			}
+		}
+
+		dirty = parents
+	}
+
+	return nil
+}
+
+// rehashNode recomputes the node at index within level from its children
+func (t *MerkleTree) rehashNode(level, index int) error {
+	node := &t.levels[level][index]
+
+	first := index * t.cfg.arity
+	if t.cfg.padding == PadPromoteOdd && first+1 == len(t.levels[level-1]) {
+		// Promoted copy of the lone node below
+		node.Data = t.levels[level-1][first].Data
+		return nil
+	}
+
+	children := node.children()
+	input := make([]*big.Int, len(children))
+	for i, child := range children {
+		input[i] = child.Data
+	}
+
+	hashed, err := t.cfg.hashChildren(input)
+	if err != nil {
+		return err
+	}
+	node.Data = hashed
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package multilevelmktree
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/iden3/go-iden3-crypto/constants"
+)
+
+func TestUpdateLeaf(t *testing.T) {
+	cases := []struct {
+		numLeaves int
+		opts      []Option
+	}{
+		{16, nil},
+		{16, []Option{WithArity(4)}},
+		{16, []Option{WithSortedPairs()}},
+		{11, []Option{WithPadding(PadZeroHash)}},
+		{11, []Option{WithPadding(PadDuplicateLast)}},
+		{11, []Option{WithPadding(PadDuplicateOdd)}},
+		{11, []Option{WithPadding(PadPromoteOdd)}},
+	}
+
+	for _, c := range cases {
+		numLeaves, opts := c.numLeaves, c.opts
+		leaves := testLeaves(numLeaves)
+		merkleTree := NewMerkleTreeWithLeaves(leaves, opts...)
+
+		for _, index := range []int{0, 5, numLeaves - 1} {
+			value := big.NewInt(int64(100 + index))
+			if err := merkleTree.UpdateLeaf(index, value); err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+			leaves[index] = value
+
+			expected := NewMerkleTreeWithLeaves(leaves, opts...).Root.Data
+			if merkleTree.Root.Data.Cmp(expected) != 0 {
+				t.Error("Expected root after updating leaf", index, "with", newConfig(opts).padding, "to be", expected, "got", merkleTree.Root.Data)
+			}
+
+			proof, directions, _ := merkleTree.GenerateProof(index)
+			if !VerifyProof(value, proof, directions, expected, opts...) {
+				t.Error("Expected proof for updated leaf", index, "to verify")
+			}
+		}
+	}
+}
+
+func TestUpdateLeafErrors(t *testing.T) {
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(4))
+	root := merkleTree.Root.Data
+
+	if err := merkleTree.UpdateLeaf(4, big.NewInt(1)); err == nil {
+		t.Error("Expected error for out of range leaf index, got nil")
+	}
+
+	// Poseidon rejects values outside the field
+	if err := merkleTree.UpdateLeaf(1, constants.Q); err == nil {
+		t.Error("Expected error for a value outside the field, got nil")
+	}
+	if merkleTree.Root.Data.Cmp(root) != 0 || merkleTree.levels[0][1].Data.Cmp(big.NewInt(2)) != 0 {
+		t.Error("Expected failed update to leave the tree unchanged")
+	}
+}
+
+func TestUpdateLeaves(t *testing.T) {
+	cases := []struct {
+		numLeaves int
+		opts      []Option
+	}{
+		{16, nil},
+		{13, []Option{WithPadding(PadDuplicateLast)}},
+		{13, []Option{WithPadding(PadPromoteOdd)}},
+		{13, []Option{WithArity(4), WithPadding(PadZeroHash)}},
+	}
+
+	for _, c := range cases {
+		leaves, opts := testLeaves(c.numLeaves), c.opts
+		merkleTree := NewMerkleTreeWithLeaves(leaves, opts...)
+
+		updates := map[int]*big.Int{
+			0:               big.NewInt(100),
+			3:               big.NewInt(103),
+			4:               big.NewInt(104),
+			len(leaves) - 1: big.NewInt(200),
+		}
+		if err := merkleTree.UpdateLeaves(updates); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		for index, value := range updates {
+			leaves[index] = value
+		}
+
+		expected := NewMerkleTreeWithLeaves(leaves, opts...).Root.Data
+		if merkleTree.Root.Data.Cmp(expected) != 0 {
+			t.Error("Expected root after batch update to be", expected, "got", merkleTree.Root.Data)
+		}
+	}
+}
+
+func TestUpdateLeavesErrors(t *testing.T) {
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(8))
+	root := merkleTree.Root.Data
+
+	if err := merkleTree.UpdateLeaves(map[int]*big.Int{1: big.NewInt(5), 8: big.NewInt(1)}); err == nil {
+		t.Error("Expected error for out of range leaf index, got nil")
+	}
+	if err := merkleTree.UpdateLeaves(map[int]*big.Int{1: big.NewInt(5), 6: constants.Q}); err == nil {
+		t.Error("Expected error for a value outside the field, got nil")
+	}
+	if merkleTree.Root.Data.Cmp(root) != 0 || merkleTree.levels[0][1].Data.Cmp(big.NewInt(2)) != 0 {
+		t.Error("Expected failed batch update to leave the tree unchanged")
+	}
+}
+
+func BenchmarkUpdateLeaves(b *testing.B) {
+	merkleTree := NewMerkleTreeWithLeaves(testLeaves(1 << 12))
+	updates := map[int]*big.Int{}
+	for i := 0; i < 1<<12; i += 4 {
+		updates[i] = big.NewInt(int64(i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		merkleTree.UpdateLeaves(updates)
+	}
+}
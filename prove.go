@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+
+	merkletree "github.com/pycckuu/merkle-tree-generation/multilevelmktree"
+)
+
+// prove writes the proof of a leaf against the root of the generated tree,
+// composed from its path in the branch holding it and the path of that branch
+// in the tree over the branches. The branches are read from a generated output
+// with -input, and regenerated from the tree flags otherwise.
+func prove(args []string) {
+	flags := flag.NewFlagSet("prove", flag.ExitOnError)
+	treeFlags := addTreeFlags(flags)
+	leafIndexPtr := flags.Int("leafIndex", 0, "The index of the leaf to prove, counted from the first leaf of the tree")
+	outputPtr := flags.String("output", "", "The file to write the JSON proof to, stdout by default or with -")
+	inputPtr := flags.String("input", "", "A JSON or NDJSON output of generate to take the levels, preimage and branches from, instead of regenerating them")
+	parseFlags(flags, args, false)
+
+	// The tiers are taken from the input when given, whatever the flags say
+	var hLevel, preImage int
+	var branchLevels []int
+	var output *Output
+	if *inputPtr != "" {
+		var err error
+		if output, err = readOutput(*inputPtr); err != nil {
+			log.Fatal(err)
+		}
+		hLevel, branchLevels, preImage = output.HLevel, []int{output.LLevel}, output.PreImage
+		if len(output.Levels) > 0 {
+			branchLevels = output.Levels[1:]
+		}
+
+		// Hash as recorded in the output, which older outputs do not do
+		if output.Hasher != "" {
+			*treeFlags.hash, *treeFlags.domainTag = output.Hasher, output.DomainTag
+		}
+		if *treeFlags.leaves == "" && output.Leaves != "" {
+			*treeFlags.leaves, *treeFlags.column = output.Leaves, output.Column
+			*treeFlags.leafEncoding, *treeFlags.hashLeaves = output.LeafEncoding, output.HashLeaves
+			*treeFlags.header = output.Header
+		}
+		if output.Seed != nil {
+			*treeFlags.random, *treeFlags.seed, *treeFlags.count = true, *output.Seed, output.Count
+		}
+	} else {
+		hLevel, branchLevels, _ = treeFlags.tiers()
+		preImage = *treeFlags.preImage
+	}
+	treeFlags.checkLeaves(hLevel+sum(branchLevels), preImage)
+	if output == nil {
+		treeFlags.confirm(hLevel, branchLevels)
+	}
+	opts := treeFlags.options()
+	span := 1 << sum(branchLevels)
+
+	ctx, cancel := treeFlags.context()
+	defer cancel()
+
+	leafIndex := *leafIndexPtr
+	if numLeaves := span << hLevel; leafIndex < 0 || leafIndex >= numLeaves {
+		log.Fatalf("leaf index %d out of range [0, %d)", leafIndex, numLeaves)
+	}
+
+	var branches []*big.Int
+	if output != nil {
+		branches = output.branches
+	} else {
+		var err error
+		if branches, err = getMerkleRoots(ctx, treeFlags.branchBuild(hLevel, branchLevels, preImage, opts)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	top, err := merkletree.NewMerkleTreeWithLeavesCtx(ctx, branches, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if output != nil && top.Root.Data.Cmp(output.root) != 0 {
+		log.Fatal("the branches of the input do not hash to its root, check -hash and -domainTag")
+	}
+	upper, err := top.Proof(leafIndex / span)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	branch, err := merkletree.NewMultilevelTree(ctx, branchLevels, (leafIndex/span+preImage)*span, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lower, err := branch.Proof(leafIndex % span)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	proof, err := merkletree.ComposeProofs(lower, upper)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !proof.Verify() {
+		log.Fatal("proof does not verify against the root")
+	}
+
+	proofJSON, err := json.MarshalIndent(proof, "", "    ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	path := *outputPtr
+	if path == "-" {
+		path = ""
+	}
+	file := createOutput(path)
+	if _, err := fmt.Fprintf(file, "%s\n", proofJSON); err != nil {
+		log.Fatalf("error writing output: %v", err)
+	}
+	closeOutput(file)
+	if path != "" {
+		treeFlags.printf("Proof written to %s\n", path)
+	}
+}
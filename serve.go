@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	merkletree "github.com/pycckuu/merkle-tree-generation/multilevelmktree"
+)
+
+// serve answers requests for the root and leaf proofs of a saved tree:
+//
+//	GET /root                  {"root": "0x..."}
+//	GET /proof?leafIndex=N     the proof of leaf N, as written by prove
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	treePtr := flags.String("tree", "", "The tree file to serve, as written by -saveTree")
+	addrPtr := flags.String("addr", "localhost:8080", "The address to listen on")
+	flags.Parse(args)
+
+	if *treePtr == "" {
+		log.Fatal("serve needs a -tree file")
+	}
+
+	tree, err := merkletree.LoadTreeFromFile(*treePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/root", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"root": fmt.Sprintf("0x%064s", tree.Root.Data.Text(16))})
+	})
+	mux.HandleFunc("/proof", func(w http.ResponseWriter, r *http.Request) {
+		leafIndex, err := strconv.Atoi(r.URL.Query().Get("leafIndex"))
+		if err != nil {
+			http.Error(w, "invalid leafIndex", http.StatusBadRequest)
+			return
+		}
+		proof, err := tree.Proof(leafIndex)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, proof)
+	})
+
+	log.Printf("Serving %s on http://%s", *treePtr, *addrPtr)
+	log.Fatal(http.ListenAndServe(*addrPtr, mux))
+}
+
+// writeJSON writes v as the JSON body of a response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	merkletree "github.com/pycckuu/merkle-tree-generation/multilevelmktree"
+)
+
+// verify checks a proof written by prove, exiting with status 1 if it does not
+// hash up to its root along the path of its leaf index, or the root differs
+// from the one given with -root
+func verify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	proofPtr := flags.String("proof", "", "The JSON proof to verify, as written by prove")
+	rootPtr := flags.String("root", "", "The 0x-prefixed hex root the proof must be for, the root in the proof by default")
+	flags.Parse(args)
+
+	if *proofPtr == "" {
+		log.Fatal("verify needs a -proof file")
+	}
+
+	data, err := os.ReadFile(*proofPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var proof merkletree.Proof
+	if err := json.Unmarshal(data, &proof); err != nil {
+		log.Fatalf("reading %s: %v", *proofPtr, err)
+	}
+
+	if *rootPtr != "" {
+		root, err := parseHex(*rootPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if root.Cmp(proof.Root) != 0 {
+			fmt.Fprintf(os.Stderr, "FAIL: the proof is for root 0x%064s, not %s\n", proof.Root.Text(16), *rootPtr)
+			os.Exit(1)
+		}
+	}
+
+	if !proof.Verify() {
+		fmt.Fprintf(os.Stderr, "FAIL: the proof of leaf %d does not verify against root 0x%064s with %s\n", proof.LeafIndex, proof.Root.Text(16), proof.Hasher)
+		os.Exit(1)
+	}
+	fmt.Printf("OK: leaf %d is in the tree with root 0x%064s\n", proof.LeafIndex, proof.Root.Text(16))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	merkletree "github.com/pycckuu/merkle-tree-generation/multilevelmktree"
+)
+
+// visualize writes a saved tree as a Graphviz graph
+func visualize(args []string) {
+	flags := flag.NewFlagSet("visualize", flag.ExitOnError)
+	treePtr := flags.String("tree", "", "The tree file to visualize, as written by -saveTree")
+	outPtr := flags.String("out", "", "The file to write the DOT graph to, stdout by default")
+	flags.Parse(args)
+
+	if *treePtr == "" {
+		log.Fatal("visualize needs a -tree file")
+	}
+
+	tree, err := merkletree.LoadTreeFromFile(*treePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out := os.Stdout
+	if *outPtr != "" {
+		out, err = os.Create(*outPtr)
+		if err != nil {
+			log.Fatalf("error opening file: %v", err)
+		}
+		defer out.Close()
+	}
+
+	if err := tree.ToDOT(out); err != nil {
+		log.Fatal(err)
+	}
+}